@@ -0,0 +1,237 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"gitlab.com/nyarla/go-crypt"
+)
+
+// AuthPlugin is the client side of a Firebird authentication plugin. A new
+// AuthPlugin is made for each connection, so it may keep state between its
+// calls.
+type AuthPlugin interface {
+	// Name returns the name the server knows the plugin by, e.g. "Srp256".
+	Name() string
+	// InitialData returns the data sent with op_connect, before the server
+	// has chosen a plugin.
+	InitialData() ([]byte, error)
+	// Continue returns the data answering serverData, and the session key
+	// for wire encryption if the plugin makes one. serverData is empty if
+	// the server switched to this plugin without data of its own.
+	Continue(serverData []byte) (clientData []byte, sessionKey []byte, err error)
+}
+
+// AuthPluginFactory makes the AuthPlugin of a connection as user.
+type AuthPluginFactory func(user string, password string) (AuthPlugin, error)
+
+var (
+	authPluginsMu sync.RWMutex
+	authPlugins   = make(map[string]AuthPluginFactory)
+)
+
+func init() {
+	for name := range srpProofHashes {
+		RegisterAuthPlugin(name, newSrpPlugin(name))
+	}
+	RegisterAuthPlugin("Legacy_Auth", newLegacyPlugin)
+}
+
+// RegisterAuthPlugin makes the plugin name available to the auth_plugin_name
+// and auth_plugin_list parameters. It replaces a plugin registered with the
+// same name.
+func RegisterAuthPlugin(name string, factory AuthPluginFactory) {
+	authPluginsMu.Lock()
+	defer authPluginsMu.Unlock()
+	authPlugins[name] = factory
+}
+
+// AuthPlugins returns the names of the registered plugins.
+func AuthPlugins() []string {
+	authPluginsMu.RLock()
+	defer authPluginsMu.RUnlock()
+	names := make([]string, 0, len(authPlugins))
+	for name := range authPlugins {
+		names = append(names, name)
+	}
+	return names
+}
+
+func newAuthPlugin(name string, user string, password string) (AuthPlugin, error) {
+	authPluginsMu.RLock()
+	factory, ok := authPlugins[name]
+	authPluginsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown plugin name:%s", name)
+	}
+	return factory(user, password)
+}
+
+// authPluginList returns the plugin list sent to the server, which starts
+// with the plugin sent first.
+func authPluginList(options map[string]string) string {
+	name := options["auth_plugin_name"]
+	names := []string{name}
+	for _, s := range strings.Split(options["auth_plugin_list"], ",") {
+		if s = strings.TrimSpace(s); s != "" && s != name {
+			names = append(names, s)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// normalizeUser returns the user name the server stores for user, following
+// the rules of SQL identifiers: a name in double quotes is taken as is, with
+// "" standing for a quote, and other names are upper-cased.
+func normalizeUser(user string) string {
+	if name, ok := unquoteUser(user); ok {
+		return name
+	}
+	return strings.ToUpper(user)
+}
+
+// dpbUser returns user for the user name item of a DPB, which the server
+// normalizes itself: quoted names are passed unchanged.
+func dpbUser(user string) string {
+	if _, ok := unquoteUser(user); ok {
+		return user
+	}
+	return strings.ToUpper(user)
+}
+
+func unquoteUser(user string) (string, bool) {
+	if len(user) < 2 || user[0] != '"' {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(user); i++ {
+		if user[i] != '"' {
+			b.WriteByte(user[i])
+		} else if i+1 < len(user) && user[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+		} else {
+			return b.String(), true
+		}
+	}
+	// no closing quote
+	return "", false
+}
+
+// specificData splits data into CNCT_specific_data items of up to 254
+// bytes, each starting with its step number.
+func specificData(data []byte) []byte {
+	var bs [][]byte
+	for step := 0; len(data) > 0 || step == 0; step++ {
+		n := min(len(data), 254)
+		bs = append(bs, []byte{CNCT_specific_data, byte(n + 1), byte(step)}, data[:n])
+		data = data[n:]
+	}
+	return bytes.Join(bs, nil)
+}
+
+// srpPlugin implements Srp (SHA-1 proof) and Srp224, Srp256, Srp384 and
+// Srp512 (SHA-2 proofs).
+type srpPlugin struct {
+	name         string
+	user         string
+	password     string
+	clientPublic *big.Int
+	clientSecret *big.Int
+}
+
+func newSrpPlugin(name string) AuthPluginFactory {
+	return func(user string, password string) (AuthPlugin, error) {
+		clientPublic, clientSecret, err := getClientSeed()
+		if err != nil {
+			return nil, err
+		}
+		return &srpPlugin{
+			name:         name,
+			user:         normalizeUser(user),
+			password:     password,
+			clientPublic: clientPublic,
+			clientSecret: clientSecret,
+		}, nil
+	}
+}
+
+func (s *srpPlugin) Name() string {
+	return s.name
+}
+
+func (s *srpPlugin) InitialData() ([]byte, error) {
+	return []byte(hex.EncodeToString(bigIntToBytes(s.clientPublic))), nil
+}
+
+func (s *srpPlugin) Continue(serverData []byte) ([]byte, []byte, error) {
+	if len(serverData) == 0 {
+		data, err := s.InitialData()
+		return data, nil, err
+	}
+	if len(serverData) < 2 {
+		return nil, nil, fmt.Errorf("%s: invalid server data", s.name)
+	}
+	ln := int(bytes_to_int16(serverData[:2]))
+	if len(serverData) < ln+4 {
+		return nil, nil, fmt.Errorf("%s: invalid server data", s.name)
+	}
+	serverSalt := serverData[2 : ln+2]
+	serverPublic := bigIntFromHexString(bytes_to_str(serverData[4+ln:]))
+	authData, sessionKey := getClientProof(s.user, s.password, serverSalt, s.clientPublic, serverPublic, s.clientSecret, s.name)
+	if DEBUG_SRP {
+		fmt.Printf("pluginName=%s\nserverSalt=%s\nserverPublic(bin)=%s\nserverPublic=%s\nauthData=%v,sessionKey=%v\n",
+			s.name, serverSalt, serverData[4+ln:], serverPublic, authData, sessionKey)
+	}
+	return []byte(hex.EncodeToString(authData)), sessionKey, nil
+}
+
+// legacyPlugin implements Legacy_Auth, which sends the DES hash of the
+// password and makes no session key.
+type legacyPlugin struct {
+	hash []byte
+}
+
+func newLegacyPlugin(user string, password string) (AuthPlugin, error) {
+	return &legacyPlugin{hash: []byte(crypt.Crypt(password, "9z")[2:])}, nil
+}
+
+func (l *legacyPlugin) Name() string {
+	return "Legacy_Auth"
+}
+
+func (l *legacyPlugin) InitialData() ([]byte, error) {
+	return l.hash, nil
+}
+
+func (l *legacyPlugin) Continue(serverData []byte) ([]byte, []byte, error) {
+	// after op_connect the hash is sent hex encoded
+	return []byte(hex.EncodeToString(l.hash)), nil, nil
+}
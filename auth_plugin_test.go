@@ -0,0 +1,282 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestSpecificData(t *testing.T) {
+	data := bytes.Repeat([]byte{'a'}, 300)
+	b := specificData(data)
+	if len(b) != 306 || !bytes.Equal(b[:3], []byte{CNCT_specific_data, 255, 0}) ||
+		!bytes.Equal(b[257:260], []byte{CNCT_specific_data, 47, 1}) {
+		t.Fatalf("specificData fail:%v", b)
+	}
+	b = specificData([]byte("abc"))
+	if !bytes.Equal(b, []byte{CNCT_specific_data, 4, 0, 'a', 'b', 'c'}) {
+		t.Fatalf("specificData fail:%v", b)
+	}
+}
+
+func TestAuthPluginList(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if s := authPluginList(dsn.options); s != "Srp256,Srp512,Srp384,Srp224,Srp,Legacy_Auth" {
+		t.Errorf("authPluginList fail:%s", s)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?auth_plugin_name=Srp&auth_plugin_list=Srp256,Srp")
+	if s := authPluginList(dsn.options); s != "Srp,Srp256" {
+		t.Errorf("authPluginList fail:%s", s)
+	}
+
+	cfg, _ := ParseConfig("user:password@localhost/dbname?auth_plugin_name=Srp")
+	cfg.AuthPlugins = []string{"Custom", "Srp256"}
+	dsn = cfg.firebirdDsn()
+	if s := authPluginList(dsn.options); s != "Custom,Srp256" {
+		t.Errorf("authPluginList fail:%s", s)
+	}
+}
+
+type testAuthPlugin struct {
+	user string
+}
+
+func (p *testAuthPlugin) Name() string {
+	return "Test_Auth"
+}
+
+func (p *testAuthPlugin) InitialData() ([]byte, error) {
+	return []byte(p.user), nil
+}
+
+func (p *testAuthPlugin) Continue(serverData []byte) ([]byte, []byte, error) {
+	return append([]byte("re:"), serverData...), []byte("key"), nil
+}
+
+func TestRegisterAuthPlugin(t *testing.T) {
+	if _, err := newAuthPlugin("Test_Auth", "alice", ""); err == nil {
+		t.Fatalf("Error Not occured")
+	}
+	RegisterAuthPlugin("Test_Auth", func(user string, password string) (AuthPlugin, error) {
+		return &testAuthPlugin{user: user}, nil
+	})
+	if !slices.Contains(AuthPlugins(), "Test_Auth") {
+		t.Fatalf("AuthPlugins fail:%v", AuthPlugins())
+	}
+	plugin, err := newAuthPlugin("Test_Auth", "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := plugin.InitialData()
+	if string(data) != "alice" {
+		t.Errorf("InitialData fail:%s", data)
+	}
+	data, key, _ := plugin.Continue([]byte("x"))
+	if string(data) != "re:x" || string(key) != "key" {
+		t.Errorf("Continue fail:%s,%s", data, key)
+	}
+}
+
+func TestSrpPlugin(t *testing.T) {
+	for _, name := range []string{"Srp", "Srp256"} {
+		plugin, err := newAuthPlugin(name, "sysdba", "masterkey")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srp := plugin.(*srpPlugin)
+		data, err := plugin.InitialData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := hex.EncodeToString(bigIntToBytes(srp.clientPublic)); string(data) != want {
+			t.Errorf("%s InitialData fail:%s", name, data)
+		}
+		if again, _, _ := plugin.Continue(nil); !bytes.Equal(again, data) {
+			t.Errorf("%s Continue(nil) fail:%s", name, again)
+		}
+
+		// server data: salt and public key, each with a little endian length
+		salt, _ := getSalt()
+		v := getVerifier("SYSDBA", "masterkey", salt)
+		keyB, keyb, err := getServerSeed(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serverPublic := []byte(hex.EncodeToString(bigIntToBytes(keyB)))
+		serverData := binary.LittleEndian.AppendUint16(nil, uint16(len(salt)))
+		serverData = append(serverData, salt...)
+		serverData = binary.LittleEndian.AppendUint16(serverData, uint16(len(serverPublic)))
+		serverData = append(serverData, serverPublic...)
+
+		proof, sessionKey, err := plugin.Continue(serverData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyM, keyK := getClientProof("SYSDBA", "masterkey", salt, srp.clientPublic, keyB, srp.clientSecret, name)
+		if string(proof) != hex.EncodeToString(keyM) || !bytes.Equal(sessionKey, keyK) {
+			t.Errorf("%s Continue fail", name)
+		}
+		if serverKey := getServerSession("SYSDBA", "masterkey", salt, srp.clientPublic, keyB, keyb); !bytes.Equal(sessionKey, serverKey) {
+			t.Errorf("%s session key fail", name)
+		}
+		if _, _, err = plugin.Continue([]byte{1}); err == nil {
+			t.Errorf("%s Error Not occured", name)
+		}
+	}
+}
+
+func TestNormalizeUser(t *testing.T) {
+	var testUsers = []struct {
+		user       string
+		normalized string
+		dpb        string
+	}{
+		{"sysdba", "SYSDBA", "SYSDBA"},
+		{"SysDba", "SYSDBA", "SYSDBA"},
+		{`"Alice"`, "Alice", `"Alice"`},
+		{`"a""b"`, `a"b`, `"a""b"`},
+		{`"x y"`, "x y", `"x y"`},
+		{`"open`, `"OPEN`, `"OPEN`},
+		{`"`, `"`, `"`},
+		{"", "", ""},
+	}
+	for _, d := range testUsers {
+		if s := normalizeUser(d.user); s != d.normalized {
+			t.Errorf("normalizeUser fail:%s(%s != %s)", d.user, s, d.normalized)
+		}
+		if s := dpbUser(d.user); s != d.dpb {
+			t.Errorf("dpbUser fail:%s(%s != %s)", d.user, s, d.dpb)
+		}
+	}
+}
+
+// testRoundsPlugin answers each round with the round number and makes a
+// session key on the last one.
+type testRoundsPlugin struct {
+	rounds int
+	seen   []string
+}
+
+func (p *testRoundsPlugin) Name() string {
+	return "Test_Rounds"
+}
+
+func (p *testRoundsPlugin) InitialData() ([]byte, error) {
+	return nil, nil
+}
+
+func (p *testRoundsPlugin) Continue(serverData []byte) ([]byte, []byte, error) {
+	p.seen = append(p.seen, string(serverData))
+	if len(p.seen) == p.rounds {
+		return []byte(fmt.Sprintf("r%d", len(p.seen))), []byte("key"), nil
+	}
+	return []byte(fmt.Sprintf("r%d", len(p.seen))), nil, nil
+}
+
+func TestParseConnectResponseRounds(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	wp, _ := newWireProtocolConn(client, "localhost:3050", "", "")
+	plugin := &testRoundsPlugin{rounds: 3}
+	wp.authPlugin = plugin
+	wp.pluginList = "Test_Rounds"
+
+	contAuth := func(data string) []byte {
+		return bytes.Join([][]byte{
+			bint32_to_bytes(op_cont_auth), xdrString(data), xdrString("Test_Rounds"), xdrString("Test_Rounds"), xdrString(""),
+		}, nil)
+	}
+	chReplies := make(chan []string, 1)
+	go func() {
+		defer server.Close()
+		server.Write(bytes.Join([][]byte{
+			bint32_to_bytes(op_cond_accept), bint32_to_bytes(PROTOCOL_VERSION16), bint32_to_bytes(1), bint32_to_bytes(ptype_lazy_send),
+			xdrString("s1"), xdrString("Test_Rounds"), bint32_to_bytes(0), xdrString(""),
+		}, nil))
+		var replies []string
+		for i := 2; i <= 4; i++ {
+			// op_cont_auth with the client data, the plugin name, the plugin list and the keys
+			b := make([]byte, len(contAuth("r1")))
+			if _, err := io.ReadFull(server, b); err != nil {
+				break
+			}
+			replies = append(replies, string(b[8:10]))
+			if i < 4 {
+				server.Write(contAuth(fmt.Sprintf("s%d", i)))
+			}
+		}
+		chReplies <- replies
+		// op_response without wire crypt plugins
+		server.Write(bytes.Join([][]byte{
+			bint32_to_bytes(op_response), bint32_to_bytes(0), make([]byte, 8), bint32_to_bytes(0), bint32_to_bytes(isc_arg_end),
+		}, nil))
+	}()
+
+	if err := wp._parse_connect_response("sysdba", "masterkey", nil); err != nil {
+		t.Fatalf("_parse_connect_response fail:%v", err)
+	}
+	if !slices.Equal(plugin.seen, []string{"s1", "s2", "s3"}) {
+		t.Errorf("Continue fail:%v", plugin.seen)
+	}
+	if replies := <-chReplies; !slices.Equal(replies, []string{"r1", "r2", "r3"}) {
+		t.Errorf("op_cont_auth fail:%v", replies)
+	}
+	if wp.pluginName != "Test_Rounds" || string(wp.authData) != "r3" {
+		t.Errorf("_parse_connect_response fail:%s,%s", wp.pluginName, wp.authData)
+	}
+}
+
+func TestParseConnectResponseAccept(t *testing.T) {
+	for _, d := range []struct {
+		version int32
+		plugin  string
+	}{
+		{PROTOCOL_VERSION13, "Test_Rounds"},
+		{10, "Legacy_Auth"},
+	} {
+		client, server := net.Pipe()
+		wp, _ := newWireProtocolConn(client, "localhost:3050", "", "")
+		wp.authPlugin = &testRoundsPlugin{}
+		go func() {
+			defer server.Close()
+			server.Write(bytes.Join([][]byte{
+				bint32_to_bytes(op_accept), bint32_to_bytes(d.version), bint32_to_bytes(1), bint32_to_bytes(ptype_lazy_send),
+			}, nil))
+		}()
+		if err := wp._parse_connect_response("sysdba", "masterkey", nil); err != nil {
+			t.Fatalf("_parse_connect_response fail:%v", err)
+		}
+		if wp.pluginName != d.plugin {
+			t.Errorf("pluginName fail:%d(%s != %s)", d.version, wp.pluginName, d.plugin)
+		}
+		client.Close()
+	}
+}
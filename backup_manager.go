@@ -1,5 +1,10 @@
 package firebirdsql
 
+import (
+	"context"
+	"io"
+)
+
 type BackupManager struct {
 	connBuilder func() (*ServiceManager, error)
 }
@@ -14,6 +19,7 @@ type BackupOptions struct {
 	Expand                                bool
 	Zip                                   bool
 	ParallelWorkers                       int32
+	Progress                              func(transferred int64) // BackupToWriter only
 }
 
 type BackupOption func(*BackupOptions)
@@ -28,6 +34,7 @@ type RestoreOptions struct {
 	PageSize             int32
 	CacheBuffers         int32
 	ParallelWorkers      int32
+	Progress             func(transferred int64) // RestoreFromReader only
 }
 
 type RestoreOption func(*RestoreOptions)
@@ -154,6 +161,12 @@ func WithoutBackupParallelWorkers() BackupOption {
 	}
 }
 
+func WithBackupProgress(progress func(transferred int64)) BackupOption {
+	return func(opts *BackupOptions) {
+		opts.Progress = progress
+	}
+}
+
 func NewBackupOptions(opts ...BackupOption) BackupOptions {
 	res := GetDefaultBackupOptions()
 	for _, opt := range opts {
@@ -266,6 +279,12 @@ func WithoutRestoreParallelWorkers() RestoreOption {
 	}
 }
 
+func WithRestoreProgress(progress func(transferred int64)) RestoreOption {
+	return func(opts *RestoreOptions) {
+		opts.Progress = progress
+	}
+}
+
 func NewRestoreOptions(opts ...RestoreOption) RestoreOptions {
 	res := GetDefaultRestoreOptions()
 	for _, opt := range opts {
@@ -284,6 +303,56 @@ func NewBackupManager(addr string, user string, password string, options Service
 }
 
 func (bm *BackupManager) Backup(database string, backup string, options BackupOptions, verbose chan string) error {
+	return bm.attach(backupSPB(database, backup, options, verbose != nil), verbose)
+}
+
+// BackupToWriter backs up database to w. The backup is sent by the server
+// through the service output, so it never touches the server's file system.
+// The backup is aborted when ctx is done.
+func (bm *BackupManager) BackupToWriter(ctx context.Context, database string, w io.Writer, options BackupOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn, err := bm.connBuilder()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	// a stalled server does not answer between chunks either
+	stop := conn.watchContext(ctx)
+	if err = conn.ServiceStart(backupSPB(database, "stdout", options, false)); err == nil {
+		err = conn.readStdout(ctx, w, options.Progress)
+	}
+	if ctxErr := stop(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// RestoreFromReader restores the backup read from r into database. The
+// backup is sent to the server through the service input. The restore is
+// aborted when ctx is done.
+func (bm *BackupManager) RestoreFromReader(ctx context.Context, r io.Reader, database string, options RestoreOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn, err := bm.connBuilder()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	// a stalled server does not answer between chunks either
+	stop := conn.watchContext(ctx)
+	if err = conn.ServiceStart(restoreSPB("stdin", database, options, false)); err == nil {
+		err = conn.writeStdin(ctx, r, options.Progress)
+	}
+	if ctxErr := stop(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func backupSPB(database string, backup string, options BackupOptions, verbose bool) []byte {
 	var optionsMask int32
 
 	if options.IgnoreChecksums {
@@ -327,14 +396,30 @@ func (bm *BackupManager) Backup(database string, backup string, options BackupOp
 		spb.PutInt32(isc_spb_bkp_parallel_workers, options.ParallelWorkers)
 	}
 
-	if verbose != nil {
+	if verbose {
 		spb.PutTag(isc_spb_verbose)
 	}
 
-	return bm.attach(spb.Bytes(), verbose)
+	return spb.Bytes()
+}
+
+// BackupContext is like Backup, but aborts the backup when ctx is done and
+// reports the verbose output as structured events.
+func (bm *BackupManager) BackupContext(ctx context.Context, database string, backup string, options BackupOptions, progress chan ServiceProgress) error {
+	return serviceAttachContext(ctx, bm.connBuilder, backupSPB(database, backup, options, progress != nil), progress)
 }
 
 func (bm *BackupManager) Restore(backup string, database string, options RestoreOptions, verbose chan string) error {
+	return bm.attach(restoreSPB(backup, database, options, verbose != nil), verbose)
+}
+
+// RestoreContext is like Restore, but aborts the restore when ctx is done and
+// reports the verbose output as structured events.
+func (bm *BackupManager) RestoreContext(ctx context.Context, backup string, database string, options RestoreOptions, progress chan ServiceProgress) error {
+	return serviceAttachContext(ctx, bm.connBuilder, restoreSPB(backup, database, options, progress != nil), progress)
+}
+
+func restoreSPB(backup string, database string, options RestoreOptions, verbose bool) []byte {
 	var optionsMask int32
 
 	if options.Replace {
@@ -372,7 +457,7 @@ func (bm *BackupManager) Restore(backup string, database string, options Restore
 		spb.PutInt32(isc_spb_res_parallel_workers, options.ParallelWorkers)
 	}
 
-	if verbose != nil {
+	if verbose {
 		spb.PutTag(isc_spb_verbose)
 	}
 
@@ -384,7 +469,7 @@ func (bm *BackupManager) Restore(backup string, database string, options Restore
 		spb.PutInt32(isc_spb_res_buffers, options.CacheBuffers)
 	}
 
-	return bm.attach(spb.Bytes(), verbose)
+	return spb.Bytes()
 }
 
 func (bm *BackupManager) attach(spb []byte, verbose chan string) error {
@@ -1,10 +1,15 @@
 package firebirdsql
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
+	"net"
 	"testing"
+	"time"
 )
 
 func TestBackupManager(t *testing.T) {
@@ -53,6 +58,64 @@ func TestBackupManager(t *testing.T) {
 	require.NoError(t, err, "Restore")
 }
 
+func TestBackupToWriterRestoreFromReader(t *testing.T) {
+	dbPathOrig := GetTestDatabase("test_backup_stream_orig_")
+	dbPathRest := GetTestDatabase("test_backup_stream_rest_")
+	conn, err := sql.Open("firebirdsql_createdb", GetTestDSNFromDatabase(dbPathOrig))
+	require.NoError(t, err, "sql.Open")
+	_, err = conn.Exec("create table test(a int)")
+	require.NoError(t, err, "Exec")
+	_, err = conn.Exec("insert into test values(123)")
+	require.NoError(t, err, "Exec")
+	conn.Close()
+
+	bm, err := NewBackupManager("localhost", GetTestUser(), GetTestPassword(), GetDefaultServiceManagerOptions())
+	require.NoError(t, err, "NewBackupManager")
+
+	ctx := context.Background()
+	var backup bytes.Buffer
+	var backedUp int64
+	err = bm.BackupToWriter(ctx, dbPathOrig, &backup, NewBackupOptions(WithBackupProgress(func(n int64) { backedUp = n })))
+	require.NoError(t, err, "BackupToWriter")
+	assert.NotZero(t, backup.Len())
+	assert.Equal(t, int64(backup.Len()), backedUp)
+
+	size := int64(backup.Len())
+	var restored int64
+	err = bm.RestoreFromReader(ctx, &backup, dbPathRest, NewRestoreOptions(WithRestoreProgress(func(n int64) { restored = n })))
+	require.NoError(t, err, "RestoreFromReader")
+	assert.Equal(t, size, restored)
+
+	conn, err = sql.Open("firebirdsql", GetTestDSNFromDatabase(dbPathRest))
+	require.NoError(t, err, "sql.Open")
+	defer conn.Close()
+	var res int
+	require.NoError(t, conn.QueryRow("select a from test").Scan(&res), "QueryRow")
+	assert.Equal(t, 123, res, "result in restored database should be same as in original")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = bm.BackupToWriter(cancelled, dbPathOrig, &backup, GetDefaultBackupOptions())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBackupToWriterStalledServer(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	// the server reads the request and never answers
+	go io.Copy(io.Discard, server)
+	wp, _ := newWireProtocolConn(client, "localhost:3050", "", "")
+	bm := &BackupManager{connBuilder: func() (*ServiceManager, error) {
+		return &ServiceManager{wp: wp}, nil
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var buf bytes.Buffer
+	err := bm.BackupToWriter(ctx, "employee", &buf, GetDefaultBackupOptions())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
 func TestBackupOptions(t *testing.T) {
 	opts := NewBackupOptions()
 	assert.Equal(t, BackupOptions{IgnoreChecksums: false, IgnoreLimboTransactions: false, MetadataOnly: false, GarbageCollect: true, Transportable: true, ConvertExternalTablesToInternalTables: true, Expand: false, Zip: false, ParallelWorkers: 0}, opts)
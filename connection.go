@@ -25,18 +25,29 @@ package firebirdsql
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
-	"math/big"
+	"errors"
+	"strings"
 )
 
+// Values of the "autocommit" DSN parameter.
+const (
+	autocommitClient = iota // commit retaining after each statement (default)
+	autocommitNone          // leave the work pending until it is committed explicitly
+	autocommitServer        // start the implicit transaction with isc_tpb_autocommit
+)
+
+// ErrNotFirebirdConn is returned when a *sql.Conn does not belong to this driver.
+var ErrNotFirebirdConn = errors.New("connection is not a firebirdsql connection")
+
 type firebirdsqlConn struct {
 	wp                *wireProtocol
 	tx                *firebirdsqlTx
 	dsn               *firebirdDsn
 	columnNameToLower bool
 	isAutocommit      bool
-	clientPublic      *big.Int
-	clientSecret      *big.Int
+	autocommitMode    int
 	transactionSet    map[*firebirdsqlTx]struct{}
 }
 
@@ -78,6 +89,24 @@ func (fc *firebirdsqlConn) Close() (err error) {
 	return
 }
 
+// CommitRetaining commits the current transaction of the connection, keeping
+// its context and open cursors.
+func (fc *firebirdsqlConn) CommitRetaining() error {
+	if fc.tx == nil {
+		return driver.ErrBadConn
+	}
+	return fc.tx.CommitRetaining()
+}
+
+// RollbackRetaining rolls back the current transaction of the connection,
+// keeping its context and open cursors.
+func (fc *firebirdsqlConn) RollbackRetaining() error {
+	if fc.tx == nil {
+		return driver.ErrBadConn
+	}
+	return fc.tx.RollbackRetaining()
+}
+
 func (fc *firebirdsqlConn) prepare(ctx context.Context, query string) (driver.Stmt, error) {
 	if fc.tx == nil {
 		return nil, driver.ErrBadConn
@@ -137,24 +166,38 @@ func (fc *firebirdsqlConn) Query(query string, args []driver.Value) (rows driver
 	return fc.query(context.Background(), query, args)
 }
 
-func openFirebirdsqlConn(dsn *firebirdDsn, dbOp func(*wireProtocol) error) (*firebirdsqlConn, error) {
-	wp, err := newWireProtocol(dsn.addr, dsn.options["timezone"], dsn.options["charset"])
+// openFirebirdsqlConn connects to the server of dsn and runs dbOp, which
+// attaches or creates the database, with dsn after its credentials are
+// resolved.
+func openFirebirdsqlConn(ctx context.Context, dsn *firebirdDsn, dbOp func(*wireProtocol, *firebirdDsn) error) (*firebirdsqlConn, error) {
+	dsn, err := dsn.resolveCredentials(ctx)
 	if err != nil {
 		return nil, err
 	}
-	columnNameToLower := convertToBool(dsn.options["column_name_to_lower"], false)
-	clientPublic, clientSecret, err := getClientSeed()
+	conn, err := dsn.dialContext(ctx, dsn.addr)
 	if err != nil {
 		return nil, err
 	}
+	wp, err := newWireProtocolConn(conn, dsn.addr, dsn.options["timezone"], dsn.options["charset"])
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	columnNameToLower := convertToBool(dsn.options["column_name_to_lower"], false)
+	autocommitMode := parseAutocommitMode(dsn.options["autocommit"])
 
-	if err = wp.opConnect(dsn.dbName, dsn.user, dsn.passwd, dsn.options, clientPublic); err != nil {
+	if wp.cryptKeyCallback, err = dsn.cryptKeyCallback(); err != nil {
+		wp.conn.Close()
 		return nil, err
 	}
-	if err = wp._parse_connect_response(dsn.user, dsn.passwd, dsn.options, clientPublic, clientSecret); err != nil {
+
+	if err = wp.opConnect(dsn.dbName, dsn.user, dsn.passwd, dsn.options); err != nil {
 		return nil, err
 	}
-	if err = dbOp(wp); err != nil {
+	if err = wp._parse_connect_response(dsn.user, dsn.passwd, dsn.options); err != nil {
+		return nil, err
+	}
+	if err = dbOp(wp, dsn); err != nil {
 		return nil, err
 	}
 	wp.dbHandle, _, _, err = wp.opResponse()
@@ -167,25 +210,96 @@ func openFirebirdsqlConn(dsn *firebirdDsn, dbOp func(*wireProtocol) error) (*fir
 		wp:                wp,
 		dsn:               dsn,
 		columnNameToLower: columnNameToLower,
-		isAutocommit:      true,
-		clientPublic:      clientPublic,
-		clientSecret:      clientSecret,
+		isAutocommit:      autocommitMode == autocommitClient,
+		autocommitMode:    autocommitMode,
 	}
-	fc.tx, err = newFirebirdsqlTx(fc, ISOLATION_LEVEL_READ_COMMITED, fc.isAutocommit, false)
+	fc.tx, err = newFirebirdsqlTx(fc, ISOLATION_LEVEL_READ_COMMITED, true, false)
 	if err != nil {
 		return nil, err
 	}
 	return fc, nil
 }
 
-func attachFirebirdsqlConn(dsn *firebirdDsn) (*firebirdsqlConn, error) {
-	return openFirebirdsqlConn(dsn, func(wp *wireProtocol) error {
+func attachFirebirdsqlConn(ctx context.Context, dsn *firebirdDsn) (*firebirdsqlConn, error) {
+	return openFirebirdsqlConn(ctx, dsn, func(wp *wireProtocol, dsn *firebirdDsn) error {
 		return wp.opAttach(dsn.dbName, dsn.user, dsn.passwd, dsn.options["role"])
 	})
 }
 
 func createFirebirdsqlConn(dsn *firebirdDsn) (*firebirdsqlConn, error) {
-	return openFirebirdsqlConn(dsn, func(wp *wireProtocol) error {
-		return wp.opCreate(dsn.dbName, dsn.user, dsn.passwd, dsn.options["role"])
+	opts, err := createOptionsFromDsn(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return createDatabaseConn(context.Background(), dsn, opts)
+}
+
+func parseAutocommitMode(s string) int {
+	if strings.EqualFold(s, "server") {
+		return autocommitServer
+	}
+	if convertToBool(s, true) {
+		return autocommitClient
+	}
+	return autocommitNone
+}
+
+// CommitRetaining commits the current transaction of conn, which is either the
+// one started with conn.BeginTx or the implicit one used outside transactions,
+// without ending it. Cursors opened in the transaction stay open.
+func CommitRetaining(conn *sql.Conn) error {
+	return conn.Raw(func(driverConn any) error {
+		fc, ok := driverConn.(*firebirdsqlConn)
+		if !ok {
+			return ErrNotFirebirdConn
+		}
+		return fc.CommitRetaining()
+	})
+}
+
+// RollbackRetaining rolls back the current transaction of conn without ending
+// it. Cursors opened in the transaction stay open.
+func RollbackRetaining(conn *sql.Conn) error {
+	return conn.Raw(func(driverConn any) error {
+		fc, ok := driverConn.(*firebirdsqlConn)
+		if !ok {
+			return ErrNotFirebirdConn
+		}
+		return fc.RollbackRetaining()
+	})
+}
+
+// ConnInfo describes what a connection negotiated with the server.
+type ConnInfo struct {
+	ProtocolVersion int32  // wire protocol version, e.g. 17 for Firebird 5
+	WireCrypt       string // cipher of the wire encryption, empty if not encrypted
+	WireCompress    bool   // zlib compression of the wire protocol
+	AuthPlugin      string // authentication plugin chosen by the server
+}
+
+func (p *wireProtocol) connInfo() ConnInfo {
+	return ConnInfo{
+		ProtocolVersion: p.protocolVersion,
+		WireCrypt:       p.conn.plugin,
+		WireCompress:    p.conn.compressor != nil,
+		AuthPlugin:      p.pluginName,
+	}
+}
+
+// ConnectionInfo returns what conn negotiated with the server when it
+// attached.
+func ConnectionInfo(conn *sql.Conn) (*ConnInfo, error) {
+	var info ConnInfo
+	err := conn.Raw(func(driverConn any) error {
+		fc, ok := driverConn.(*firebirdsqlConn)
+		if !ok {
+			return ErrNotFirebirdConn
+		}
+		info = fc.wp.connInfo()
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
 }
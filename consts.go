@@ -36,6 +36,7 @@ const (
 
 	// Protocol Version
 	PROTOCOL_VERSION13 = 13
+	PROTOCOL_VERSION14 = 14
 	PROTOCOL_VERSION16 = 16
 
 	CNCT_user              = 1
@@ -126,6 +127,13 @@ const (
 	isc_info_active_tran_count     = 110
 	isc_info_creation_date         = 111
 	isc_info_db_file_size          = 112
+	fb_info_crypt_state            = 134
+	fb_info_db_guid                = 144
+	fb_info_replica_mode           = 146
+
+	// fb_info_crypt_state flags
+	fb_info_crypt_encrypted = 0x01
+	fb_info_crypt_process   = 0x02
 
 	// isc_info_sql_records items
 	isc_info_req_select_count = 13
@@ -152,6 +160,7 @@ const (
 	isc_info_svc_limbo_trans        = 66
 	isc_info_svc_running            = 67
 	isc_info_svc_get_users          = 68
+	isc_info_svc_stdin              = 78
 
 	isc_tpb_version1         = 1
 	isc_tpb_version3         = 3
@@ -368,6 +377,13 @@ const (
 	isc_spb_trc_name = 2
 	isc_spb_trc_cfg  = 3
 
+	// Parameters for isc_action_svc_validate
+	isc_spb_val_tab_incl     = 1
+	isc_spb_val_tab_excl     = 2
+	isc_spb_val_idx_incl     = 3
+	isc_spb_val_idx_excl     = 4
+	isc_spb_val_lock_timeout = 5
+
 	// isc_info_svc_svr_db_info params
 	isc_spb_num_att = 5
 	isc_spb_num_db  = 6
@@ -0,0 +1,197 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// CreateOptions controls the attributes of a database created by
+// CreateDatabase or the firebirdsql_createdb driver. The zero value leaves
+// every choice to the server.
+type CreateOptions struct {
+	PageSize     int32  // 0 leaves the choice to the server
+	Charset      string // default character set, "" means the connection charset
+	Collation    string // default collation of Charset, "" keeps the charset default
+	ForcedWrites *bool  // nil keeps the server default (on)
+	Overwrite    *bool  // replace an existing file with the same name; nil keeps the server default (off)
+	SQLDialect   int32  // 0 means dialect 3
+	// Owner is the login creating the database in place of the user of the
+	// DSN, which makes it the owner. The password of the DSN must be its
+	// password.
+	Owner string
+}
+
+// GetDefaultCreateOptions returns the options the firebirdsql_createdb driver
+// uses when the DSN does not override them.
+func GetDefaultCreateOptions() CreateOptions {
+	forcedWrites, overwrite := true, true
+	return CreateOptions{
+		PageSize:     4096,
+		ForcedWrites: &forcedWrites,
+		Overwrite:    &overwrite,
+		SQLDialect:   3,
+	}
+}
+
+func createOptionsFromDsn(dsn *firebirdDsn) (CreateOptions, error) {
+	opts := GetDefaultCreateOptions()
+	if s := dsn.options["page_size"]; s != "" {
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return opts, fmt.Errorf("invalid page_size %q", s)
+		}
+		opts.PageSize = int32(v)
+	}
+	if s := dsn.options["sql_dialect"]; s != "" {
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return opts, fmt.Errorf("invalid sql_dialect %q", s)
+		}
+		opts.SQLDialect = int32(v)
+	}
+	opts.Charset = dsn.options["default_charset"]
+	opts.Collation = dsn.options["collation"]
+	forcedWrites := convertToBool(dsn.options["forced_writes"], *opts.ForcedWrites)
+	overwrite := convertToBool(dsn.options["overwrite"], *opts.Overwrite)
+	opts.ForcedWrites, opts.Overwrite = &forcedWrites, &overwrite
+	opts.Owner = dsn.options["owner"]
+	return opts, nil
+}
+
+// createDpb builds the database parameter buffer of op_create, without the
+// authentication and timezone items.
+func (opts CreateOptions) createDpb(charset, user, password, role string) []byte {
+	dbCharset := opts.Charset
+	if dbCharset == "" {
+		dbCharset = charset
+	}
+	sqlDialect := opts.SQLDialect
+	if sqlDialect == 0 {
+		sqlDialect = 3
+	}
+	dbCharsetBytes := []byte(dbCharset)
+	encode := []byte(charset)
+	userBytes := []byte(dpbUser(user))
+	passwordBytes := []byte(password)
+	roleBytes := []byte(role)
+	dpb := bytes.Join([][]byte{
+		[]byte{isc_dpb_version1},
+		[]byte{isc_dpb_set_db_charset, byte(len(dbCharsetBytes))}, dbCharsetBytes,
+		[]byte{isc_dpb_lc_ctype, byte(len(encode))}, encode,
+		[]byte{isc_dpb_user_name, byte(len(userBytes))}, userBytes,
+		[]byte{isc_dpb_password, byte(len(passwordBytes))}, passwordBytes,
+		[]byte{isc_dpb_sql_role_name, byte(len(roleBytes))}, roleBytes,
+		[]byte{isc_dpb_sql_dialect, 4}, int32_to_bytes(sqlDialect),
+	}, nil)
+	if opts.ForcedWrites != nil {
+		dpb = append(dpb, isc_dpb_force_write, 4)
+		dpb = append(dpb, bint32_to_bytes(boolToInt32(*opts.ForcedWrites))...)
+	}
+	if opts.Overwrite != nil {
+		dpb = append(dpb, isc_dpb_overwrite, 4)
+		dpb = append(dpb, bint32_to_bytes(boolToInt32(*opts.Overwrite))...)
+	}
+	if opts.PageSize > 0 {
+		dpb = append(dpb, isc_dpb_page_size, 4)
+		dpb = append(dpb, int32_to_bytes(opts.PageSize)...)
+	}
+	return append(dpb, isc_dpb_utf8_filename, 1, 1)
+}
+
+// collationStatement returns the statement setting the default collation
+// requested by opts, or "" if there is nothing to do.
+func (opts CreateOptions) collationStatement(charset string) string {
+	if opts.Collation == "" {
+		return ""
+	}
+	if opts.Charset != "" {
+		charset = opts.Charset
+	}
+	return fmt.Sprintf("ALTER CHARACTER SET %s SET DEFAULT COLLATION %s", charset, opts.Collation)
+}
+
+func createDatabaseConn(ctx context.Context, dsn *firebirdDsn, opts CreateOptions) (*firebirdsqlConn, error) {
+	dsn, err := dsn.resolveCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Owner != "" {
+		// The creating user owns the database.
+		ownerDsn := *dsn
+		ownerDsn.user = opts.Owner
+		dsn = &ownerDsn
+	}
+	fc, err := openFirebirdsqlConn(ctx, dsn, func(wp *wireProtocol, dsn *firebirdDsn) error {
+		return wp.opCreate(dsn.dbName, dsn.user, dsn.passwd, dsn.options["role"], opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stmt := opts.collationStatement(fc.wp.charset); stmt != "" {
+		if _, err = fc.exec(ctx, stmt, nil); err == nil {
+			err = fc.tx.Commit()
+		}
+		if err != nil {
+			fc.Close()
+			return nil, err
+		}
+	}
+	return fc, nil
+}
+
+// CreateDatabase creates the database described by cfg with opts.
+// If opts.Owner is set, it is used instead of cfg.User as the login creating
+// the database, which makes it the owner; cfg.Password must be its password.
+func CreateDatabase(ctx context.Context, cfg *Config, opts CreateOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fc, err := createDatabaseConn(ctx, cfg.firebirdDsn(), opts)
+	if err != nil {
+		return err
+	}
+	return fc.Close()
+}
+
+// DropDatabase deletes the database described by cfg. It fails if other
+// attachments to the database exist.
+func DropDatabase(ctx context.Context, cfg *Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fc, err := attachFirebirdsqlConn(ctx, cfg.firebirdDsn())
+	if err != nil {
+		return err
+	}
+	defer fc.wp.conn.Close()
+	if err = fc.wp.opDropDatabase(); err != nil {
+		return err
+	}
+	_, _, _, err = fc.wp.opResponse()
+	return err
+}
@@ -0,0 +1,136 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateOptionsFromDsn(t *testing.T) {
+	dsn, err := parseDSN("user:password@localhost/dbname")
+	require.NoError(t, err)
+	opts, err := createOptionsFromDsn(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, GetDefaultCreateOptions(), opts)
+
+	dsn, err = parseDSN("user:password@localhost/dbname?page_size=16384&default_charset=WIN1252&collation=PXW_INTL&forced_writes=false&overwrite=false&sql_dialect=1&owner=alice")
+	require.NoError(t, err)
+	opts, err = createOptionsFromDsn(dsn)
+	require.NoError(t, err)
+	off := false
+	assert.Equal(t, CreateOptions{
+		PageSize:     16384,
+		Charset:      "WIN1252",
+		Collation:    "PXW_INTL",
+		ForcedWrites: &off,
+		Overwrite:    &off,
+		SQLDialect:   1,
+		Owner:        "alice",
+	}, opts)
+
+	dsn, err = parseDSN("user:password@localhost/dbname?page_size=large")
+	require.NoError(t, err)
+	_, err = createOptionsFromDsn(dsn)
+	assert.Error(t, err)
+}
+
+func TestCreateDpb(t *testing.T) {
+	overwrite := true
+	dpb := CreateOptions{Charset: "WIN1252", Overwrite: &overwrite}.createDpb("UTF8", "sysdba", "pw", "")
+	pb := NewXPBReader(dpb)
+	_, v := pb.Next()
+	assert.Equal(t, byte(isc_dpb_version1), v)
+
+	items := map[byte][]byte{}
+	for {
+		have, item := pb.Next()
+		if !have {
+			break
+		}
+		_, ln := pb.Next()
+		data := dpb[pb.pos : pb.pos+int(ln)]
+		pb.Skip(int(ln))
+		items[item] = data
+	}
+	assert.Equal(t, []byte("WIN1252"), items[isc_dpb_set_db_charset])
+	assert.Equal(t, []byte("UTF8"), items[isc_dpb_lc_ctype])
+	assert.Equal(t, []byte("SYSDBA"), items[isc_dpb_user_name])
+	assert.Equal(t, int32_to_bytes(3), items[isc_dpb_sql_dialect])
+	assert.Equal(t, bint32_to_bytes(1), items[isc_dpb_overwrite])
+	_, ok := items[isc_dpb_page_size]
+	assert.False(t, ok, "page size must be left to the server")
+	_, ok = items[isc_dpb_force_write]
+	assert.False(t, ok, "forced writes must be left to the server")
+
+	assert.Equal(t, "", CreateOptions{}.collationStatement("UTF8"))
+	assert.Equal(t,
+		"ALTER CHARACTER SET UTF8 SET DEFAULT COLLATION UNICODE_CI",
+		CreateOptions{Collation: "UNICODE_CI"}.collationStatement("UTF8"))
+}
+
+func TestCreateDropDatabase(t *testing.T) {
+	ctx := context.Background()
+	cfg, err := ParseConfig(GetTestDSN("test_create_drop_"))
+	require.NoError(t, err)
+
+	forcedWrites := false
+	opts := CreateOptions{
+		PageSize:     8192,
+		Charset:      "UTF8",
+		Collation:    "UNICODE_CI",
+		ForcedWrites: &forcedWrites,
+		SQLDialect:   3,
+	}
+	require.NoError(t, CreateDatabase(ctx, cfg, opts))
+	assert.Error(t, CreateDatabase(ctx, cfg, opts), "database exists and Overwrite is the server default")
+
+	conn, err := sql.Open("firebirdsql", GetTestDSNFromDatabase(cfg.Database))
+	require.NoError(t, err)
+	c, err := conn.Conn(ctx)
+	require.NoError(t, err)
+	info, err := DatabaseInfo(ctx, c)
+	require.NoError(t, err)
+	assert.Equal(t, 8192, info.PageSize)
+	assert.False(t, info.ForcedWrites)
+
+	var collation string
+	err = c.QueryRowContext(ctx, `
+		SELECT TRIM(c.RDB$COLLATION_NAME)
+		FROM RDB$CHARACTER_SETS cs
+		JOIN RDB$COLLATIONS c ON c.RDB$CHARACTER_SET_ID = cs.RDB$CHARACTER_SET_ID
+			AND c.RDB$COLLATION_NAME = cs.RDB$DEFAULT_COLLATE_NAME
+		WHERE cs.RDB$CHARACTER_SET_NAME = 'UTF8'`).Scan(&collation)
+	require.NoError(t, err)
+	assert.Equal(t, "UNICODE_CI", collation)
+	c.Close()
+	conn.Close()
+
+	require.NoError(t, DropDatabase(ctx, cfg))
+	assert.Error(t, DropDatabase(ctx, cfg))
+}
@@ -0,0 +1,68 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// StaticCryptKey returns a key callback that answers every request of the
+// crypt plugin of the database with key. It suits plugins that ask for a
+// single key, such as the Firebird sample plugin and most commercial ones.
+func StaticCryptKey(key []byte) func(serverData []byte) ([]byte, error) {
+	return func(serverData []byte) ([]byte, error) {
+		return key, nil
+	}
+}
+
+// parseCryptKey parses the crypt_key parameter: a "base64:" prefix marks a
+// base64 encoded key, and other values are the key itself.
+func parseCryptKey(s string) ([]byte, error) {
+	if encoded, ok := strings.CutPrefix(s, "base64:"); ok {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid crypt_key %q", s)
+		}
+		return key, nil
+	}
+	return []byte(s), nil
+}
+
+// cryptKeyCallback returns the key callback of dsn: the one of its Config,
+// or a StaticCryptKey of the crypt_key parameter.
+func (dsn *firebirdDsn) cryptKeyCallback() (func(serverData []byte) ([]byte, error), error) {
+	if dsn.cryptKey != nil {
+		return dsn.cryptKey, nil
+	}
+	if dsn.options["crypt_key"] == "" {
+		return nil, nil
+	}
+	key, err := parseCryptKey(dsn.options["crypt_key"])
+	if err != nil {
+		return nil, err
+	}
+	return StaticCryptKey(key), nil
+}
@@ -0,0 +1,144 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestParseCryptKey(t *testing.T) {
+	var testKeys = []struct {
+		s   string
+		key []byte
+	}{
+		{"secret", []byte("secret")},
+		{"base64:AQIDBA==", []byte{1, 2, 3, 4}},
+		{"base64:", []byte{}},
+	}
+	for _, d := range testKeys {
+		key, err := parseCryptKey(d.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(key, d.key) {
+			t.Errorf("parseCryptKey fail:%s(%v != %v)", d.s, key, d.key)
+		}
+	}
+	if _, err := parseCryptKey("base64:???"); err == nil {
+		t.Fatalf("Error Not occured")
+	}
+
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if callback, _ := dsn.cryptKeyCallback(); callback != nil {
+		t.Fatalf("cryptKeyCallback fail")
+	}
+	cfg, _ := ParseConfig("user:password@localhost/dbname?crypt_key=base64:AQIDBA==")
+	callback, err := cfg.firebirdDsn().cryptKeyCallback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := callback([]byte("KeyName")); !bytes.Equal(key, []byte{1, 2, 3, 4}) {
+		t.Errorf("cryptKeyCallback fail:%v", key)
+	}
+	cfg.CryptKeyCallback = StaticCryptKey([]byte("other"))
+	callback, _ = cfg.firebirdDsn().cryptKeyCallback()
+	if key, _ := callback(nil); string(key) != "other" {
+		t.Errorf("cryptKeyCallback fail:%s", key)
+	}
+}
+
+func TestAnswerCryptCallback(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	wp, err := newWireProtocolConn(client, "localhost:3050", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wp.protocolVersion = PROTOCOL_VERSION16
+	var asked []byte
+	wp.cryptKeyCallback = func(serverData []byte) ([]byte, error) {
+		asked = serverData
+		return []byte("secret"), nil
+	}
+
+	chReply := make(chan []byte, 1)
+	go func() {
+		defer server.Close()
+		packet := bytes.Join([][]byte{
+			bint32_to_bytes(op_crypt_key_callback), xdrBytes([]byte("KeyName")), bint32_to_bytes(64),
+		}, nil)
+		server.Write(packet)
+		reply := make([]byte, 4+4+8+4)
+		if _, err := io.ReadFull(server, reply); err != nil {
+			chReply <- nil
+			return
+		}
+		chReply <- reply
+		// op_response with handle 7, no buffer and an empty status vector
+		server.Write(bytes.Join([][]byte{
+			bint32_to_bytes(op_response), bint32_to_bytes(7), make([]byte, 8), bint32_to_bytes(0), bint32_to_bytes(isc_arg_end),
+		}, nil))
+	}()
+
+	h, _, _, err := wp.opResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != 7 {
+		t.Errorf("opResponse fail:handle %d", h)
+	}
+	if string(asked) != "KeyName" {
+		t.Errorf("answerCryptCallback fail:%s", asked)
+	}
+	want := bytes.Join([][]byte{
+		bint32_to_bytes(op_crypt_key_callback), xdrBytes([]byte("secret")), bint32_to_bytes(int32(BUFFER_LEN)),
+	}, nil)
+	if reply := <-chReply; !bytes.Equal(reply, want) {
+		t.Errorf("answerCryptCallback fail:%v", reply)
+	}
+}
+
+func TestAnswerCryptCallbackError(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	wp, _ := newWireProtocolConn(client, "localhost:3050", "", "")
+	wp.protocolVersion = PROTOCOL_VERSION16
+	errNoKey := errors.New("no key")
+	wp.cryptKeyCallback = func(serverData []byte) ([]byte, error) {
+		return nil, errNoKey
+	}
+	go func() {
+		defer server.Close()
+		server.Write(bytes.Join([][]byte{
+			bint32_to_bytes(op_crypt_key_callback), xdrBytes(nil), bint32_to_bytes(64),
+		}, nil))
+	}()
+	if _, _, _, err := wp.opResponse(); !errors.Is(err, errNoKey) {
+		t.Fatalf("opResponse fail:%v", err)
+	}
+}
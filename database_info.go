@@ -0,0 +1,228 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// DbInfo holds the attributes of an attached database as reported by
+// op_info_database. Items the server does not know are left at their zero value.
+type DbInfo struct {
+	ODSMajor            int
+	ODSMinor            int
+	PageSize            int
+	Pages               int64 // pages allocated to the database
+	AttachmentID        int64 // of this connection, as CURRENT_CONNECTION; 64-bit on Firebird 4+
+	ImplementationCode  int
+	ImplementationClass int
+	ServerVersion       string // e.g. "LI-V3.0.10.33601 Firebird 3.0"
+	ForcedWrites        bool
+	SweepInterval       int64
+	ReadOnly            bool
+	NextTransaction     int64
+	OldestTransaction   int64 // oldest interesting transaction
+	OldestActive        int64
+	OldestSnapshot      int64
+	SQLDialect          int
+	CreationDate        time.Time
+	DatabaseGUID        string // Firebird 4+
+	ReplicaMode         ReplicaMode
+	Encrypted           bool // Firebird 3+
+	CryptInProgress     bool // Firebird 3+
+}
+
+// Version parses ServerVersion.
+func (info *DbInfo) Version() (FirebirdVersion, bool) {
+	if !FirebirdVersionPattern.MatchString(info.ServerVersion) {
+		return FirebirdVersion{}, false
+	}
+	return ParseFirebirdVersion(info.ServerVersion), true
+}
+
+func dbInfoItems(protocolVersion int32) []byte {
+	items := []byte{
+		isc_info_ods_version,
+		isc_info_ods_minor_version,
+		isc_info_page_size,
+		isc_info_allocation,
+		isc_info_attachment_id,
+		isc_info_implementation,
+		isc_info_firebird_version,
+		isc_info_forced_writes,
+		isc_info_sweep_interval,
+		isc_info_db_read_only,
+		isc_info_next_transaction,
+		isc_info_oldest_transaction,
+		isc_info_oldest_active,
+		isc_info_oldest_snapshot,
+		isc_info_db_sql_dialect,
+		isc_info_creation_date,
+	}
+	if protocolVersion >= PROTOCOL_VERSION13 {
+		items = append(items, fb_info_crypt_state)
+	}
+	if protocolVersion >= PROTOCOL_VERSION16 {
+		items = append(items, fb_info_db_guid, fb_info_replica_mode)
+	}
+	return append(items, isc_info_end)
+}
+
+// infoInt decodes a little-endian integer of 1, 2, 4 or 8 bytes. Database
+// info values are never negative, so counters like transaction numbers are
+// read as unsigned up to 4 bytes.
+func infoInt(b []byte) int64 {
+	switch len(b) {
+	case 1:
+		return int64(b[0])
+	case 2:
+		return int64(binary.LittleEndian.Uint16(b))
+	case 4:
+		return int64(binary.LittleEndian.Uint32(b))
+	case 8:
+		return bytes_to_int64(b)
+	}
+	return 0
+}
+
+// formatGUID renders a 16 byte GUID the way Firebird prints it.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8], b[9], b[10], b[11], b[12], b[13], b[14], b[15])
+}
+
+func parseDbInfo(buf []byte, timezone string) (*DbInfo, error) {
+	info := &DbInfo{}
+	pb := NewXPBReader(buf)
+	for {
+		have, item := pb.Next()
+		if !have || item == isc_info_end {
+			break
+		}
+		if item == isc_info_truncated {
+			return info, fmt.Errorf("database info buffer truncated")
+		}
+		if len(buf)-pb.pos < 2 {
+			return info, fmt.Errorf("invalid database info item %d", item)
+		}
+		ln := int(pb.GetInt16())
+		if ln < 0 || pb.pos+ln > len(buf) {
+			return info, fmt.Errorf("invalid database info item %d length %d", item, ln)
+		}
+		data := buf[pb.pos : pb.pos+ln]
+		pb.Skip(ln)
+
+		switch item {
+		case isc_info_ods_version:
+			info.ODSMajor = int(infoInt(data))
+		case isc_info_ods_minor_version:
+			info.ODSMinor = int(infoInt(data))
+		case isc_info_page_size:
+			info.PageSize = int(infoInt(data))
+		case isc_info_allocation:
+			info.Pages = infoInt(data)
+		case isc_info_attachment_id:
+			info.AttachmentID = infoInt(data)
+		case isc_info_implementation:
+			// count, then (implementation, class) pairs
+			if len(data) >= 3 {
+				info.ImplementationCode = int(data[1])
+				info.ImplementationClass = int(data[2])
+			}
+		case isc_info_firebird_version:
+			// count, then length-prefixed strings
+			if len(data) >= 2 && int(data[1])+2 <= len(data) {
+				info.ServerVersion = string(data[2 : 2+int(data[1])])
+			}
+		case isc_info_forced_writes:
+			info.ForcedWrites = infoInt(data) != 0
+		case isc_info_sweep_interval:
+			info.SweepInterval = infoInt(data)
+		case isc_info_db_read_only:
+			info.ReadOnly = infoInt(data) != 0
+		case isc_info_next_transaction:
+			info.NextTransaction = infoInt(data)
+		case isc_info_oldest_transaction:
+			info.OldestTransaction = infoInt(data)
+		case isc_info_oldest_active:
+			info.OldestActive = infoInt(data)
+		case isc_info_oldest_snapshot:
+			info.OldestSnapshot = infoInt(data)
+		case isc_info_db_sql_dialect:
+			info.SQLDialect = int(infoInt(data))
+		case isc_info_creation_date:
+			if len(data) == 8 {
+				// ISC_TIMESTAMP in little-endian; the xsqlvar decoders expect XDR order.
+				raw := append(bint32_to_bytes(bytes_to_int32(data[0:4])), bint32_to_bytes(bytes_to_int32(data[4:8]))...)
+				info.CreationDate = (&xSQLVAR{}).parseTimestamp(raw, timezone)
+			}
+		case fb_info_db_guid:
+			if len(data) == 16 {
+				info.DatabaseGUID = formatGUID(data)
+			}
+		case fb_info_replica_mode:
+			info.ReplicaMode = ReplicaMode(infoInt(data))
+		case fb_info_crypt_state:
+			state := infoInt(data)
+			info.Encrypted = state&fb_info_crypt_encrypted != 0
+			info.CryptInProgress = state&fb_info_crypt_process != 0
+		}
+	}
+	return info, nil
+}
+
+func (fc *firebirdsqlConn) databaseInfo() (*DbInfo, error) {
+	if err := fc.wp.opInfoDatabase(dbInfoItems(fc.wp.protocolVersion)); err != nil {
+		return nil, err
+	}
+	_, _, buf, err := fc.wp.opResponse()
+	if err != nil {
+		return nil, err
+	}
+	return parseDbInfo(buf, fc.wp.timezone)
+}
+
+// DatabaseInfo returns the attributes of the database conn is attached to.
+func DatabaseInfo(ctx context.Context, conn *sql.Conn) (*DbInfo, error) {
+	var info *DbInfo
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	err := conn.Raw(func(driverConn any) (err error) {
+		fc, ok := driverConn.(*firebirdsqlConn)
+		if !ok {
+			return ErrNotFirebirdConn
+		}
+		info, err = fc.databaseInfo()
+		return
+	})
+	return info, err
+}
@@ -0,0 +1,127 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDbInfo(t *testing.T) {
+	version := "LI-V4.0.2.2816 Firebird 4.0"
+	buf := []byte{
+		isc_info_ods_version, 1, 0, 13,
+		isc_info_ods_minor_version, 1, 0, 0,
+		isc_info_page_size, 2, 0, 0x00, 0x20,
+		isc_info_allocation, 4, 0, 0xc8, 0, 0, 0,
+		isc_info_attachment_id, 8, 0, 7, 0, 0, 0, 0, 0, 0, 0,
+		isc_info_implementation, 3, 0, 1, 81, 12,
+		isc_info_firebird_version, byte(len(version) + 2), 0, 1, byte(len(version)),
+	}
+	buf = append(buf, version...)
+	buf = append(buf,
+		isc_info_forced_writes, 1, 0, 1,
+		isc_info_sweep_interval, 4, 0, 0x50, 0xc3, 0, 0,
+		isc_info_db_read_only, 1, 0, 0,
+		isc_info_next_transaction, 4, 0, 0x00, 0x00, 0x00, 0x90,
+		isc_info_oldest_transaction, 4, 0, 5, 0, 0, 0,
+		isc_info_oldest_active, 4, 0, 6, 0, 0, 0,
+		isc_info_oldest_snapshot, 4, 0, 6, 0, 0, 0,
+		isc_info_db_sql_dialect, 1, 0, 3,
+		// 2024-01-02 03:04:05
+		isc_info_creation_date, 8, 0, 0x97, 0xeb, 0x00, 0x00, 0x50, 0x55, 0x95, 0x06,
+		fb_info_crypt_state, 1, 0, fb_info_crypt_encrypted,
+		fb_info_db_guid, 16, 0, 0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 1, 2, 3, 4, 5, 6, 7, 8,
+		fb_info_replica_mode, 1, 0, 1,
+		// unknown item reported by an older server
+		isc_info_error, 5, 0, 146, 0x14, 0, 0, 0,
+		isc_info_end,
+	)
+
+	info, err := parseDbInfo(buf, "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, 13, info.ODSMajor)
+	assert.Equal(t, 0, info.ODSMinor)
+	assert.Equal(t, 8192, info.PageSize)
+	assert.Equal(t, int64(200), info.Pages)
+	assert.Equal(t, int64(7), info.AttachmentID)
+	assert.Equal(t, 81, info.ImplementationCode)
+	assert.Equal(t, 12, info.ImplementationClass)
+	assert.Equal(t, version, info.ServerVersion)
+	assert.True(t, info.ForcedWrites)
+	assert.Equal(t, int64(50000), info.SweepInterval)
+	assert.False(t, info.ReadOnly)
+	assert.Equal(t, int64(0x90000000), info.NextTransaction)
+	assert.Equal(t, int64(5), info.OldestTransaction)
+	assert.Equal(t, int64(6), info.OldestActive)
+	assert.Equal(t, int64(6), info.OldestSnapshot)
+	assert.Equal(t, 3, info.SQLDialect)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), info.CreationDate)
+	assert.True(t, info.Encrypted)
+	assert.False(t, info.CryptInProgress)
+	assert.Equal(t, "{12345678-1234-5678-0102-030405060708}", info.DatabaseGUID)
+	assert.Equal(t, ReplicaModeReadOnly, info.ReplicaMode)
+
+	v, ok := info.Version()
+	require.True(t, ok)
+	assert.Equal(t, 4, v.Major)
+}
+
+func TestParseDbInfoTruncated(t *testing.T) {
+	_, err := parseDbInfo([]byte{isc_info_page_size, 2, 0, 0, 0x10, isc_info_truncated}, "")
+	assert.Error(t, err)
+	_, err = parseDbInfo([]byte{isc_info_page_size, 4, 0, 0}, "")
+	assert.Error(t, err)
+}
+
+func TestDatabaseInfo(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", GetTestDSN("test_database_info_"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	require.NoError(t, err)
+	defer c.Close()
+
+	info, err := DatabaseInfo(ctx, c)
+	require.NoError(t, err)
+	assert.Equal(t, 4096, info.PageSize)
+	assert.Equal(t, 3, info.SQLDialect)
+	assert.True(t, info.ForcedWrites)
+	assert.False(t, info.ReadOnly)
+	assert.Greater(t, info.ODSMajor, 10)
+	assert.Greater(t, info.Pages, int64(0))
+	var attachmentID int64
+	require.NoError(t, c.QueryRowContext(ctx, "SELECT CURRENT_CONNECTION FROM RDB$DATABASE").Scan(&attachmentID))
+	assert.Equal(t, attachmentID, info.AttachmentID)
+	assert.GreaterOrEqual(t, info.NextTransaction, info.OldestTransaction)
+	assert.NotEmpty(t, info.ServerVersion)
+	assert.False(t, info.CreationDate.IsZero())
+}
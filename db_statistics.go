@@ -0,0 +1,357 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bufio"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DbStatistics is the parsed output of gstat / isc_action_svc_db_stats.
+type DbStatistics struct {
+	Database string
+	Header   DbStatisticsHeader
+	Tables   []TableStatistics
+}
+
+// DbStatisticsHeader holds the header page information. Every line of the
+// header is also kept verbatim in Fields, keyed by its label.
+type DbStatisticsHeader struct {
+	Flags             int64
+	Generation        int64
+	PageSize          int
+	ODSMajor          int
+	ODSMinor          int
+	OldestTransaction int64
+	OldestActive      int64
+	OldestSnapshot    int64
+	NextTransaction   int64
+	NextAttachmentID  int64
+	ImplementationID  int    // Firebird 2.5
+	Implementation    string // Firebird 3+
+	ShadowCount       int
+	PageBuffers       int64
+	SQLDialect        int
+	CreationDate      string
+	Attributes        []string
+	SweepInterval     int64
+	DatabaseGUID      string // Firebird 4+
+	Fields            map[string]string
+}
+
+// FillDistribution counts pages by fill ratio: 0-19%, 20-39%, 40-59%, 60-79% and 80-99%.
+type FillDistribution [5]int64
+
+// TableStatistics holds the data page statistics of a table. Metrics the
+// parser does not know are kept in Other, keyed by their lower-cased label.
+type TableStatistics struct {
+	Name                  string
+	ID                    int
+	PrimaryPointerPage    int64
+	IndexRootPage         int64
+	TotalFormats          int64
+	UsedFormats           int64
+	AverageRecordLength   float64
+	TotalRecords          int64
+	AverageVersionLength  float64
+	TotalVersions         int64
+	MaxVersions           int64
+	AverageFragmentLength float64
+	TotalFragments        int64
+	MaxFragments          int64
+	AverageUnpackedLength float64
+	CompressionRatio      float64
+	PointerPages          int64
+	DataPageSlots         int64
+	DataPages             int64
+	AverageFill           float64 // percent
+	PrimaryPages          int64
+	SecondaryPages        int64
+	SweptPages            int64
+	EmptyPages            int64
+	FullPages             int64
+	Blobs                 int64
+	BlobsTotalLength      int64
+	BlobPages             int64
+	FillDistribution      FillDistribution
+	Indices               []IndexStatistics
+	Other                 map[string]string
+}
+
+// IndexStatistics holds the statistics of an index.
+type IndexStatistics struct {
+	Name                string
+	ID                  int
+	RootPage            int64
+	Depth               int64
+	LeafBuckets         int64
+	Nodes               int64
+	AverageNodeLength   float64
+	AverageDataLength   float64
+	TotalDup            int64
+	MaxDup              int64
+	AverageKeyLength    float64
+	CompressionRatio    float64
+	AveragePrefixLength float64
+	ClusteringFactor    float64
+	ClusteringRatio     float64
+	FillDistribution    FillDistribution
+	Other               map[string]string
+}
+
+var (
+	dbStatsDatabasePattern = regexp.MustCompile(`^Database "(.*)"`)
+	dbStatsHeaderPattern   = regexp.MustCompile(`^\s*([^\s:][^\t:]*?)(?::\s*|\t+| {2,})(\S.*?)\s*$`)
+	dbStatsTablePattern    = regexp.MustCompile(`^(\S.*?)\s+\((\d+)\)\s*$`)
+	dbStatsIndexPattern    = regexp.MustCompile(`^\s+Index\s+(.+?)\s+\((\d+)\)\s*$`)
+	dbStatsFillPattern     = regexp.MustCompile(`^\s*(\d+)\s*-\s*\d+\s*%\s*=\s*(\d+)\s*$`)
+)
+
+func (t *TableStatistics) metrics() map[string]any {
+	return map[string]any{
+		"primary pointer page":    &t.PrimaryPointerPage,
+		"index root page":         &t.IndexRootPage,
+		"total formats":           &t.TotalFormats,
+		"used formats":            &t.UsedFormats,
+		"average record length":   &t.AverageRecordLength,
+		"total records":           &t.TotalRecords,
+		"average version length":  &t.AverageVersionLength,
+		"total versions":          &t.TotalVersions,
+		"max versions":            &t.MaxVersions,
+		"average fragment length": &t.AverageFragmentLength,
+		"total fragments":         &t.TotalFragments,
+		"max fragments":           &t.MaxFragments,
+		"average unpacked length": &t.AverageUnpackedLength,
+		"compression ratio":       &t.CompressionRatio,
+		"pointer pages":           &t.PointerPages,
+		"data page slots":         &t.DataPageSlots,
+		"data pages":              &t.DataPages,
+		"average fill":            &t.AverageFill,
+		"primary pages":           &t.PrimaryPages,
+		"secondary pages":         &t.SecondaryPages,
+		"swept pages":             &t.SweptPages,
+		"empty pages":             &t.EmptyPages,
+		"full pages":              &t.FullPages,
+		"blobs":                   &t.Blobs,
+		"total length":            &t.BlobsTotalLength,
+		"blob pages":              &t.BlobPages,
+	}
+}
+
+func (idx *IndexStatistics) metrics() map[string]any {
+	return map[string]any{
+		"root page":             &idx.RootPage,
+		"depth":                 &idx.Depth,
+		"leaf buckets":          &idx.LeafBuckets,
+		"nodes":                 &idx.Nodes,
+		"average node length":   &idx.AverageNodeLength,
+		"average data length":   &idx.AverageDataLength,
+		"total dup":             &idx.TotalDup,
+		"max dup":               &idx.MaxDup,
+		"average key length":    &idx.AverageKeyLength,
+		"compression ratio":     &idx.CompressionRatio,
+		"average prefix length": &idx.AveragePrefixLength,
+		"clustering factor":     &idx.ClusteringFactor,
+		"ratio":                 &idx.ClusteringRatio,
+	}
+}
+
+// parseStatsMetrics reads a "Label: value, label: value" line into fields.
+// Unknown labels and unparsable values end up in other.
+func parseStatsMetrics(line string, fields map[string]any, other *map[string]string) {
+	for _, part := range strings.Split(strings.TrimSpace(line), ", ") {
+		k, v, ok := strings.Cut(part, ":")
+		v = strings.TrimSuffix(strings.TrimSpace(v), "%")
+		if !ok || v == "" {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		var err error
+		switch p := fields[k].(type) {
+		case *int64:
+			*p, err = strconv.ParseInt(v, 10, 64)
+		case *float64:
+			*p, err = strconv.ParseFloat(v, 64)
+		default:
+			err = errors.New("unknown metric")
+		}
+		if err != nil {
+			if *other == nil {
+				*other = make(map[string]string)
+			}
+			(*other)[k] = v
+		}
+	}
+}
+
+func (h *DbStatisticsHeader) set(key, value string) {
+	if h.Fields == nil {
+		h.Fields = make(map[string]string)
+	}
+	h.Fields[key] = value
+
+	atoi := func() int64 {
+		v, _ := strconv.ParseInt(value, 10, 64)
+		return v
+	}
+	switch strings.ToLower(key) {
+	case "flags":
+		h.Flags = atoi()
+	case "generation":
+		h.Generation = atoi()
+	case "page size":
+		h.PageSize = int(atoi())
+	case "ods version":
+		major, minor, _ := strings.Cut(value, ".")
+		h.ODSMajor, _ = strconv.Atoi(major)
+		h.ODSMinor, _ = strconv.Atoi(minor)
+	case "oldest transaction":
+		h.OldestTransaction = atoi()
+	case "oldest active":
+		h.OldestActive = atoi()
+	case "oldest snapshot":
+		h.OldestSnapshot = atoi()
+	case "next transaction":
+		h.NextTransaction = atoi()
+	case "next attachment id":
+		h.NextAttachmentID = atoi()
+	case "implementation id":
+		h.ImplementationID = int(atoi())
+	case "implementation":
+		h.Implementation = value
+	case "shadow count":
+		h.ShadowCount = int(atoi())
+	case "page buffers":
+		h.PageBuffers = atoi()
+	case "database dialect":
+		h.SQLDialect = int(atoi())
+	case "creation date":
+		h.CreationDate = value
+	case "attributes":
+		h.Attributes = strings.Split(value, ", ")
+	case "sweep interval":
+		h.SweepInterval = atoi()
+	case "database guid":
+		h.DatabaseGUID = value
+	}
+}
+
+// ParseDbStatistics parses gstat output as produced by Firebird 2.5 to 5.
+// Lines it does not understand are skipped.
+func ParseDbStatistics(s string) (*DbStatistics, error) {
+	stats := &DbStatistics{}
+	var (
+		inHeader, inTables, found bool
+		table                     *TableStatistics
+		index                     *IndexStatistics
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "Gstat "):
+			continue
+		case dbStatsDatabasePattern.MatchString(line):
+			stats.Database = dbStatsDatabasePattern.FindStringSubmatch(line)[1]
+			found = true
+			continue
+		case strings.HasPrefix(trimmed, "Database header page information"):
+			inHeader, inTables, found = true, false, true
+			continue
+		case strings.HasPrefix(trimmed, "Database file sequence"):
+			inHeader = false
+			continue
+		case strings.HasPrefix(trimmed, "Analyzing database pages"):
+			inHeader, inTables, found = false, true, true
+			continue
+		}
+
+		if inHeader {
+			if m := dbStatsHeaderPattern.FindStringSubmatch(line); m != nil {
+				stats.Header.set(strings.TrimSpace(m[1]), m[2])
+			}
+			continue
+		}
+		if !inTables {
+			continue
+		}
+
+		if m := dbStatsTablePattern.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[2])
+			stats.Tables = append(stats.Tables, TableStatistics{Name: m[1], ID: id})
+			table = &stats.Tables[len(stats.Tables)-1]
+			index = nil
+			continue
+		}
+		if table == nil {
+			continue
+		}
+		if m := dbStatsIndexPattern.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[2])
+			table.Indices = append(table.Indices, IndexStatistics{Name: m[1], ID: id})
+			index = &table.Indices[len(table.Indices)-1]
+			continue
+		}
+		if m := dbStatsFillPattern.FindStringSubmatch(line); m != nil {
+			from, _ := strconv.Atoi(m[1])
+			count, _ := strconv.ParseInt(m[2], 10, 64)
+			if bucket := from / 20; bucket < len(FillDistribution{}) {
+				if index != nil {
+					index.FillDistribution[bucket] = count
+				} else {
+					table.FillDistribution[bucket] = count
+				}
+			}
+			continue
+		}
+		if index != nil {
+			parseStatsMetrics(line, index.metrics(), &index.Other)
+		} else {
+			parseStatsMetrics(line, table.metrics(), &table.Other)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("no database statistics found")
+	}
+	return stats, nil
+}
+
+// GetDbStatsParsed runs gstat on database and returns the parsed result.
+func (svc *ServiceManager) GetDbStatsParsed(database string, options StatisticsOptions) (*DbStatistics, error) {
+	s, err := svc.GetDbStatsString(database, options)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDbStatistics(s)
+}
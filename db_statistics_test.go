@@ -0,0 +1,129 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func TestParseDbStatisticsGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "gstat", "*.txt"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			require.NoError(t, err)
+			stats, err := ParseDbStatistics(string(src))
+			require.NoError(t, err)
+			got, err := json.MarshalIndent(stats, "", "  ")
+			require.NoError(t, err)
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(input, ".txt") + ".golden.json"
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(golden, got, 0644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+func TestParseDbStatistics(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join("testdata", "gstat", "fb30.txt"))
+	require.NoError(t, err)
+	stats, err := ParseDbStatistics(string(src))
+	require.NoError(t, err)
+
+	assert.Equal(t, "/opt/firebird/examples/empbuild/employee.fdb", stats.Database)
+	assert.Equal(t, 8192, stats.Header.PageSize)
+	assert.Equal(t, 12, stats.Header.ODSMajor)
+	assert.Equal(t, int64(150), stats.Header.OldestTransaction)
+	assert.Equal(t, int64(152), stats.Header.NextTransaction)
+	assert.Equal(t, []string{"force write", "no reserve"}, stats.Header.Attributes)
+	assert.Equal(t, int64(20000), stats.Header.SweepInterval)
+
+	require.Len(t, stats.Tables, 2)
+	country := stats.Tables[0]
+	assert.Equal(t, "COUNTRY", country.Name)
+	assert.Equal(t, 128, country.ID)
+	assert.Equal(t, int64(14), country.TotalRecords)
+	assert.Equal(t, 8.0, country.AverageFill)
+	assert.Equal(t, FillDistribution{1, 0, 0, 0, 0}, country.FillDistribution)
+	require.Len(t, country.Indices, 1)
+	assert.Equal(t, "RDB$PRIMARY1", country.Indices[0].Name)
+	assert.Equal(t, int64(203), country.Indices[0].RootPage)
+	assert.Equal(t, 0.07, country.Indices[0].ClusteringRatio)
+	assert.Equal(t, 0.88, country.Indices[0].CompressionRatio)
+	assert.Equal(t, `"MIXED Case"`, stats.Tables[1].Name)
+
+	_, err = ParseDbStatistics("no statistics here")
+	assert.Error(t, err)
+}
+
+func TestServiceManager_GetDbStatsParsed(t *testing.T) {
+	dbPath := GetTestDatabase("test_dbstats_parsed_")
+	conn, err := sql.Open("firebirdsql_createdb", GetTestDSNFromDatabase(dbPath))
+	require.NoError(t, err, "sql.Open")
+	_, err = conn.Exec("CREATE TABLE test_stats (id INTEGER NOT NULL PRIMARY KEY, s VARCHAR(20))")
+	require.NoError(t, err)
+	_, err = conn.Exec("INSERT INTO test_stats VALUES (1, 'a')")
+	require.NoError(t, err)
+	conn.Close()
+
+	sm, err := NewServiceManager("localhost:3050", GetTestUser(), GetTestPassword(), GetDefaultServiceManagerOptions())
+	require.NoError(t, err, "NewServiceManager")
+	defer sm.Close()
+
+	stats, err := sm.GetDbStatsParsed(dbPath, GetDefaultStatisticsOptions())
+	require.NoError(t, err, "GetDbStatsParsed")
+	assert.Equal(t, 4096, stats.Header.PageSize)
+	assert.NotZero(t, stats.Header.ODSMajor)
+	assert.NotZero(t, stats.Header.NextTransaction)
+
+	var table *TableStatistics
+	for i := range stats.Tables {
+		if stats.Tables[i].Name == "TEST_STATS" {
+			table = &stats.Tables[i]
+		}
+	}
+	require.NotNil(t, table, "TEST_STATS statistics")
+	assert.Equal(t, int64(1), table.TotalRecords)
+	require.Len(t, table.Indices, 1)
+	assert.Equal(t, int64(1), table.Indices[0].Nodes)
+}
@@ -56,6 +56,16 @@ Windows database path:
 	db, err := sql.Open("firebirdsql", "sysdba:masterkey@localhost/C:/fbdata/mydb.fdb")
 
 See the README for the full list of optional query parameters (auth_plugin_name,
-charset, role, timezone, wire_crypt, wire_compress, column_name_to_lower).
+auth_plugin_list, autocommit, charset, crypt_key, role, timezone, wire_crypt,
+wire_crypt_plugins, wire_compress, column_name_to_lower, dial_timeout, aux_host,
+aux_port) and the parameters used by "firebirdsql_createdb" (page_size,
+default_charset, collation, forced_writes, overwrite, sql_dialect, owner).
+
+Use [ParseConfig] to parse a DSN, and [CreateDatabase] and [DropDatabase] to
+create or remove a database without going through database/sql.
+[NewConnector] opens a [Config] with [database/sql.OpenDB], which lets
+[Config.Dial] replace the dialer. [RegisterAuthPlugin] adds authentication
+plugins to those built in (Srp, Srp224, Srp256, Srp384, Srp512 and
+Legacy_Auth).
 */
 package firebirdsql
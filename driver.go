@@ -24,6 +24,7 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 )
@@ -35,7 +36,7 @@ func (d *firebirdsqlDriver) Open(dsns string) (driver.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return attachFirebirdsqlConn(dsn)
+	return attachFirebirdsqlConn(context.Background(), dsn)
 }
 
 type firebirdsqlCreateDbDriver struct{}
@@ -117,10 +117,16 @@ func (d *firebirdConnector) OpenConnector(dsns string) (driver.Connector, error)
 	return &firebirdConnector{dsn: dsn}, nil
 }
 
+// NewConnector returns a driver.Connector for cfg, to be passed to
+// sql.OpenDB. Unlike a DSN, cfg can carry a custom dialer.
+func NewConnector(cfg *Config) driver.Connector {
+	return &firebirdConnector{dsn: cfg.firebirdDsn()}
+}
+
 func (fc *firebirdConnector) Driver() driver.Driver {
 	return &firebirdsqlDriver{}
 }
 
 func (fc *firebirdConnector) Connect(ctx context.Context) (driver.Conn, error) {
-	return attachFirebirdsqlConn(fc.dsn)
+	return attachFirebirdsqlConn(ctx, fc.dsn)
 }
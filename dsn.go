@@ -24,9 +24,14 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type firebirdDsn struct {
@@ -35,17 +40,96 @@ type firebirdDsn struct {
 	user    string
 	passwd  string
 	options map[string]string
+
+	dial        func(ctx context.Context, network, addr string) (net.Conn, error)
+	dialTimeout time.Duration
+	auxHost     string
+	auxPorts    map[int]int
+	auxPortFunc func(port int) int
+	cryptKey    func(serverData []byte) ([]byte, error)
+	credentials func(ctx context.Context) (user string, password string, err error)
+}
+
+// Config holds the parameters of a connection string.
+type Config struct {
+	Addr     string            // host[:port], port defaults to 3050
+	Database string            // database path or alias
+	User     string            // login user
+	Password string            // login password
+	Params   map[string]string // optional parameters, see the README
+
+	// AuthPlugins lists the authentication plugins the server may choose
+	// from, most preferred first; the first one also replaces
+	// auth_plugin_name. The auth_plugin_list parameter is used if empty.
+	// Plugins other than Srp, Srp224, Srp256, Srp384, Srp512 and Legacy_Auth
+	// must be registered with RegisterAuthPlugin.
+	AuthPlugins []string
+
+	// CryptKeyCallback answers the crypt plugin of an encrypted database
+	// when it asks the client for the key (op_crypt_key_callback) on
+	// attach. The crypt_key parameter is used if nil; see StaticCryptKey.
+	CryptKeyCallback func(serverData []byte) ([]byte, error)
+
+	// CredentialsProvider returns the user and the password of each new
+	// connection, including the attachments of events, in place of User
+	// and Password. It lets credentials rotate without reopening sql.DB.
+	CredentialsProvider func(ctx context.Context) (user string, password string, err error)
+
+	// Dial opens the connections to the server, including the auxiliary
+	// connection of events. net.Dialer is used if nil.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// DialTimeout limits each dial; the dial_timeout parameter is used if 0.
+	DialTimeout time.Duration
+
+	// AuxHost replaces the host the server reports for the auxiliary
+	// connection of events; the aux_host parameter is used if empty.
+	AuxHost string
+	// AuxPorts maps the port the server reports for the auxiliary
+	// connection to the port to dial, as with NAT or container port
+	// mapping. Ports not in the map are dialed as reported, or replaced by
+	// the aux_port parameter.
+	AuxPorts map[int]int
+	// AuxPortFunc maps the reported port like AuxPorts, and takes
+	// precedence over it.
+	AuxPortFunc func(port int) int
 }
 
 var ErrDsnUserUnknown = errors.New("User unknown")
 
+var defaultDsnOptions = map[string]string{
+	"auth_plugin_name":     "Srp256",
+	"auth_plugin_list":     "Srp256,Srp512,Srp384,Srp224,Srp,Legacy_Auth",
+	"autocommit":           "true",
+	"charset":              "UTF8",
+	"column_name_to_lower": "false",
+	"role":                 "",
+	"timezone":             "",
+	"wire_crypt":           "true",
+	"wire_crypt_plugins":   "ChaCha64,ChaCha,Arc4",
+	"wire_compress":        "false",
+	"crypt_key":            "",
+	// used by the firebirdsql_createdb driver only
+	"page_size":       "4096",
+	"default_charset": "",
+	"collation":       "",
+	"forced_writes":   "true",
+	"overwrite":       "true",
+	"sql_dialect":     "3",
+	"owner":           "",
+	// event connections
+	"aux_host":     "",
+	"aux_port":     "",
+	"dial_timeout": "",
+}
+
 func newFirebirdDsn() *firebirdDsn {
 	return &firebirdDsn{options: make(map[string]string)}
 }
 
-func parseDSN(dsns string) (*firebirdDsn, error) {
-
-	dsn := newFirebirdDsn()
+// ParseConfig parses a connection string
+// user:password@host[:port]/database[?param=value&...] into a Config.
+func ParseConfig(dsns string) (*Config, error) {
+	cfg := &Config{Params: make(map[string]string)}
 
 	if !strings.HasPrefix(dsns, "firebird://") {
 		dsns = "firebird://" + dsns
@@ -57,42 +141,132 @@ func parseDSN(dsns string) (*firebirdDsn, error) {
 	if u.User == nil {
 		return nil, ErrDsnUserUnknown
 	}
-	dsn.user = u.User.Username()
-	dsn.passwd, _ = u.User.Password()
-	dsn.addr = u.Host
-	if !strings.ContainsRune(dsn.addr, ':') {
-		dsn.addr += ":3050"
+	cfg.User = u.User.Username()
+	cfg.Password, _ = u.User.Password()
+	cfg.Addr = u.Host
+	if !strings.ContainsRune(cfg.Addr, ':') {
+		cfg.Addr += ":3050"
 	}
-	dsn.dbName = u.Path
-	if !strings.ContainsRune(dsn.dbName[1:], '/') {
-		dsn.dbName = dsn.dbName[1:]
+	cfg.Database = u.Path
+	if !strings.ContainsRune(cfg.Database[1:], '/') {
+		cfg.Database = cfg.Database[1:]
 	}
 
 	//Windows Path
-	if strings.ContainsRune(dsn.dbName[2:], ':') {
-		dsn.dbName = dsn.dbName[1:]
+	if strings.ContainsRune(cfg.Database[2:], ':') {
+		cfg.Database = cfg.Database[1:]
 	}
 
 	m, _ := url.ParseQuery(u.RawQuery)
+	for k, values := range m {
+		cfg.Params[k] = values[0]
+	}
+
+	return cfg, nil
+}
 
-	var default_options = map[string]string{
-		"auth_plugin_name":     "Srp256",
-		"charset":              "UTF8",
-		"column_name_to_lower": "false",
-		"role":                 "",
-		"timezone":             "",
-		"wire_crypt":           "true",
-		"wire_compress":        "false",
+func (cfg *Config) firebirdDsn() *firebirdDsn {
+	dsn := newFirebirdDsn()
+	dsn.addr = cfg.Addr
+	if !strings.ContainsRune(dsn.addr, ':') {
+		dsn.addr += ":3050"
 	}
+	dsn.dbName = cfg.Database
+	dsn.user = cfg.User
+	dsn.passwd = cfg.Password
+	dsn.dial = cfg.Dial
+	dsn.dialTimeout = cfg.DialTimeout
+	dsn.auxHost = cfg.AuxHost
+	dsn.auxPorts = cfg.AuxPorts
+	dsn.auxPortFunc = cfg.AuxPortFunc
+	dsn.cryptKey = cfg.CryptKeyCallback
+	dsn.credentials = cfg.CredentialsProvider
 
-	for k, v := range default_options {
-		values, ok := m[k]
-		if ok {
-			dsn.options[k] = values[0]
+	for k, v := range defaultDsnOptions {
+		if value, ok := cfg.Params[k]; ok {
+			dsn.options[k] = value
 		} else {
 			dsn.options[k] = v
 		}
 	}
+	if len(cfg.AuthPlugins) > 0 {
+		dsn.options["auth_plugin_name"] = cfg.AuthPlugins[0]
+		dsn.options["auth_plugin_list"] = strings.Join(cfg.AuthPlugins, ",")
+	}
+	return dsn
+}
 
-	return dsn, nil
+func parseDSN(dsns string) (*firebirdDsn, error) {
+	cfg, err := ParseConfig(dsns)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.firebirdDsn(), nil
+}
+
+// resolveCredentials returns dsn with the user and the password of its
+// credentials provider, if it has one.
+func (dsn *firebirdDsn) resolveCredentials(ctx context.Context) (*firebirdDsn, error) {
+	if dsn.credentials == nil {
+		return dsn, nil
+	}
+	user, password, err := dsn.credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("credentials provider: %w", err)
+	}
+	resolved := *dsn
+	resolved.user, resolved.passwd = user, password
+	resolved.credentials = nil
+	return &resolved, nil
+}
+
+// dialContext connects to addr with the dialer and the dial timeout of dsn.
+func (dsn *firebirdDsn) dialContext(ctx context.Context, addr string) (net.Conn, error) {
+	timeout := dsn.dialTimeout
+	if timeout == 0 && dsn.options["dial_timeout"] != "" {
+		var err error
+		if timeout, err = time.ParseDuration(dsn.options["dial_timeout"]); err != nil {
+			return nil, fmt.Errorf("invalid dial_timeout %q", dsn.options["dial_timeout"])
+		}
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	dial := dsn.dial
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	return dial(ctx, "tcp", addr)
+}
+
+// auxAddr returns the address to dial for the auxiliary connection the
+// server reported at host and port. An empty host is the host of the
+// primary connection.
+func (dsn *firebirdDsn) auxAddr(host string, port int) (string, error) {
+	if dsn.auxHost != "" {
+		host = dsn.auxHost
+	} else if dsn.options["aux_host"] != "" {
+		host = dsn.options["aux_host"]
+	}
+	if host == "" {
+		var err error
+		if host, _, err = net.SplitHostPort(dsn.addr); err != nil {
+			return "", err
+		}
+	}
+	if dsn.auxPortFunc != nil {
+		port = dsn.auxPortFunc(port)
+	} else if p, ok := dsn.auxPorts[port]; ok {
+		port = p
+	} else if dsn.options["aux_port"] != "" {
+		p, err := strconv.Atoi(dsn.options["aux_port"])
+		if err != nil || p <= 0 || p > 65535 {
+			return "", fmt.Errorf("invalid aux_port %q", dsn.options["aux_port"])
+		}
+		port = p
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
 }
@@ -26,11 +26,13 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // Errors
@@ -41,11 +43,15 @@ var (
 
 // SQLs
 const (
-	sqlPostEvent = `execute block as begin post_event '%s'; end`
+	sqlPostEvent = `execute block as begin post_event %s; end`
 )
 
 // FbEvent allows you to subscribe to events, also stores subscribers.
 // It is possible to send events to the database.
+//
+// All subscriptions share one attachment and one auxiliary connection; the
+// event names are queued in groups of at most 15, and the counts are passed
+// to every subscription interested in a name.
 type FbEvent struct {
 	mu               sync.RWMutex
 	dsn              *firebirdDsn
@@ -55,6 +61,17 @@ type FbEvent struct {
 	closer           sync.Once
 	chDoneSubscriber chan *Subscription
 	subscribers      []*Subscription
+	options          EventOptions
+
+	// the shared attachment, guarded by muConn
+	muConn    sync.Mutex
+	fc        *firebirdsqlConn
+	manager   *eventManager
+	auxHandle int32
+	lastID    int32
+	queues    map[int32]*remoteEvent // queued groups of names by their current id
+	lost      []*remoteEvent         // groups to queue again after a reconnect
+	refs      map[string]int         // subscriptions per event name
 }
 
 // Event stores event data: the amount since the last time the event was received and id
@@ -63,49 +80,380 @@ type Event struct {
 	Count    int
 	ID       int32
 	RemoteID int32
+	// Reconnected is set on the event sent after a subscription has
+	// reconnected; Name is empty, and events posted while the connection
+	// was down may have been missed if the server restarted.
+	Reconnected bool
 }
 
 // EventHandler callback function type
 type EventHandler func(e Event)
 
+// EventOptions controls how subscriptions recover from a lost connection.
+type EventOptions struct {
+	Reconnect         bool
+	ReconnectDelay    time.Duration // delay before the first attempt, doubled after each failure
+	ReconnectMaxDelay time.Duration
+	ReconnectAttempts int // 0 retries until FbEvent is closed
+}
+
+type EventOption func(*EventOptions)
+
+func GetDefaultEventOptions() EventOptions {
+	return EventOptions{
+		Reconnect:         false,
+		ReconnectDelay:    time.Second,
+		ReconnectMaxDelay: 30 * time.Second,
+		ReconnectAttempts: 0,
+	}
+}
+
+// WithReconnect makes subscriptions reattach when their connection drops,
+// waiting delay, then twice as long after each failure up to maxDelay, and
+// giving up after attempts failures (0 never gives up).
+func WithReconnect(delay time.Duration, maxDelay time.Duration, attempts int) EventOption {
+	return func(opts *EventOptions) {
+		opts.Reconnect = true
+		opts.ReconnectDelay = delay
+		opts.ReconnectMaxDelay = maxDelay
+		opts.ReconnectAttempts = attempts
+	}
+}
+
+func WithoutReconnect() EventOption {
+	return func(opts *EventOptions) {
+		opts.Reconnect = false
+	}
+}
+
+func NewEventOptions(opts ...EventOption) EventOptions {
+	res := GetDefaultEventOptions()
+	for _, opt := range opts {
+		opt(&res)
+	}
+	return res
+}
+
 // NewFBEvent returns FbEvent for event subscription
 func NewFBEvent(dsns string) (*FbEvent, error) {
+	return NewFBEventWithOptions(dsns, GetDefaultEventOptions())
+}
+
+// NewFBEventWithOptions is like NewFBEvent, with options applied to its
+// subscriptions.
+func NewFBEventWithOptions(dsns string, options EventOptions) (*FbEvent, error) {
 	conn, err := sql.Open("firebirdsql", dsns)
 	if err != nil {
 		return nil, err
 	}
 	// can ignore error, would have been thrown by sql.Open
 	dsn, _ := parseDSN(dsns)
+	return newFBEvent(dsn, conn, options), nil
+}
+
+// NewFBEventFromConfig is like NewFBEventWithOptions, for the database
+// described by cfg. The dialer and the auxiliary address settings of cfg
+// apply to the event connections.
+func NewFBEventFromConfig(cfg *Config, options EventOptions) (*FbEvent, error) {
+	dsn := cfg.firebirdDsn()
+	conn := sql.OpenDB(&firebirdConnector{dsn: dsn})
+	return newFBEvent(dsn, conn, options), nil
+}
+
+func newFBEvent(dsn *firebirdDsn, conn *sql.DB, options EventOptions) *FbEvent {
 	fbEvent := &FbEvent{
 		dsn:              dsn,
 		conn:             conn,
 		done:             make(chan struct{}),
 		chDoneSubscriber: make(chan *Subscription),
+		options:          options,
+		queues:           make(map[int32]*remoteEvent),
+		refs:             make(map[string]int),
 	}
 	go fbEvent.run()
-	return fbEvent, nil
+	return fbEvent
 }
 
 // PostEvent posts an event to the database
 func (e *FbEvent) PostEvent(name string) error {
-	_, err := e.conn.Exec(fmt.Sprintf(sqlPostEvent, name))
+	_, err := e.conn.Exec(postEventStatement(name))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// PostEventTx posts an event in tx. Firebird delivers it when tx commits,
+// and not at all if it rolls back.
+func PostEventTx(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, postEventStatement(name))
+	return err
+}
+
+func postEventStatement(name string) string {
+	return fmt.Sprintf(sqlPostEvent, QuoteString(name))
+}
+
 func (e *FbEvent) newSubscriber(events []string, cb EventHandler, chEvent chan Event) (*Subscription, error) {
-	subscriber, err := newSubscription(e.dsn, events, cb, chEvent, e.chDoneSubscriber)
+	if e.IsClosed() {
+		return nil, ErrFbEventClosed
+	}
+	subscriber, err := newSubscription(e, events, cb, chEvent)
 	if err != nil {
 		return nil, err
 	}
+
+	e.muConn.Lock()
+	defer e.muConn.Unlock()
+	if e.fc == nil {
+		if err = e.attach(); err != nil {
+			subscriber.closeNoNotify(err)
+			return nil, err
+		}
+	}
+	// add the subscriber first, the counts may arrive before queueing returns
 	e.mu.Lock()
-	defer e.mu.Unlock()
 	e.subscribers = append(e.subscribers, subscriber)
+	e.mu.Unlock()
+
+	var added []string
+	for _, name := range subscriber.events {
+		if e.refs[name] == 0 {
+			added = append(added, name)
+		}
+		e.refs[name]++
+	}
+	for len(added) > 0 {
+		n := min(len(added), maxEventsPerEpb)
+		group := newRemoteEvent()
+		if err = group.queueEvents(added[:n]...); err == nil {
+			err = e.queue(group)
+		}
+		if err != nil {
+			e.release(subscriber)
+			e.mu.Lock()
+			e.removeSubscriber(subscriber)
+			e.mu.Unlock()
+			// muConn is held and the names are released already, so close
+			// without going through Unsubscribe
+			subscriber.closeNoNotify(err)
+			return nil, err
+		}
+		added = added[n:]
+	}
 	return subscriber, nil
 }
 
+// attach opens the shared attachment and its auxiliary connection, and
+// queues the groups of names kept from a lost connection.
+func (e *FbEvent) attach() error {
+	fc, err := attachFirebirdsqlConn(context.Background(), e.dsn)
+	if err != nil {
+		return err
+	}
+	auxHandle, address, err := connAuxRequest(fc)
+	if err != nil {
+		fc.Close()
+		return err
+	}
+	manager, err := newEventManager(e.dsn, address, auxHandle)
+	if err != nil {
+		fc.Close()
+		return err
+	}
+	e.fc, e.manager, e.auxHandle = fc, manager, auxHandle
+	chErr := manager.wait(e.dispatch)
+	go e.watch(manager, chErr)
+
+	lost := e.lost
+	e.lost = nil
+	for _, group := range lost {
+		if !e.referenced(group) {
+			continue
+		}
+		if err = e.queue(group); err != nil {
+			e.lost = lost
+			e.detach()
+			return err
+		}
+	}
+	return nil
+}
+
+// detach closes the shared attachment; queued groups are kept in lost.
+func (e *FbEvent) detach() {
+	if e.fc == nil {
+		return
+	}
+	for _, group := range e.queues {
+		e.lost = append(e.lost, group)
+	}
+	e.queues = make(map[int32]*remoteEvent)
+	manager, fc := e.manager, e.fc
+	e.manager, e.fc = nil, nil
+	manager.close()
+	fc.Close()
+}
+
+// queue queues group on the shared attachment under a new id.
+func (e *FbEvent) queue(group *remoteEvent) error {
+	e.lastID++
+	id := e.lastID
+	if err := e.fc.wp.opQueEvents(e.auxHandle, group.buildEpb(), id); err != nil {
+		return err
+	}
+	rid, _, _, err := e.fc.wp.opResponse()
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&group.id, id)
+	atomic.StoreInt32(&group.rid, rid)
+	e.queues[id] = group
+	return nil
+}
+
+func (e *FbEvent) referenced(group *remoteEvent) bool {
+	for _, name := range group.events {
+		if e.refs[name] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// release drops the names of subscriber and cancels the groups no
+// subscription is interested in anymore.
+func (e *FbEvent) release(subscriber *Subscription) (err error) {
+	for _, name := range subscriber.events {
+		if e.refs[name]--; e.refs[name] <= 0 {
+			delete(e.refs, name)
+		}
+	}
+	for id, group := range e.queues {
+		if e.referenced(group) {
+			continue
+		}
+		delete(e.queues, id)
+		if cerr := e.fc.wp.opCancelEvents(id); cerr == nil {
+			_, _, _, cerr = e.fc.wp.opResponse()
+			err = errors.Join(err, cerr)
+		} else {
+			err = errors.Join(err, cerr)
+		}
+	}
+	if len(e.refs) == 0 {
+		e.lost = nil
+		e.detach()
+	}
+	return err
+}
+
+// dispatch is called by the event manager with the counts of the group
+// queued under id; the group is queued again and the counts are passed on.
+func (e *FbEvent) dispatch(id int32, buffer []byte) {
+	e.muConn.Lock()
+	group := e.queues[id]
+	if group == nil {
+		// cancelled meanwhile
+		e.muConn.Unlock()
+		return
+	}
+	delete(e.queues, id)
+	events := group.getEventCounts(buffer)
+	if e.referenced(group) {
+		// a failure here also breaks the auxiliary connection, see watch
+		e.queue(group)
+	}
+	e.muConn.Unlock()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, ev := range events {
+		for _, subscriber := range e.subscribers {
+			if subscriber.names[ev.Name] {
+				subscriber.push(ev)
+			}
+		}
+	}
+}
+
+// watch handles the end of the auxiliary connection of manager.
+func (e *FbEvent) watch(manager *eventManager, chErr <-chan error) {
+	err := <-chErr
+	e.muConn.Lock()
+	if e.manager != manager {
+		// closed on purpose
+		e.muConn.Unlock()
+		return
+	}
+	e.detach()
+	e.muConn.Unlock()
+
+	if e.options.Reconnect {
+		err = e.reconnect(err)
+	}
+	if err != nil {
+		e.failSubscribers(err)
+	}
+}
+
+// reconnect attaches again with backoff and queues the names with the
+// counts seen so far, so events posted meanwhile are delivered as usual
+// unless the server restarted. It returns the last error if it gives up.
+func (e *FbEvent) reconnect(err error) error {
+	delay := e.options.ReconnectDelay
+	for attempt := 1; e.options.ReconnectAttempts == 0 || attempt <= e.options.ReconnectAttempts; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-e.done:
+			return nil
+		}
+		delay *= 2
+		if delay > e.options.ReconnectMaxDelay {
+			delay = e.options.ReconnectMaxDelay
+		}
+
+		e.muConn.Lock()
+		if e.fc == nil && len(e.refs) > 0 {
+			err = e.attach()
+		} else {
+			// a new subscription attached already, or there is no one left
+			err = nil
+		}
+		e.muConn.Unlock()
+		if err == nil {
+			e.mu.RLock()
+			for _, subscriber := range e.subscribers {
+				subscriber.push(Event{Reconnected: true})
+			}
+			e.mu.RUnlock()
+			return nil
+		}
+	}
+	return err
+}
+
+// failSubscribers closes all subscriptions after the connection was lost.
+func (e *FbEvent) failSubscribers(err error) {
+	e.muConn.Lock()
+	e.lost = nil
+	e.refs = make(map[string]int)
+	e.muConn.Unlock()
+
+	e.mu.RLock()
+	subscribers := append([]*Subscription(nil), e.subscribers...)
+	e.mu.RUnlock()
+	for _, subscriber := range subscribers {
+		subscriber.closeWithError(err)
+	}
+}
+
+func (e *FbEvent) unsubscribe(subscriber *Subscription) error {
+	e.muConn.Lock()
+	defer e.muConn.Unlock()
+	return e.release(subscriber)
+}
+
 // Subscribers returns slice of all subscribers
 func (e *FbEvent) Subscribers() []*Subscription {
 	e.mu.RLock()
@@ -144,6 +492,10 @@ func (e *FbEvent) run() {
 func (e *FbEvent) shutdownSubscriber(subscriber *Subscription) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	e.removeSubscriber(subscriber)
+}
+
+func (e *FbEvent) removeSubscriber(subscriber *Subscription) {
 	for i := range e.subscribers {
 		if e.subscribers[i] == subscriber {
 			last := len(e.subscribers) - 1
@@ -193,6 +545,10 @@ func (e *FbEvent) doClose(err error) (errResult error) {
 		e.subscribers = nil
 		e.mu.Unlock()
 		wg.Wait()
+		e.muConn.Lock()
+		e.lost = nil
+		e.detach()
+		e.muConn.Unlock()
 		errResult = errors.Join(errs...)
 		close(e.done)
 	})
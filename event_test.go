@@ -27,6 +27,7 @@ import (
 	"database/sql"
 	"math/rand"
 	"net"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -247,3 +248,251 @@ func TestSubscribe(t *testing.T) {
 		t.Errorf("expected closed subscriber")
 	}
 }
+
+func TestEventOptions(t *testing.T) {
+	opts := NewEventOptions()
+	if opts.Reconnect {
+		t.Error("reconnect is enabled by default")
+	}
+	opts = NewEventOptions(WithReconnect(time.Millisecond, time.Second, 3))
+	if !opts.Reconnect || opts.ReconnectDelay != time.Millisecond || opts.ReconnectMaxDelay != time.Second || opts.ReconnectAttempts != 3 {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	opts = NewEventOptions(WithReconnect(time.Millisecond, time.Second, 3), WithoutReconnect())
+	if opts.Reconnect {
+		t.Error("expected reconnect disabled")
+	}
+}
+
+func TestRemoteEventCountsReset(t *testing.T) {
+	e := newRemoteEvent()
+	if err := e.queueEvents("a"); err != nil {
+		t.Fatal(err)
+	}
+	counts := func(n int32) []byte {
+		return append([]byte{EPB_version1, 1, 'a'}, int32_to_bytes(n+1)...)
+	}
+	if got := e.getEventCounts(counts(5)); len(got) != 1 || got[0].Count != 5 {
+		t.Fatalf("unexpected events %v", got)
+	}
+	// the server restarted: counts start from zero
+	if got := e.getEventCounts(counts(2)); len(got) != 1 || got[0].Count != 2 {
+		t.Fatalf("unexpected events %v", got)
+	}
+}
+
+func TestSubscriptionReconnect(t *testing.T) {
+	dsn := GetTestDSN("test_event_reconnect_")
+	conn, err := sql.Open("firebirdsql_createdb", dsn)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	conn.Ping()
+	conn.Close()
+
+	fbevent, err := NewFBEventWithOptions(dsn, NewEventOptions(WithReconnect(10*time.Millisecond, 100*time.Millisecond, 10)))
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer fbevent.Close()
+
+	chEvent := make(chan Event, 10)
+	subscription, err := fbevent.SubscribeChan([]string{"event_reconnect"}, chEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	// drop the connection events arrive on
+	fbevent.manager.wp.conn.Close()
+	select {
+	case e := <-chEvent:
+		if !e.Reconnected {
+			t.Fatalf("expected reconnected event, got %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnected event")
+	}
+
+	if err := fbevent.PostEvent("event_reconnect"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-chEvent:
+		if e.Name != "event_reconnect" || e.Count != 1 {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after reconnect")
+	}
+}
+
+func TestEventDispatch(t *testing.T) {
+	e := &FbEvent{
+		chDoneSubscriber: make(chan *Subscription),
+		queues:           make(map[int32]*remoteEvent),
+		refs:             make(map[string]int),
+	}
+	group := newRemoteEvent()
+	if err := group.queueEvents("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	e.queues[7] = group
+
+	chA := make(chan Event, 10)
+	chAB := make(chan Event, 10)
+	subA, err := newSubscription(e, []string{"a", "a"}, nil, chA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subA.unsubscribeNoNotify()
+	subAB, err := newSubscription(e, []string{"a", "b"}, nil, chAB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subAB.unsubscribeNoNotify()
+	e.subscribers = []*Subscription{subA, subAB}
+	if len(subA.events) != 1 {
+		t.Errorf("expected duplicate names removed, got %v", subA.events)
+	}
+
+	buffer := []byte{EPB_version1}
+	buffer = append(append(buffer, 1, 'a'), int32_to_bytes(3)...)
+	buffer = append(append(buffer, 1, 'b'), int32_to_bytes(2)...)
+	e.dispatch(7, buffer)
+	e.dispatch(8, buffer) // unknown id
+
+	got := func(ch chan Event) map[string]int {
+		counts := map[string]int{}
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case ev := <-ch:
+				counts[ev.Name] += ev.Count
+			case <-timeout:
+				return counts
+			}
+		}
+	}
+	if counts := got(chA); counts["a"] != 2 || len(counts) != 1 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+	if counts := got(chAB); counts["a"] != 2 || counts["b"] != 1 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+}
+
+func TestSubscribeQueueError(t *testing.T) {
+	client, server := net.Pipe()
+	// the server reads op_que_events and drops the connection
+	go func() {
+		server.Read(make([]byte, 1024))
+		server.Close()
+	}()
+	wp, _ := newWireProtocolConn(client, "localhost:3050", "", "")
+	auxClient, auxServer := net.Pipe()
+	defer auxServer.Close()
+	auxWp, _ := newWireProtocolConn(auxClient, "localhost:3050", "", "")
+
+	e := &FbEvent{
+		done:             make(chan struct{}),
+		chDoneSubscriber: make(chan *Subscription),
+		queues:           make(map[int32]*remoteEvent),
+		refs:             make(map[string]int),
+		fc:               &firebirdsqlConn{wp: wp},
+		manager:          &eventManager{wp: auxWp},
+	}
+	chErr := make(chan error, 1)
+	go func() {
+		_, err := e.Subscribe([]string{"a"}, func(Event) {})
+		chErr <- err
+	}()
+	select {
+	case err := <-chErr:
+		if err == nil {
+			t.Fatal("expected an error from queueing")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe did not return")
+	}
+	if !e.muConn.TryLock() {
+		t.Fatal("muConn is still held")
+	}
+	e.muConn.Unlock()
+	if e.Count() != 0 || len(e.refs) != 0 {
+		t.Errorf("expected no subscribers and no names, got %d %v", e.Count(), e.refs)
+	}
+}
+
+func TestEventsShared(t *testing.T) {
+	dsn := GetTestDSN("test_events_shared_")
+	conn, err := sql.Open("firebirdsql_createdb", dsn)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	conn.Ping()
+	conn.Close()
+
+	fbevent, err := NewFBEvent(dsn)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer fbevent.Close()
+
+	// more names than fit in one EPB
+	var names []string
+	for i := 0; i < 20; i++ {
+		names = append(names, "shared_"+strconv.Itoa(i))
+	}
+	ch1 := make(chan Event, 100)
+	sub1, err := fbevent.SubscribeChan(names, ch1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := fbevent.fc
+	ch2 := make(chan Event, 100)
+	sub2, err := fbevent.SubscribeChan(names[15:], ch2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fbevent.fc != fc {
+		t.Error("expected the attachment to be shared")
+	}
+
+	for _, name := range []string{"shared_1", "shared_19", "shared_19"} {
+		if err := fbevent.PostEvent(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Second)
+	counts := func(ch chan Event) map[string]int {
+		res := map[string]int{}
+		for len(ch) > 0 {
+			e := <-ch
+			res[e.Name] += e.Count
+		}
+		return res
+	}
+	if c := counts(ch1); c["shared_1"] != 1 || c["shared_19"] != 2 {
+		t.Errorf("unexpected counts %v", c)
+	}
+	if c := counts(ch2); c["shared_1"] != 0 || c["shared_19"] != 2 {
+		t.Errorf("unexpected counts %v", c)
+	}
+
+	sub1.Unsubscribe()
+	if fbevent.fc == nil {
+		t.Error("expected the attachment to stay open for sub2")
+	}
+	sub2.Unsubscribe()
+	if fbevent.fc != nil {
+		t.Error("expected the attachment to be closed")
+	}
+}
+
+func TestPostEventStatement(t *testing.T) {
+	want := "execute block as begin post_event 'it''s'; end"
+	if got := postEventStatement("it's"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
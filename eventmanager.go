@@ -26,6 +26,7 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"context"
 	"sync"
 )
 
@@ -35,11 +36,16 @@ type eventManager struct {
 	destructor sync.Once
 }
 
-func newEventManager(address string, auxHandle int32) (*eventManager, error) {
-	wp, err := newWireProtocol(address, "", "UTF8")
+func newEventManager(dsn *firebirdDsn, address string, auxHandle int32) (*eventManager, error) {
+	conn, err := dsn.dialContext(context.Background(), address)
 	if err != nil {
 		return nil, err
 	}
+	wp, err := newWireProtocolConn(conn, address, "", "UTF8")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
 	newManager := &eventManager{
 		wp:     wp,
 		handle: auxHandle,
@@ -47,7 +53,9 @@ func newEventManager(address string, auxHandle int32) (*eventManager, error) {
 	return newManager, nil
 }
 
-func (e *eventManager) wait(event *remoteEvent, eventCounts chan<- Event) <-chan error {
+// wait reads the auxiliary connection and calls dispatch with the counts
+// of each op_event and the id the events were queued with.
+func (e *eventManager) wait(dispatch func(id int32, buffer []byte)) <-chan error {
 	chErr := make(chan error, 1)
 	go func() {
 		for {
@@ -68,9 +76,7 @@ func (e *eventManager) wait(event *remoteEvent, eventCounts chan<- Event) <-chan
 				b, _ = e.wp.recvPackets(4)
 				eventId := bytes_to_bint32(b)
 				e.wp.debugPrint("op_event:%v: event id: %v", buffer, eventId)
-				for _, count := range event.getEventCounts(buffer) {
-					eventCounts <- count
-				}
+				dispatch(eventId, buffer)
 			default:
 				e.wp.debugPrint("unknown operation:%v:%v", op, data)
 			}
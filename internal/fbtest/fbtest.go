@@ -0,0 +1,53 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+// Package fbtest holds helpers shared by the tests of the subpackages.
+package fbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// GetTestDSN returns the DSN of a new database file named after prefix on
+// the local server, with the login from ISC_USER and ISC_PASSWORD.
+func GetTestDSN(prefix string) string {
+	randBytes := make([]byte, 16)
+	rand.Read(randBytes)
+	dbPath := filepath.Join(os.TempDir(), prefix+hex.EncodeToString(randBytes)+".fdb")
+	if runtime.GOOS == "windows" {
+		dbPath = "/" + dbPath
+	}
+	user := "sysdba"
+	if s := os.Getenv("ISC_USER"); s != "" {
+		user = s
+	}
+	password := "masterkey"
+	if s := os.Getenv("ISC_PASSWORD"); s != "" {
+		password = s
+	}
+	return user + ":" + password + "@localhost:3050" + dbPath
+}
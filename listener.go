@@ -0,0 +1,215 @@
+//go:build !plan9
+
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2019 Arteev Aleksey
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"sync"
+)
+
+// ListenPolicy says what a Listener does with events once its buffer is
+// full.
+type ListenPolicy int
+
+// Policies for a Listener whose consumer falls behind.
+const (
+	// ListenCoalesce adds the count of an event to the pending event with
+	// the same name once the buffer is full.
+	ListenCoalesce ListenPolicy = iota
+	// ListenDropOldest discards the oldest pending event once the buffer is full.
+	ListenDropOldest
+	// ListenDropNewest discards new events once the buffer is full.
+	ListenDropNewest
+	// ListenBlock keeps every event; they queue up in memory.
+	ListenBlock
+)
+
+type ListenOptions struct {
+	Buffer int // pending events before Policy applies; at least 1
+	Policy ListenPolicy
+}
+
+type ListenOption func(*ListenOptions)
+
+func GetDefaultListenOptions() ListenOptions {
+	return ListenOptions{
+		Buffer: 64,
+		Policy: ListenCoalesce,
+	}
+}
+
+func WithListenBuffer(n int) ListenOption {
+	return func(opts *ListenOptions) {
+		opts.Buffer = n
+	}
+}
+
+func WithListenPolicy(policy ListenPolicy) ListenOption {
+	return func(opts *ListenOptions) {
+		opts.Policy = policy
+	}
+}
+
+func NewListenOptions(opts ...ListenOption) ListenOptions {
+	res := GetDefaultListenOptions()
+	for _, opt := range opts {
+		opt(&res)
+	}
+	return res
+}
+
+// Listener delivers the events of a Listen call on C. C is closed when the
+// context is done or the subscription fails; Err tells which.
+type Listener struct {
+	C <-chan Event
+
+	mu      sync.Mutex
+	err     error
+	dropped int
+}
+
+// Listen subscribes to names until ctx is done, with the default
+// ListenOptions.
+func (e *FbEvent) Listen(ctx context.Context, names ...string) (*Listener, error) {
+	return e.ListenWithOptions(ctx, GetDefaultListenOptions(), names...)
+}
+
+// ListenWithOptions subscribes to names until ctx is done. Events are
+// buffered for a slow consumer as options say.
+func (e *FbEvent) ListenWithOptions(ctx context.Context, options ListenOptions, names ...string) (*Listener, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if options.Buffer < 1 {
+		options.Buffer = 1
+	}
+	in := make(chan Event)
+	subscription, err := e.SubscribeChan(names, in)
+	if err != nil {
+		return nil, err
+	}
+	chClose := make(chan error, 1)
+	subscription.NotifyClose(chClose)
+
+	c := make(chan Event)
+	l := &Listener{C: c}
+	go l.run(ctx, options, subscription, in, c, chClose)
+	return l, nil
+}
+
+func (l *Listener) run(ctx context.Context, options ListenOptions, subscription *Subscription, in <-chan Event, c chan<- Event, chClose <-chan error) {
+	defer close(c)
+	var pending []Event
+	for {
+		var out chan<- Event
+		var next Event
+		if len(pending) > 0 {
+			out, next = c, pending[0]
+		}
+		receive := in
+		if options.Policy == ListenBlock && len(pending) >= options.Buffer {
+			receive = nil
+		}
+		select {
+		case e := <-receive:
+			pending = l.add(options, pending, e)
+		case out <- next:
+			pending = pending[1:]
+		case <-ctx.Done():
+			subscription.Unsubscribe()
+			l.setErr(ctx.Err())
+			return
+		case err := <-chClose:
+			l.setErr(err)
+			return
+		case <-subscription.doneSubscription:
+			select {
+			case err := <-chClose:
+				l.setErr(err)
+			default:
+				// closed without an error, e.g. by FbEvent.Close
+				l.setErr(ErrFbEventClosed)
+			}
+			return
+		}
+	}
+}
+
+func (l *Listener) add(options ListenOptions, pending []Event, e Event) []Event {
+	if len(pending) < options.Buffer || e.Reconnected {
+		return append(pending, e)
+	}
+	switch options.Policy {
+	case ListenCoalesce:
+		for i := range pending {
+			if pending[i].Name == e.Name && !pending[i].Reconnected {
+				pending[i].Count += e.Count
+				pending[i].ID, pending[i].RemoteID = e.ID, e.RemoteID
+				return pending
+			}
+		}
+		// at most one pending event per name
+		return append(pending, e)
+	case ListenDropOldest:
+		if len(pending) == 0 {
+			return append(pending, e)
+		}
+		l.drop()
+		return append(pending[1:], e)
+	case ListenDropNewest:
+		l.drop()
+		return pending
+	}
+	return append(pending, e)
+}
+
+func (l *Listener) drop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dropped++
+}
+
+func (l *Listener) setErr(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
+
+// Err returns why C was closed: the context error, the error of the
+// connection, or ErrFbEventClosed. It is nil while C is open.
+func (l *Listener) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Dropped returns the number of events discarded by ListenDropOldest or
+// ListenDropNewest.
+func (l *Listener) Dropped() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
@@ -0,0 +1,141 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2019 Arteev Aleksey
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerAdd(t *testing.T) {
+	ev := func(name string, count int) Event {
+		return Event{Name: name, Count: count}
+	}
+	full := []Event{ev("a", 1), ev("b", 1)}
+	clone := func() []Event {
+		return append([]Event(nil), full...)
+	}
+
+	l := &Listener{}
+	got := l.add(NewListenOptions(WithListenBuffer(2)), clone(), ev("a", 2))
+	assert.Equal(t, []Event{ev("a", 3), ev("b", 1)}, got)
+	got = l.add(NewListenOptions(WithListenBuffer(2)), clone(), ev("c", 2))
+	assert.Equal(t, []Event{ev("a", 1), ev("b", 1), ev("c", 2)}, got)
+	got = l.add(NewListenOptions(WithListenBuffer(3)), clone(), ev("a", 2))
+	assert.Equal(t, []Event{ev("a", 1), ev("b", 1), ev("a", 2)}, got)
+
+	got = l.add(NewListenOptions(WithListenBuffer(2), WithListenPolicy(ListenDropOldest)), clone(), ev("c", 1))
+	assert.Equal(t, []Event{ev("b", 1), ev("c", 1)}, got)
+	got = l.add(NewListenOptions(WithListenBuffer(2), WithListenPolicy(ListenDropNewest)), clone(), ev("c", 1))
+	assert.Equal(t, []Event{ev("a", 1), ev("b", 1)}, got)
+	assert.Equal(t, 2, l.Dropped())
+
+	got = l.add(NewListenOptions(WithListenBuffer(2), WithListenPolicy(ListenDropNewest)), clone(), Event{Reconnected: true})
+	assert.Len(t, got, 3)
+
+	got = l.add(NewListenOptions(WithListenBuffer(0), WithListenPolicy(ListenDropOldest)), nil, ev("a", 1))
+	assert.Equal(t, []Event{ev("a", 1)}, got)
+}
+
+func TestListenerRun(t *testing.T) {
+	e := &FbEvent{
+		done:             make(chan struct{}),
+		chDoneSubscriber: make(chan *Subscription),
+		queues:           make(map[int32]*remoteEvent),
+		refs:             make(map[string]int),
+	}
+	go e.run()
+	defer close(e.done)
+
+	in := make(chan Event)
+	subscription, err := newSubscription(e, []string{"a"}, nil, in)
+	require.NoError(t, err)
+	chClose := make(chan error, 1)
+	subscription.NotifyClose(chClose)
+	c := make(chan Event)
+	l := &Listener{C: c}
+	ctx, cancel := context.WithCancel(context.Background())
+	go l.run(ctx, NewListenOptions(WithListenBuffer(1)), subscription, in, c, chClose)
+
+	// the consumer is not reading: counts are summed
+	for i := 0; i < 5; i++ {
+		subscription.push(Event{Name: "a", Count: 1})
+	}
+	time.Sleep(100 * time.Millisecond)
+	total := 0
+	for total < 5 {
+		select {
+		case e := <-l.C:
+			total += e.Count
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events, want 5", total)
+		}
+	}
+	assert.Nil(t, l.Err())
+
+	cancel()
+	_, ok := <-l.C
+	assert.False(t, ok)
+	assert.ErrorIs(t, l.Err(), context.Canceled)
+	assert.Eventually(t, subscription.IsClose, time.Second, 10*time.Millisecond)
+}
+
+func TestListen(t *testing.T) {
+	dsn := GetTestDSN("test_listen_")
+	conn, err := sql.Open("firebirdsql_createdb", dsn)
+	require.NoError(t, err)
+	conn.Ping()
+	conn.Close()
+
+	fbevent, err := NewFBEvent(dsn)
+	require.NoError(t, err)
+	defer fbevent.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l, err := fbevent.Listen(ctx, "listen_1", "listen_2")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, fbevent.PostEvent("listen_1"))
+	}
+	total := 0
+	for total < 3 {
+		select {
+		case e := <-l.C:
+			assert.Equal(t, "listen_1", e.Name)
+			total += e.Count
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d events, want 3", total)
+		}
+	}
+	cancel()
+	for range l.C {
+	}
+	assert.True(t, errors.Is(l.Err(), context.Canceled))
+}
@@ -23,6 +23,8 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 
 package firebirdsql
 
+import "context"
+
 type MaintenanceManager struct {
 	connBuilder func() (*ServiceManager, error)
 }
@@ -146,10 +148,15 @@ func (mm *MaintenanceManager) SetSweepInterval(database string, transactions uin
 }
 
 func (mm *MaintenanceManager) Sweep(database string) error {
+	return mm.SweepContext(context.Background(), database)
+}
+
+// SweepContext sweeps database, aborting the sweep when ctx is done.
+func (mm *MaintenanceManager) SweepContext(ctx context.Context, database string) error {
 	spb := NewXPBWriterFromTag(isc_action_svc_repair)
 	spb.PutString(isc_spb_dbname, database)
 	spb.PutInt32(isc_spb_options, isc_spb_rpr_sweep_db)
-	return mm.attach(spb.Bytes(), nil)
+	return serviceAttachContext(ctx, mm.connBuilder, spb.Bytes(), nil)
 }
 
 func (mm *MaintenanceManager) ActivateShadow(shadow string) error {
@@ -174,10 +181,15 @@ func (mm *MaintenanceManager) Mend(database string) error {
 }
 
 func (mm *MaintenanceManager) Validate(database string, options int) error {
+	return mm.ValidateContext(context.Background(), database, options)
+}
+
+// ValidateContext validates database, aborting the validation when ctx is done.
+func (mm *MaintenanceManager) ValidateContext(ctx context.Context, database string, options int) error {
 	spb := NewXPBWriterFromTag(isc_action_svc_repair)
 	spb.PutString(isc_spb_dbname, database)
 	spb.PutInt32(isc_spb_options, isc_spb_rpr_validate_db|int32(options))
-	return mm.attach(spb.Bytes(), nil)
+	return serviceAttachContext(ctx, mm.connBuilder, spb.Bytes(), nil)
 }
 
 func (mm *MaintenanceManager) GetLimboTransactions(database string) ([]int64, error) {
@@ -0,0 +1,502 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+/*
+Package migrate applies versioned SQL scripts to a Firebird database.
+
+Migrations are read from files named <version>_<name>.up.sql and
+<version>_<name>.down.sql, applied in version order and recorded in a
+version table.
+
+	migrations, err := migrate.Load(os.DirFS("migrations"), ".")
+	m, err := migrate.New(db, migrations, migrate.NewOptions())
+	applied, err := m.Up(ctx)
+
+Scripts are split by firebirdsql.SplitScript, so SET TERM and PSQL bodies
+work. Firebird
+cannot use an object in the transaction that created it, so the transaction
+is committed after each DDL statement and at COMMIT; a migration that fails
+halfway may leave its earlier DDL in place.
+
+Only one runner works on a database at a time: the runner holds an update
+lock on the row of a lock table while it works, and a second runner fails
+with ErrLocked.
+*/
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nakagami/firebirdsql"
+)
+
+var (
+	ErrLocked       = errors.New("migrate: another migration is running")
+	ErrNoDownScript = errors.New("migrate: migration has no down script")
+	ErrUnknown      = errors.New("migrate: applied version has no migration")
+)
+
+// Migration is one versioned change of the schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string // empty if the migration cannot be reverted
+}
+
+// Status tells whether a migration is applied.
+type Status struct {
+	Migration *Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Error is returned when a statement of a migration fails.
+type Error struct {
+	Version   int64
+	Name      string
+	Statement int // index of the statement in the script
+	Line      int // position of the statement in the script
+	SQL       string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("migrate: %d_%s line %d: %v", e.Version, e.Name, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+?)(\.up|\.down)?\.sql$`)
+
+// Load reads the migrations in dir of fsys. A file without .up or .down
+// is an up script.
+func Load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		m := fileNamePattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %s: %w", e.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == ".down" {
+			mig.Down = string(b)
+		} else if mig.Up != "" {
+			return nil, fmt.Errorf("migrate: version %d has more than one up script", version)
+		} else {
+			mig.Up = string(b)
+		}
+	}
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		migrations = append(migrations, mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+type Options struct {
+	Table     string    // version table, as stored in RDB$RELATIONS
+	LockTable string    // table holding the lock row, as stored in RDB$RELATIONS
+	DryRun    bool      // print the statements instead of running them
+	Output    io.Writer // receives the statements of a dry run
+}
+
+type Option func(*Options)
+
+func GetDefaultOptions() Options {
+	return Options{
+		Table:     "SCHEMA_MIGRATIONS",
+		LockTable: "SCHEMA_MIGRATIONS_LOCK",
+		DryRun:    false,
+		Output:    io.Discard,
+	}
+}
+
+func WithTable(table string) Option {
+	return func(opts *Options) {
+		opts.Table = table
+	}
+}
+
+func WithLockTable(table string) Option {
+	return func(opts *Options) {
+		opts.LockTable = table
+	}
+}
+
+// WithDryRun writes the statements that would run to w; nothing is
+// changed and no lock is taken.
+func WithDryRun(w io.Writer) Option {
+	return func(opts *Options) {
+		opts.DryRun = true
+		opts.Output = w
+	}
+}
+
+func WithoutDryRun() Option {
+	return func(opts *Options) {
+		opts.DryRun = false
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	res := GetDefaultOptions()
+	for _, opt := range opts {
+		opt(&res)
+	}
+	return res
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+	options    Options
+}
+
+// New returns a Migrator for migrations. While it works it holds one
+// connection of db for the lock and uses another for the scripts.
+func New(db *sql.DB, migrations []*Migration, options Options) (*Migrator, error) {
+	seen := make(map[int64]bool)
+	for _, m := range migrations {
+		if seen[m.Version] {
+			return nil, fmt.Errorf("migrate: duplicate version %d", m.Version)
+		}
+		seen[m.Version] = true
+	}
+	sorted := append([]*Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return &Migrator{db: db, migrations: sorted, options: options}, nil
+}
+
+// Status returns all migrations with their state, and fails with
+// ErrUnknown if the database has a version that is not a migration.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Status
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		res = append(res, Status{Migration: mig, Applied: ok, AppliedAt: at})
+		delete(applied, mig.Version)
+	}
+	if len(applied) > 0 {
+		var unknown []string
+		for version := range applied {
+			unknown = append(unknown, strconv.FormatInt(version, 10))
+		}
+		sort.Strings(unknown)
+		return res, fmt.Errorf("%w: %s", ErrUnknown, strings.Join(unknown, ", "))
+	}
+	return res, nil
+}
+
+// Up applies all pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	return m.UpTo(ctx, -1)
+}
+
+// UpTo applies the pending migrations up to version; a negative version
+// applies all of them.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]*Migration, error) {
+	var done []*Migration
+	err := m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if version >= 0 && mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err = m.run(ctx, mig, mig.Up, false); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations and returns them; a
+// negative steps reverts all of them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		for i, v := range versions {
+			if i == steps {
+				break
+			}
+			mig := m.migration(v)
+			if mig == nil {
+				return fmt.Errorf("%w: %d", ErrUnknown, v)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDownScript, mig.Version, mig.Name)
+			}
+			if err = m.run(ctx, mig, mig.Down, true); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) migration(version int64) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) tableExists(ctx context.Context, table string) (bool, error) {
+	var n int
+	err := m.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM RDB$RELATIONS WHERE RDB$RELATION_NAME = ?", table).Scan(&n)
+	return n > 0, err
+}
+
+// applied returns the applied versions; none if the version table does
+// not exist yet.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	applied := make(map[int64]time.Time)
+	exists, err := m.tableExists(ctx, m.options.Table)
+	if err != nil || !exists {
+		return applied, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT VERSION, APPLIED_AT FROM "+quote(m.options.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// createTable creates table unless it exists; a runner that loses the race
+// to create it finds it on the second look.
+func (m *Migrator) createTable(ctx context.Context, table string, ddl string) error {
+	exists, err := m.tableExists(ctx, table)
+	if err != nil || exists {
+		return err
+	}
+	if _, err = m.db.ExecContext(ctx, ddl); err != nil {
+		if exists, _ = m.tableExists(ctx, table); exists {
+			return nil
+		}
+	}
+	return err
+}
+
+// locked runs fn while holding the lock row in a NOWAIT transaction, so a
+// concurrent runner fails with ErrLocked instead of waiting.
+func (m *Migrator) locked(ctx context.Context, fn func() error) error {
+	if m.options.DryRun {
+		return fn()
+	}
+	lockTable := quote(m.options.LockTable)
+	if err := m.createTable(ctx, m.options.LockTable,
+		"CREATE TABLE "+lockTable+" (ID INTEGER NOT NULL PRIMARY KEY, LOCKED_AT TIMESTAMP)"); err != nil {
+		return err
+	}
+	if err := m.createTable(ctx, m.options.Table,
+		"CREATE TABLE "+quote(m.options.Table)+" (VERSION BIGINT NOT NULL PRIMARY KEY, NAME VARCHAR(255), APPLIED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL)"); err != nil {
+		return err
+	}
+	// only reads the row if it exists, so a held lock does not block here
+	if _, err := m.db.ExecContext(ctx, "INSERT INTO "+lockTable+" (ID) SELECT 1 FROM RDB$DATABASE "+
+		"WHERE NOT EXISTS (SELECT 1 FROM "+lockTable+" WHERE ID = 1)"); err != nil && !isUniqueViolation(err) {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, &sql.TxOptions{Isolation: firebirdsql.LevelReadCommittedNoWait})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, "UPDATE "+lockTable+" SET LOCKED_AT = CURRENT_TIMESTAMP WHERE ID = 1"); err != nil {
+		if isLockConflict(err) {
+			return ErrLocked
+		}
+		return err
+	}
+	return fn()
+}
+
+func isUniqueViolation(err error) bool {
+	var fbErr *firebirdsql.FbError
+	return errors.As(err, &fbErr) && slices.Contains(fbErr.GDSCodes, firebirdsql.ISCUniqueKeyViolation)
+}
+
+func isLockConflict(err error) bool {
+	var fbErr *firebirdsql.FbError
+	if !errors.As(err, &fbErr) {
+		return false
+	}
+	for _, code := range fbErr.GDSCodes {
+		if code == firebirdsql.ISCLockConflict || code == firebirdsql.ISCUpdateConflict || code == firebirdsql.ISCDeadlock {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	dmlPattern    = regexp.MustCompile(`(?is)^(INSERT|UPDATE|DELETE|MERGE|SELECT|WITH|EXECUTE)\b`)
+	commitPattern = regexp.MustCompile(`(?is)^COMMIT(\s+WORK)?$`)
+	// isql directives that have no meaning here
+	directivePattern = regexp.MustCompile(`(?is)^SET\s+(AUTODDL|SQL\s+DIALECT|NAMES|ECHO|BAIL|LIST|STATS|PLAN|COUNT|HEADING|WARNINGS)\b`)
+)
+
+// run executes script and records or removes the version of mig in the
+// last transaction.
+func (m *Migrator) run(ctx context.Context, mig *Migration, script string, down bool) error {
+	statements, err := firebirdsql.SplitScript(strings.NewReader(script))
+	if err != nil {
+		line := 0
+		var scriptErr *firebirdsql.ScriptError
+		if errors.As(err, &scriptErr) {
+			line = scriptErr.Line
+		}
+		return &Error{Version: mig.Version, Name: mig.Name, Statement: -1, Line: line, Err: err}
+	}
+	record := "INSERT INTO " + quote(m.options.Table) + " (VERSION, NAME) VALUES (?, ?)"
+	args := []any{mig.Version, mig.Name}
+	if down {
+		record = "DELETE FROM " + quote(m.options.Table) + " WHERE VERSION = ?"
+		args = args[:1]
+	}
+
+	if m.options.DryRun {
+		fmt.Fprintf(m.options.Output, "-- %d_%s\n", mig.Version, mig.Name)
+		for _, stmt := range statements {
+			fmt.Fprintf(m.options.Output, "%s\n;\n", stmt.SQL)
+		}
+		return nil
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+	commit := func() error {
+		err := tx.Commit()
+		tx = nil
+		if err == nil {
+			tx, err = m.db.BeginTx(ctx, nil)
+		}
+		return err
+	}
+	for i, stmt := range statements {
+		switch {
+		case directivePattern.MatchString(stmt.SQL):
+			continue
+		case commitPattern.MatchString(stmt.SQL):
+			err = commit()
+		default:
+			if _, err = tx.ExecContext(ctx, stmt.SQL); err == nil && !dmlPattern.MatchString(stmt.SQL) {
+				err = commit()
+			}
+		}
+		if err != nil {
+			return &Error{Version: mig.Version, Name: mig.Name, Statement: i, Line: stmt.Line, SQL: stmt.SQL, Err: err}
+		}
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	tx = nil
+	return err
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,135 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nakagami/firebirdsql"
+	"github.com/nakagami/firebirdsql/internal/fbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementKind(t *testing.T) {
+	assert.True(t, dmlPattern.MatchString("update t1 set a = 1"))
+	assert.False(t, dmlPattern.MatchString("ALTER TABLE t1 ADD a INTEGER"))
+	assert.True(t, directivePattern.MatchString("SET AUTODDL OFF"))
+	assert.True(t, commitPattern.MatchString("commit work"))
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/2_add_name.up.sql":    {Data: []byte("ALTER TABLE t1 ADD name VARCHAR(10);")},
+		"migrations/2_add_name.down.sql":  {Data: []byte("ALTER TABLE t1 DROP name;")},
+		"migrations/1_create.sql":         {Data: []byte("CREATE TABLE t1 (id INTEGER);")},
+		"migrations/10_seed.up.sql":       {Data: []byte("INSERT INTO t1 (id) VALUES (1);")},
+		"migrations/README.md":            {Data: []byte("not a migration")},
+		"migrations/sub/3_ignored.up.sql": {Data: []byte("")},
+		"other/4_elsewhere.up.sql":        {Data: []byte("")},
+	}
+	migrations, err := Load(fsys, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, &Migration{Version: 1, Name: "create", Up: "CREATE TABLE t1 (id INTEGER);"}, migrations[0])
+	assert.Equal(t, &Migration{Version: 2, Name: "add_name", Up: "ALTER TABLE t1 ADD name VARCHAR(10);", Down: "ALTER TABLE t1 DROP name;"}, migrations[1])
+	assert.Equal(t, int64(10), migrations[2].Version)
+
+	fsys["migrations/2_other.up.sql"] = &fstest.MapFile{Data: []byte("")}
+	_, err = Load(fsys, "migrations")
+	assert.Error(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("firebirdsql_createdb", fbtest.GetTestDSN("test_migrate_"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrations := []*Migration{
+		{Version: 1, Name: "create", Up: `
+CREATE TABLE t1 (id INTEGER NOT NULL PRIMARY KEY);
+INSERT INTO t1 (id) VALUES (1);
+SET TERM ^ ;
+CREATE PROCEDURE p1 RETURNS (n INTEGER) AS
+BEGIN
+  SELECT COUNT(*) FROM t1 INTO :n;
+  SUSPEND;
+END^
+SET TERM ; ^
+`, Down: "DROP PROCEDURE p1; DROP TABLE t1;"},
+		{Version: 2, Name: "add_name", Up: "ALTER TABLE t1 ADD name VARCHAR(10); UPDATE t1 SET name = 'one';",
+			Down: "ALTER TABLE t1 DROP name;"},
+		{Version: 3, Name: "broken", Up: "ALTER TABLE t1 ADD no_such_type NOSUCHTYPE;"},
+	}
+
+	var out bytes.Buffer
+	m, err := New(db, migrations, NewOptions(WithDryRun(&out)))
+	require.NoError(t, err)
+	applied, err := m.UpTo(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.Contains(t, out.String(), "-- 2_add_name\n")
+
+	m, err = New(db, migrations, NewOptions())
+	require.NoError(t, err)
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status[0].Applied)
+
+	applied, err = m.UpTo(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	var name string
+	require.NoError(t, db.QueryRow("SELECT name FROM t1").Scan(&name))
+	assert.Equal(t, "one", name)
+
+	_, err = m.Up(ctx)
+	var migrateErr *Error
+	require.True(t, errors.As(err, &migrateErr))
+	assert.Equal(t, int64(3), migrateErr.Version)
+	assert.Equal(t, 0, migrateErr.Statement)
+
+	// another runner holds the lock
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: firebirdsql.LevelReadCommittedNoWait})
+	require.NoError(t, err)
+	_, err = tx.Exec("UPDATE SCHEMA_MIGRATIONS_LOCK SET LOCKED_AT = CURRENT_TIMESTAMP")
+	require.NoError(t, err)
+	_, err = m.Down(ctx, 1)
+	assert.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, tx.Rollback())
+
+	reverted, err := m.Down(ctx, -1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 2)
+	assert.Equal(t, int64(2), reverted[0].Version)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status[0].Applied)
+}
@@ -23,6 +23,8 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 
 package firebirdsql
 
+import "context"
+
 type NBackupManager struct {
 	connBuilder func() (*ServiceManager, error)
 }
@@ -129,6 +131,16 @@ func NewNBackupManager(addr string, user string, password string, options Servic
 }
 
 func (bm *NBackupManager) Backup(database string, backup string, options NBackupOptions, verbose chan string) error {
+	return bm.attach(nbackupSPB(database, backup, options), verbose)
+}
+
+// BackupContext is like Backup, but aborts the backup when ctx is done.
+// nbackup has no verbose output to report progress with.
+func (bm *NBackupManager) BackupContext(ctx context.Context, database string, backup string, options NBackupOptions) error {
+	return serviceRunContext(ctx, bm.connBuilder, nbackupSPB(database, backup, options), nil)
+}
+
+func nbackupSPB(database string, backup string, options NBackupOptions) []byte {
 	spb := NewXPBWriterFromTag(isc_action_svc_nbak)
 	spb.PutString(isc_spb_dbname, database)
 	spb.PutString(isc_spb_nbk_file, backup)
@@ -147,10 +159,19 @@ func (bm *NBackupManager) Backup(database string, backup string, options NBackup
 		spb.PutInt32(isc_spb_options, optionsMask)
 	}
 
-	return bm.attach(spb.Bytes(), verbose)
+	return spb.Bytes()
 }
 
 func (bm *NBackupManager) Restore(backups []string, database string, options NBackupOptions, verbose chan string) error {
+	return bm.attach(nrestoreSPB(backups, database, options), verbose)
+}
+
+// RestoreContext is like Restore, but aborts the restore when ctx is done.
+func (bm *NBackupManager) RestoreContext(ctx context.Context, backups []string, database string, options NBackupOptions) error {
+	return serviceRunContext(ctx, bm.connBuilder, nrestoreSPB(backups, database, options), nil)
+}
+
+func nrestoreSPB(backups []string, database string, options NBackupOptions) []byte {
 	spb := NewXPBWriterFromTag(isc_action_svc_nrest)
 	spb.PutString(isc_spb_dbname, database)
 	for _, file := range backups {
@@ -162,7 +183,7 @@ func (bm *NBackupManager) Restore(backups []string, database string, options NBa
 		spb.PutInt32(isc_spb_options, optionsMask)
 	}
 
-	return bm.attach(spb.Bytes(), verbose)
+	return spb.Bytes()
 }
 
 func (bm *NBackupManager) Fixup(database string, options NBackupOptions, verbose chan string) error {
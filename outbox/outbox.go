@@ -0,0 +1,373 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+/*
+Package outbox records changes in a queue table and notifies consumers with
+Firebird events, so a consumer gets every change even if it was not
+listening when the change was committed.
+
+	ob := outbox.New(db, outbox.NewOptions())
+	err := ob.Install(ctx)            // queue table and sequence
+	err = ob.Track(ctx, "ORDERS")     // trigger recording inserts, updates and deletes
+	err = ob.Publish(ctx, tx, "mail", "42", "payload") // in the caller's transaction
+
+	fbEvent, _ := firebirdsql.NewFBEvent(dsn)
+	err = ob.Consume(ctx, fbEvent, func(ctx context.Context, changes []outbox.Change) error {
+		...
+	})
+
+Changes are removed from the queue once the handler returns nil, in the
+transaction that read them, so they are delivered at least once. Events are
+only a wake-up call: the queue is read after every event and every
+PollInterval, so missed events do not lose changes.
+*/
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nakagami/firebirdsql"
+)
+
+// Operations of a Change.
+const (
+	OpInsert  = "I"
+	OpUpdate  = "U"
+	OpDelete  = "D"
+	OpPublish = "P" // recorded by Publish
+)
+
+var ErrNoPrimaryKey = errors.New("outbox: table has no primary key")
+
+// Change is a row of the queue table.
+type Change struct {
+	ID        int64
+	Source    string // table name, or topic for OpPublish
+	Operation string
+	Key       string // primary key; the columns of a composite key are joined with "|"
+	Payload   string // set by Publish only
+	CreatedAt time.Time
+}
+
+// Handler processes a batch of changes. If it returns an error, the
+// changes stay queued and Consume returns the error.
+type Handler func(ctx context.Context, changes []Change) error
+
+type Options struct {
+	Table        string        // queue table; its sequence is named <Table>_SEQ and its event <Table>
+	BatchSize    int           // changes passed to a Handler at most; at least 1
+	PollInterval time.Duration // look at the queue without an event that often, 0 never
+}
+
+type Option func(*Options)
+
+func GetDefaultOptions() Options {
+	return Options{
+		Table:        "OUTBOX",
+		BatchSize:    100,
+		PollInterval: time.Minute,
+	}
+}
+
+func WithTable(table string) Option {
+	return func(opts *Options) {
+		opts.Table = table
+	}
+}
+
+func WithBatchSize(n int) Option {
+	return func(opts *Options) {
+		opts.BatchSize = n
+	}
+}
+
+func WithPollInterval(d time.Duration) Option {
+	return func(opts *Options) {
+		opts.PollInterval = d
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	res := GetDefaultOptions()
+	for _, opt := range opts {
+		opt(&res)
+	}
+	return res
+}
+
+type Outbox struct {
+	db      *sql.DB
+	options Options
+}
+
+func New(db *sql.DB, options Options) *Outbox {
+	return &Outbox{db: db, options: options}
+}
+
+// Event returns the event posted for every change.
+func (o *Outbox) Event() string {
+	return o.options.Table
+}
+
+// TableEvent returns the event posted for the changes of table, for
+// listeners that only need a counter.
+func (o *Outbox) TableEvent(table string) string {
+	return o.options.Table + ":" + table
+}
+
+func (o *Outbox) sequence() string {
+	return firebirdsql.QuoteIdentifier(o.options.Table + "_SEQ")
+}
+
+func (o *Outbox) triggerName(table string) string {
+	return firebirdsql.QuoteIdentifier(o.options.Table + "_" + table)
+}
+
+func (o *Outbox) exists(ctx context.Context, query string, name string) (bool, error) {
+	var n int
+	err := o.db.QueryRowContext(ctx, query, name).Scan(&n)
+	return n > 0, err
+}
+
+// Install creates the queue table and its sequence unless they exist.
+func (o *Outbox) Install(ctx context.Context) error {
+	exists, err := o.exists(ctx, "SELECT COUNT(*) FROM RDB$GENERATORS WHERE RDB$GENERATOR_NAME = ?", o.options.Table+"_SEQ")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err = o.db.ExecContext(ctx, "CREATE SEQUENCE "+o.sequence()); err != nil {
+			return err
+		}
+	}
+	exists, err = o.exists(ctx, "SELECT COUNT(*) FROM RDB$RELATIONS WHERE RDB$RELATION_NAME = ?", o.options.Table)
+	if err != nil || exists {
+		return err
+	}
+	_, err = o.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %s (
+  ID BIGINT NOT NULL PRIMARY KEY,
+  SOURCE VARCHAR(63) NOT NULL,
+  OPERATION CHAR(1) NOT NULL,
+  KEY_VALUE VARCHAR(1000),
+  PAYLOAD BLOB SUB_TYPE TEXT,
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL)`, firebirdsql.QuoteIdentifier(o.options.Table)))
+	return err
+}
+
+// primaryKey returns the primary key columns of table.
+func (o *Outbox) primaryKey(ctx context.Context, table string) ([]string, error) {
+	rows, err := o.db.QueryContext(ctx, `SELECT TRIM(s.RDB$FIELD_NAME)
+FROM RDB$RELATION_CONSTRAINTS c
+JOIN RDB$INDEX_SEGMENTS s ON s.RDB$INDEX_NAME = c.RDB$INDEX_NAME
+WHERE c.RDB$RELATION_NAME = ? AND c.RDB$CONSTRAINT_TYPE = 'PRIMARY KEY'
+ORDER BY s.RDB$FIELD_POSITION`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoPrimaryKey, table)
+	}
+	return columns, nil
+}
+
+// Track creates the trigger recording the changes of table, which needs a
+// primary key. table is the name as stored in RDB$RELATIONS.
+func (o *Outbox) Track(ctx context.Context, table string) error {
+	columns, err := o.primaryKey(ctx, table)
+	if err != nil {
+		return err
+	}
+	_, err = o.db.ExecContext(ctx, o.triggerDDL(table, columns))
+	return err
+}
+
+// Untrack drops the trigger created by Track.
+func (o *Outbox) Untrack(ctx context.Context, table string) error {
+	_, err := o.db.ExecContext(ctx, "DROP TRIGGER "+o.triggerName(table))
+	return err
+}
+
+func (o *Outbox) triggerDDL(table string, columns []string) string {
+	key := func(context string) string {
+		parts := make([]string, len(columns))
+		for i, column := range columns {
+			parts[i] = context + "." + firebirdsql.QuoteIdentifier(column)
+		}
+		return strings.Join(parts, " || '|' || ")
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (ID, SOURCE, OPERATION, KEY_VALUE) VALUES (NEXT VALUE FOR %s, %s, ",
+		firebirdsql.QuoteIdentifier(o.options.Table), o.sequence(), firebirdsql.QuoteString(table))
+	return fmt.Sprintf(`CREATE OR ALTER TRIGGER %s FOR %s ACTIVE AFTER INSERT OR UPDATE OR DELETE POSITION 32000
+AS
+BEGIN
+  IF (DELETING) THEN
+    %s'D', %s);
+  ELSE
+    %sIIF(INSERTING, 'I', 'U'), %s);
+  POST_EVENT %s;
+  POST_EVENT %s;
+END`, o.triggerName(table), firebirdsql.QuoteIdentifier(table),
+		insert, key("OLD"), insert, key("NEW"),
+		firebirdsql.QuoteString(o.Event()), firebirdsql.QuoteString(o.TableEvent(table)))
+}
+
+// Publish queues a message in tx. Consumers are notified when tx commits;
+// if it rolls back, the message is gone with it.
+func (o *Outbox) Publish(ctx context.Context, tx *sql.Tx, topic string, key string, payload string) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (ID, SOURCE, OPERATION, KEY_VALUE, PAYLOAD) VALUES (NEXT VALUE FOR %s, ?, ?, ?, ?)",
+		firebirdsql.QuoteIdentifier(o.options.Table), o.sequence()), topic, OpPublish, key, payload)
+	if err != nil {
+		return err
+	}
+	return firebirdsql.PostEventTx(ctx, tx, o.Event())
+}
+
+// Consume passes the queued changes to handler, then waits for the event
+// of the queue, until ctx is done. Rows are locked while handler runs, so
+// concurrent consumers of the same queue take turns.
+func (o *Outbox) Consume(ctx context.Context, fbEvent *firebirdsql.FbEvent, handler Handler) error {
+	if o.options.BatchSize < 1 {
+		return fmt.Errorf("outbox: invalid batch size %d", o.options.BatchSize)
+	}
+	// ends the subscription when Consume returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	listener, err := fbEvent.Listen(ctx, o.Event())
+	if err != nil {
+		return err
+	}
+	var poll <-chan time.Time
+	if o.options.PollInterval > 0 {
+		ticker := time.NewTicker(o.options.PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	for {
+		for {
+			n, err := o.consumeBatch(ctx, handler)
+			if err != nil {
+				return err
+			}
+			if n < o.options.BatchSize {
+				break
+			}
+		}
+		select {
+		case _, ok := <-listener.C:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return listener.Err()
+			}
+		case <-poll:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// consumeBatch hands one batch to handler and returns its size; a batch
+// locked by another consumer counts as empty.
+func (o *Outbox) consumeBatch(ctx context.Context, handler Handler) (int, error) {
+	tx, err := o.db.BeginTx(ctx, &sql.TxOptions{Isolation: firebirdsql.LevelReadCommittedNoWait})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	changes, err := o.fetch(ctx, tx)
+	if err != nil {
+		if isLockConflict(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	if err = handler(ctx, changes); err != nil {
+		return 0, err
+	}
+	// IDs are not committed in order, so only the rows handled are removed
+	del := fmt.Sprintf("DELETE FROM %s WHERE ID = ?", firebirdsql.QuoteIdentifier(o.options.Table))
+	for _, c := range changes {
+		if _, err = tx.ExecContext(ctx, del, c.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(changes), tx.Commit()
+}
+
+func (o *Outbox) fetch(ctx context.Context, tx *sql.Tx) ([]Change, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		"SELECT ID, SOURCE, OPERATION, KEY_VALUE, PAYLOAD, CREATED_AT FROM %s ORDER BY ID ROWS %d WITH LOCK",
+		firebirdsql.QuoteIdentifier(o.options.Table), o.options.BatchSize))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		var key, payload sql.NullString
+		if err = rows.Scan(&c.ID, &c.Source, &c.Operation, &key, &payload, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.Source = strings.TrimSpace(c.Source)
+		c.Key, c.Payload = key.String, payload.String
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func isLockConflict(err error) bool {
+	var fbErr *firebirdsql.FbError
+	if !errors.As(err, &fbErr) {
+		return false
+	}
+	for _, code := range fbErr.GDSCodes {
+		if code == firebirdsql.ISCLockConflict || code == firebirdsql.ISCUpdateConflict || code == firebirdsql.ISCDeadlock {
+			return true
+		}
+	}
+	return false
+}
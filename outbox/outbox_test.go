@@ -0,0 +1,123 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/nakagami/firebirdsql"
+	"github.com/nakagami/firebirdsql/internal/fbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTriggerDDL(t *testing.T) {
+	o := New(nil, NewOptions())
+	assert.Equal(t, `CREATE OR ALTER TRIGGER OUTBOX_ORDER_LINES FOR ORDER_LINES ACTIVE AFTER INSERT OR UPDATE OR DELETE POSITION 32000
+AS
+BEGIN
+  IF (DELETING) THEN
+    INSERT INTO OUTBOX (ID, SOURCE, OPERATION, KEY_VALUE) VALUES (NEXT VALUE FOR OUTBOX_SEQ, 'ORDER_LINES', 'D', OLD.ORDER_ID || '|' || OLD."Line");
+  ELSE
+    INSERT INTO OUTBOX (ID, SOURCE, OPERATION, KEY_VALUE) VALUES (NEXT VALUE FOR OUTBOX_SEQ, 'ORDER_LINES', IIF(INSERTING, 'I', 'U'), NEW.ORDER_ID || '|' || NEW."Line");
+  POST_EVENT 'OUTBOX';
+  POST_EVENT 'OUTBOX:ORDER_LINES';
+END`, o.triggerDDL("ORDER_LINES", []string{"ORDER_ID", "Line"}))
+
+	o = New(nil, NewOptions(WithTable("CHANGES")))
+	assert.Equal(t, "CHANGES", o.Event())
+	assert.Equal(t, "CHANGES:T1", o.TableEvent("T1"))
+	assert.Equal(t, "CHANGES_SEQ", o.sequence())
+
+	o = New(nil, NewOptions(WithBatchSize(0)))
+	assert.Error(t, o.Consume(context.Background(), nil, nil))
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	dsn := fbtest.GetTestDSN("test_outbox_")
+	db, err := sql.Open("firebirdsql_createdb", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t1 (id INTEGER NOT NULL PRIMARY KEY, s VARCHAR(10))")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE t2 (s VARCHAR(10))")
+	require.NoError(t, err)
+
+	o := New(db, NewOptions(WithBatchSize(2)))
+	require.NoError(t, o.Install(ctx))
+	require.NoError(t, o.Install(ctx))
+	require.NoError(t, o.Track(ctx, "T1"))
+	assert.ErrorIs(t, o.Track(ctx, "T2"), ErrNoPrimaryKey)
+
+	_, err = db.Exec("INSERT INTO t1 VALUES (1, 'a')")
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE t1 SET s = 'b' WHERE id = 1")
+	require.NoError(t, err)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, o.Publish(ctx, tx, "mail", "42", "hello"))
+	require.NoError(t, tx.Rollback())
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, o.Publish(ctx, tx, "mail", "43", "hello"))
+	require.NoError(t, tx.Commit())
+
+	fbEvent, err := firebirdsql.NewFBEvent(dsn)
+	require.NoError(t, err)
+	defer fbEvent.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var got []Change
+	err = o.Consume(ctx, fbEvent, func(ctx context.Context, changes []Change) error {
+		got = append(got, changes...)
+		if len(got) == 3 {
+			// delivered after the queue was drained, through the event
+			_, err := db.Exec("DELETE FROM t1")
+			require.NoError(t, err)
+		}
+		if len(got) == 4 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	require.Len(t, got, 4)
+	assert.Equal(t, []string{OpInsert, OpUpdate, OpPublish, OpDelete},
+		[]string{got[0].Operation, got[1].Operation, got[2].Operation, got[3].Operation})
+	assert.Equal(t, "T1", got[0].Source)
+	assert.Equal(t, "1", got[0].Key)
+	assert.Equal(t, "mail", got[2].Source)
+	assert.Equal(t, "hello", got[2].Payload)
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM OUTBOX").Scan(&n))
+	assert.Equal(t, 0, n)
+}
@@ -42,6 +42,7 @@ var (
 const (
 	maxEpbLength       = 65535
 	maxEventNameLength = 255
+	maxEventsPerEpb    = 15 // the server ignores the names after the 15th
 )
 
 type remoteEvent struct {
@@ -91,15 +92,6 @@ func (e *remoteEvent) queueEvents(events ...string) error {
 	return nil
 }
 
-func (e *remoteEvent) cancelEvents() {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	e.events = nil
-	e.counts = nil
-	e.prevCounts = nil
-	atomic.StoreInt32(&e.running, 0)
-}
-
 func (e *remoteEvent) getEventCounts(data []byte) []Event {
 	e.mu.Lock()
 	e.prevCounts = make(map[string]int, len(e.events))
@@ -126,6 +118,10 @@ func (e *remoteEvent) getEventCounts(data []byte) []Event {
 	var result []Event
 	for i := 0; i < len(e.events); i++ {
 		count := e.counts[e.events[i]] - e.prevCounts[e.events[i]]
+		if count < 0 {
+			// the server restarted and counts from zero again
+			count = e.counts[e.events[i]]
+		}
 		if count == 0 {
 			continue
 		}
@@ -0,0 +1,831 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/nakagami/firebirdsql"
+)
+
+// Extract loads the schema of the database q is attached to and returns
+// its DDL script.
+func Extract(ctx context.Context, q Queryer) (string, error) {
+	s, err := Load(ctx, q)
+	if err != nil {
+		return "", err
+	}
+	return s.DDL(), nil
+}
+
+// LoadDDL reads the schema a DDL script creates, e.g. one saved from
+// Schema.DDL, to compare a live database with. There is no DDL parser:
+// the script is run with firebirdsql.ExecScript in a scratch database that
+// is created at cfg with opts and dropped afterwards, so cfg must name a
+// database that does not exist yet.
+func LoadDDL(ctx context.Context, cfg *firebirdsql.Config, opts firebirdsql.CreateOptions, r io.Reader) (s *Schema, err error) {
+	if err = firebirdsql.CreateDatabase(ctx, cfg, opts); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if dropErr := firebirdsql.DropDatabase(ctx, cfg); err == nil {
+			err = dropErr
+		}
+	}()
+	db := sql.OpenDB(firebirdsql.NewConnector(cfg))
+	// the attachment must be gone before the database is dropped
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err = firebirdsql.ExecScript(ctx, conn, r); err != nil {
+		return nil, err
+	}
+	return Load(ctx, conn)
+}
+
+// DDL returns a script that recreates the schema, in the order isql
+// -extract uses: objects are created before they are referenced, and PSQL
+// objects are first created with an empty body and altered to their real
+// body once everything they may use exists. PSQL statements are
+// terminated with ^ inside SET TERM.
+func (s *Schema) DDL() string {
+	w := &ddlWriter{s: s}
+	if s.Dialect != 0 {
+		w.printf("SET SQL DIALECT %d;\n", s.Dialect)
+	}
+	w.udfs()
+	w.generators()
+	w.exceptions()
+	w.domains()
+	w.tables()
+	w.routineHeaders()
+	w.indices()
+	w.foreignKeys()
+	w.views()
+	w.routineBodies()
+	w.checks()
+	w.triggers()
+	w.roles()
+	w.grants()
+	w.comments()
+	return w.b.String()
+}
+
+// Domain returns the domain name, or nil.
+func (s *Schema) Domain(name string) *Domain {
+	for _, d := range s.Domains {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+type ddlWriter struct {
+	s       *Schema
+	b       strings.Builder
+	section string
+	psql    bool
+}
+
+func (w *ddlWriter) printf(format string, args ...any) {
+	fmt.Fprintf(&w.b, format, args...)
+}
+
+// begin starts a section; the comment is written with its first statement.
+func (w *ddlWriter) begin(comment string) {
+	w.section = comment
+}
+
+func (w *ddlWriter) flushSection() {
+	if w.section != "" {
+		w.printf("\n/* %s */\n", w.section)
+		w.section = ""
+	}
+}
+
+// stmt writes a DDL statement.
+func (w *ddlWriter) stmt(s string) {
+	w.endTerm()
+	w.flushSection()
+	w.printf("%s;\n", s)
+}
+
+// psqlStmt writes a statement with PSQL inside SET TERM ^.
+func (w *ddlWriter) psqlStmt(s string) {
+	w.flushSection()
+	if !w.psql {
+		w.printf("SET TERM ^ ;\n")
+		w.psql = true
+	}
+	w.printf("%s^\n\n", s)
+}
+
+func (w *ddlWriter) endTerm() {
+	if w.psql {
+		w.printf("SET TERM ; ^\n")
+		w.psql = false
+	}
+}
+
+func (w *ddlWriter) end() {
+	w.endTerm()
+	w.section = ""
+}
+
+// typeClause renders a type with its character set unless it is the
+// default one of the database.
+func (w *ddlWriter) typeClause(typ string, charset string) string {
+	if charset != "" && charset != w.s.DefaultCharset {
+		return typ + " CHARACTER SET " + charset
+	}
+	return typ
+}
+
+// body returns the PSQL source of a routine, starting with AS.
+func body(source string) string {
+	if len(source) >= 3 && strings.EqualFold(source[:2], "AS") && strings.ContainsAny(source[2:3], " \t\r\n") {
+		return source
+	}
+	return "AS\n" + source
+}
+
+func (w *ddlWriter) udfs() {
+	w.begin("External functions")
+	for _, f := range w.s.Functions {
+		if f.Legacy {
+			w.stmt(w.s.udfDDL(f))
+		}
+	}
+	w.end()
+}
+
+func (s *Schema) udfDDL(f *Function) string {
+	w := &ddlWriter{s: s}
+	var args []string
+	for _, a := range f.Arguments {
+		args = append(args, w.typeClause(a.Type, a.Charset))
+	}
+	ddl := "DECLARE EXTERNAL FUNCTION " + firebirdsql.QuoteIdentifier(f.Name)
+	if len(args) > 0 {
+		ddl += "\n  " + strings.Join(args, ",\n  ")
+	}
+	return ddl + "\n  RETURNS " + f.ReturnType +
+		"\n  ENTRY_POINT " + firebirdsql.QuoteString(f.EntryPoint) + " MODULE_NAME " + firebirdsql.QuoteString(f.ModuleName)
+}
+
+func (w *ddlWriter) generators() {
+	w.begin("Generators or sequences")
+	for _, g := range w.s.Generators {
+		w.stmt(generatorDDL(g))
+	}
+	w.end()
+}
+
+func generatorDDL(g *Generator) string {
+	ddl := "CREATE GENERATOR " + firebirdsql.QuoteIdentifier(g.Name)
+	if g.InitialValue != 0 {
+		ddl += fmt.Sprintf(" START WITH %d", g.InitialValue)
+	}
+	if g.Increment != 1 {
+		ddl += fmt.Sprintf(" INCREMENT BY %d", g.Increment)
+	}
+	return ddl
+}
+
+func (w *ddlWriter) exceptions() {
+	w.begin("Exceptions")
+	for _, e := range w.s.Exceptions {
+		w.stmt("CREATE EXCEPTION " + firebirdsql.QuoteIdentifier(e.Name) + " " + firebirdsql.QuoteString(e.Message))
+	}
+	w.end()
+}
+
+func (w *ddlWriter) domains() {
+	w.begin("Domain definitions")
+	for _, d := range w.s.Domains {
+		w.stmt(DomainDDL(d, w.s.DefaultCharset))
+	}
+	w.end()
+}
+
+// DomainDDL returns the CREATE DOMAIN statement of d. The character set is
+// omitted if it is defaultCharset.
+func DomainDDL(d *Domain, defaultCharset string) string {
+	w := &ddlWriter{s: &Schema{DefaultCharset: defaultCharset}}
+	ddl := "CREATE DOMAIN " + firebirdsql.QuoteIdentifier(d.Name) + " AS " + w.typeClause(d.Type, d.Charset)
+	if d.Default != "" {
+		ddl += " DEFAULT " + d.Default
+	}
+	if !d.Nullable {
+		ddl += " NOT NULL"
+	}
+	if d.Check != "" {
+		ddl += " " + d.Check
+	}
+	if d.Collation != "" {
+		ddl += " COLLATE " + d.Collation
+	}
+	return ddl
+}
+
+// ColumnDDL returns the definition of c as used in CREATE TABLE and
+// ALTER TABLE ADD.
+func (s *Schema) ColumnDDL(c *Column) string {
+	w := &ddlWriter{s: s}
+	def := firebirdsql.QuoteIdentifier(c.Name)
+	if c.ComputedSource != "" {
+		return def + " COMPUTED BY " + c.ComputedSource
+	}
+	if d := s.Domain(c.Domain); d != nil {
+		def += " " + firebirdsql.QuoteIdentifier(d.Name)
+		if c.Default != "" && c.Default != d.Default {
+			def += " DEFAULT " + c.Default
+		}
+		if !c.Nullable && d.Nullable {
+			def += " NOT NULL"
+		}
+		if c.Collation != "" && c.Collation != d.Collation {
+			def += " COLLATE " + c.Collation
+		}
+		return def
+	}
+	def += " " + w.typeClause(c.Type, c.Charset)
+	if c.Identity != "" {
+		def += " GENERATED " + c.Identity + " AS IDENTITY"
+	}
+	if c.Default != "" {
+		def += " DEFAULT " + c.Default
+	}
+	if !c.Nullable {
+		def += " NOT NULL"
+	}
+	if c.Collation != "" {
+		def += " COLLATE " + c.Collation
+	}
+	return def
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = firebirdsql.QuoteIdentifier(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (t *Table) index(name string) *Index {
+	for _, ix := range t.Indices {
+		if ix.Name == name {
+			return ix
+		}
+	}
+	return nil
+}
+
+// ConstraintDDL returns the definition of c as used in CREATE TABLE and
+// ALTER TABLE ADD.
+func (t *Table) ConstraintDDL(c *Constraint) string {
+	var def string
+	// INTEG_n names are generated by the server
+	if !strings.HasPrefix(c.Name, "INTEG_") {
+		def = "CONSTRAINT " + firebirdsql.QuoteIdentifier(c.Name) + " "
+	}
+	switch c.Type {
+	case Check:
+		return def + c.Source
+	case ForeignKey:
+		def += "FOREIGN KEY (" + quoteNames(c.Columns) + ") REFERENCES " +
+			firebirdsql.QuoteIdentifier(c.ReferencedTable) + " (" + quoteNames(c.ReferencedColumns) + ")"
+		if c.UpdateRule != "" && c.UpdateRule != "RESTRICT" {
+			def += " ON UPDATE " + c.UpdateRule
+		}
+		if c.DeleteRule != "" && c.DeleteRule != "RESTRICT" {
+			def += " ON DELETE " + c.DeleteRule
+		}
+	default:
+		def += c.Type + " (" + quoteNames(c.Columns) + ")"
+	}
+	// RDB$xxx index names are generated by the server
+	if ix := t.index(c.Index); ix != nil && !strings.HasPrefix(ix.Name, "RDB$") && (ix.Name != c.Name || ix.Descending) {
+		def += " USING "
+		if ix.Descending {
+			def += "DESC "
+		}
+		def += "INDEX " + firebirdsql.QuoteIdentifier(ix.Name)
+	}
+	return def
+}
+
+func (w *ddlWriter) tables() {
+	w.begin("Table definitions")
+	for _, t := range w.s.Tables {
+		w.stmt(w.s.TableDDL(t))
+	}
+	w.end()
+}
+
+// TableDDL returns the CREATE TABLE statement of t with its primary key and
+// unique constraints.
+func (s *Schema) TableDDL(t *Table) string {
+	ddl := "CREATE TABLE "
+	if t.Temporary != "" {
+		ddl = "CREATE GLOBAL TEMPORARY TABLE "
+	}
+	ddl += firebirdsql.QuoteIdentifier(t.Name)
+	if t.ExternalFile != "" {
+		ddl += " EXTERNAL FILE " + firebirdsql.QuoteString(t.ExternalFile)
+	}
+	var defs []string
+	for _, c := range t.Columns {
+		defs = append(defs, s.ColumnDDL(c))
+	}
+	for _, c := range t.Constraints {
+		if c.Type == PrimaryKey || c.Type == Unique {
+			defs = append(defs, t.ConstraintDDL(c))
+		}
+	}
+	ddl += " (\n  " + strings.Join(defs, ",\n  ") + ")"
+	if t.Temporary != "" {
+		ddl += " ON COMMIT " + t.Temporary
+	}
+	return ddl
+}
+
+func (w *ddlWriter) indices() {
+	w.begin("Index definitions")
+	for _, t := range w.s.Tables {
+		for _, ix := range t.Indices {
+			if ix.Constraint != "" {
+				continue
+			}
+			w.stmt(IndexDDL(ix))
+			if !ix.Active {
+				w.stmt("ALTER INDEX " + firebirdsql.QuoteIdentifier(ix.Name) + " INACTIVE")
+			}
+		}
+	}
+	w.end()
+}
+
+// IndexDDL returns the CREATE INDEX statement of ix.
+func IndexDDL(ix *Index) string {
+	ddl := "CREATE "
+	if ix.Unique {
+		ddl += "UNIQUE "
+	}
+	if ix.Descending {
+		ddl += "DESCENDING "
+	}
+	ddl += "INDEX " + firebirdsql.QuoteIdentifier(ix.Name) + " ON " + firebirdsql.QuoteIdentifier(ix.Table)
+	if ix.Expression != "" {
+		expr := ix.Expression
+		if !strings.HasPrefix(expr, "(") {
+			expr = "(" + expr + ")"
+		}
+		ddl += " COMPUTED BY " + expr
+	} else {
+		ddl += " (" + quoteNames(ix.Columns) + ")"
+	}
+	if ix.Condition != "" {
+		ddl += " WHERE " + ix.Condition
+	}
+	return ddl
+}
+
+func (w *ddlWriter) foreignKeys() {
+	w.begin("Foreign key constraints")
+	for _, t := range w.s.Tables {
+		for _, c := range t.Constraints {
+			if c.Type == ForeignKey {
+				w.stmt("ALTER TABLE " + firebirdsql.QuoteIdentifier(t.Name) + " ADD " + t.ConstraintDDL(c))
+			}
+		}
+	}
+	w.end()
+}
+
+func (w *ddlWriter) checks() {
+	w.begin("Check constraints")
+	for _, t := range w.s.Tables {
+		for _, c := range t.Constraints {
+			if c.Type == Check {
+				w.stmt("ALTER TABLE " + firebirdsql.QuoteIdentifier(t.Name) + " ADD " + t.ConstraintDDL(c))
+			}
+		}
+	}
+	w.end()
+}
+
+func (w *ddlWriter) views() {
+	w.begin("View definitions")
+	for _, v := range orderViews(w.s.Views) {
+		w.stmt(ViewDDL(v))
+	}
+	w.end()
+}
+
+// ViewDDL returns the CREATE VIEW statement of v.
+func ViewDDL(v *View) string {
+	names := make([]string, len(v.Columns))
+	for i, c := range v.Columns {
+		names[i] = c.Name
+	}
+	return "CREATE VIEW " + firebirdsql.QuoteIdentifier(v.Name) + " (" + quoteNames(names) + ") AS\n" + v.Source
+}
+
+// orderViews sorts views so that a view comes after the views its source
+// mentions. Cycles, which the server does not allow, keep their order.
+func orderViews(views []*View) []*View {
+	patterns := make(map[*View]*regexp.Regexp)
+	for _, v := range views {
+		patterns[v] = regexp.MustCompile(`(?i)(^|[^A-Za-z0-9_$])` + regexp.QuoteMeta(v.Name) + `($|[^A-Za-z0-9_$])`)
+	}
+	var ordered []*View
+	remaining := append([]*View(nil), views...)
+	for len(remaining) > 0 {
+		var next []*View
+		for _, v := range remaining {
+			ready := true
+			for _, other := range remaining {
+				if other != v && patterns[other].MatchString(v.Source) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, v)
+			} else {
+				next = append(next, v)
+			}
+		}
+		if len(next) == len(remaining) {
+			return append(ordered, next...)
+		}
+		remaining = next
+	}
+	return ordered
+}
+
+// ParameterDDL returns the declaration of a routine parameter.
+func (s *Schema) ParameterDDL(p *Parameter) string {
+	w := &ddlWriter{s: s}
+	def := firebirdsql.QuoteIdentifier(p.Name) + " "
+	switch {
+	case p.TypeOf && p.Relation != "":
+		def += "TYPE OF COLUMN " + firebirdsql.QuoteIdentifier(p.Relation) + "." + firebirdsql.QuoteIdentifier(p.Field)
+	case p.TypeOf:
+		def += "TYPE OF " + firebirdsql.QuoteIdentifier(p.Domain)
+	case p.Domain != "":
+		def += firebirdsql.QuoteIdentifier(p.Domain)
+	default:
+		def += w.typeClause(p.Type, p.Charset)
+	}
+	if !p.Nullable {
+		def += " NOT NULL"
+	}
+	if p.Collation != "" && p.Domain == "" {
+		def += " COLLATE " + p.Collation
+	}
+	if p.Default != "" {
+		def += " = " + p.Default
+	}
+	return def
+}
+
+func (s *Schema) parameterList(params []*Parameter) string {
+	defs := make([]string, len(params))
+	for i, p := range params {
+		defs[i] = s.ParameterDDL(p)
+	}
+	return "(\n  " + strings.Join(defs, ",\n  ") + ")"
+}
+
+// ProcedureHeader returns the name and parameters of p.
+func (s *Schema) ProcedureHeader(p *Procedure) string {
+	h := firebirdsql.QuoteIdentifier(p.Name)
+	if len(p.Inputs) > 0 {
+		h += " " + s.parameterList(p.Inputs)
+	}
+	if len(p.Outputs) > 0 {
+		h += "\nRETURNS " + s.parameterList(p.Outputs)
+	}
+	return h
+}
+
+// FunctionHeader returns the name, arguments and return type of f.
+func (s *Schema) FunctionHeader(f *Function) string {
+	w := &ddlWriter{s: s}
+	h := firebirdsql.QuoteIdentifier(f.Name)
+	if len(f.Arguments) > 0 {
+		h += " " + s.parameterList(f.Arguments)
+	} else {
+		h += " ()"
+	}
+	h += "\nRETURNS " + w.typeClause(f.ReturnType, f.ReturnCharset)
+	if f.Deterministic {
+		h += " DETERMINISTIC"
+	}
+	return h
+}
+
+// functionStub creates f with an empty body, or the external function.
+func (s *Schema) functionStub(f *Function) string {
+	if f.Engine != "" {
+		return "CREATE OR ALTER FUNCTION " + s.FunctionHeader(f) +
+			"\nEXTERNAL NAME " + firebirdsql.QuoteString(f.EntryPoint) + " ENGINE " + firebirdsql.QuoteIdentifier(f.Engine)
+	}
+	return "CREATE OR ALTER FUNCTION " + s.FunctionHeader(f) + "\nAS\nBEGIN\n  RETURN NULL;\nEND"
+}
+
+func (s *Schema) functionBody(f *Function) string {
+	return "ALTER FUNCTION " + s.FunctionHeader(f) + "\n" + body(f.Source)
+}
+
+// procedureStub creates p with an empty body, or the external procedure.
+func (s *Schema) procedureStub(p *Procedure) string {
+	if p.Engine != "" {
+		return "CREATE OR ALTER PROCEDURE " + s.ProcedureHeader(p) +
+			"\nEXTERNAL NAME " + firebirdsql.QuoteString(p.EntryPoint) + " ENGINE " + firebirdsql.QuoteIdentifier(p.Engine)
+	}
+	stub := "EXIT;"
+	if p.Selectable && len(p.Outputs) > 0 {
+		stub = "SUSPEND;"
+	}
+	return "CREATE OR ALTER PROCEDURE " + s.ProcedureHeader(p) + "\nAS\nBEGIN\n  " + stub + "\nEND"
+}
+
+func (s *Schema) procedureBody(p *Procedure) string {
+	return "ALTER PROCEDURE " + s.ProcedureHeader(p) + "\n" + body(p.Source)
+}
+
+func packageHeaderDDL(p *Package) string {
+	return "CREATE OR ALTER PACKAGE " + firebirdsql.QuoteIdentifier(p.Name) + "\n" + body(p.Header)
+}
+
+func packageBodyDDL(p *Package) string {
+	return "RECREATE PACKAGE BODY " + firebirdsql.QuoteIdentifier(p.Name) + "\n" + body(p.Body)
+}
+
+func (w *ddlWriter) routineHeaders() {
+	w.begin("Stored functions headers")
+	for _, f := range w.s.Functions {
+		if !f.Legacy {
+			w.psqlStmt(w.s.functionStub(f))
+		}
+	}
+	w.end()
+
+	w.begin("Stored procedures headers")
+	for _, p := range w.s.Procedures {
+		w.psqlStmt(w.s.procedureStub(p))
+	}
+	w.end()
+
+	w.begin("Package headers")
+	for _, p := range w.s.Packages {
+		w.psqlStmt(packageHeaderDDL(p))
+	}
+	w.end()
+}
+
+func (w *ddlWriter) routineBodies() {
+	w.begin("Stored functions bodies")
+	for _, f := range w.s.Functions {
+		if !f.Legacy && f.Engine == "" {
+			w.psqlStmt(w.s.functionBody(f))
+		}
+	}
+	w.end()
+
+	w.begin("Stored procedures bodies")
+	for _, p := range w.s.Procedures {
+		if p.Engine == "" {
+			w.psqlStmt(w.s.procedureBody(p))
+		}
+	}
+	w.end()
+
+	w.begin("Package bodies")
+	for _, p := range w.s.Packages {
+		if p.Body != "" {
+			w.psqlStmt(packageBodyDDL(p))
+		}
+	}
+	w.end()
+}
+
+// TriggerDDL returns the CREATE TRIGGER statement of t, without terminator.
+func TriggerDDL(t *Trigger) string {
+	ddl := "CREATE TRIGGER " + firebirdsql.QuoteIdentifier(t.Name)
+	if t.Table != "" {
+		ddl += " FOR " + firebirdsql.QuoteIdentifier(t.Table)
+	}
+	if t.Active {
+		ddl += " ACTIVE "
+	} else {
+		ddl += " INACTIVE "
+	}
+	return ddl + t.Event + fmt.Sprintf(" POSITION %d\n", t.Position) + body(t.Source)
+}
+
+func (w *ddlWriter) triggers() {
+	w.begin("Triggers")
+	for _, t := range w.s.Triggers {
+		w.psqlStmt(TriggerDDL(t))
+	}
+	w.end()
+}
+
+func (w *ddlWriter) roles() {
+	w.begin("Roles")
+	for _, r := range w.s.Roles {
+		w.stmt("CREATE ROLE " + firebirdsql.QuoteIdentifier(r.Name))
+	}
+	w.end()
+}
+
+var grantObjectKeywords = map[string]string{
+	"TABLE":         "TABLE",
+	"VIEW":          "TABLE",
+	"PROCEDURE":     "PROCEDURE",
+	"FUNCTION":      "FUNCTION",
+	"PACKAGE":       "PACKAGE",
+	"GENERATOR":     "GENERATOR",
+	"EXCEPTION":     "EXCEPTION",
+	"DOMAIN":        "DOMAIN",
+	"CHARACTER SET": "CHARACTER SET",
+	"COLLATION":     "COLLATION",
+}
+
+func granteeClause(g *Grant) string {
+	switch g.GranteeType {
+	case "", "USER":
+		return firebirdsql.QuoteIdentifier(g.Grantee)
+	}
+	return g.GranteeType + " " + firebirdsql.QuoteIdentifier(g.Grantee)
+}
+
+// GrantDDL returns the GRANT statements for grants; privileges of the same
+// grantee on the same object are combined.
+func GrantDDL(grants []*Grant) []string {
+	type key struct {
+		object, column, grantee string
+		grantOption             bool
+	}
+	var (
+		order      []key
+		privileges = make(map[key][]string)
+		first      = make(map[key]*Grant)
+		statements []string
+	)
+	for _, g := range grants {
+		if g.Privilege == "MEMBER" {
+			ddl := "GRANT " + firebirdsql.QuoteIdentifier(g.ObjectName) + " TO " + granteeClause(g)
+			if g.GrantOption {
+				ddl += " WITH ADMIN OPTION"
+			}
+			statements = append(statements, ddl)
+			continue
+		}
+		keyword, ok := grantObjectKeywords[g.ObjectType]
+		if !ok {
+			continue
+		}
+		k := key{keyword + " " + firebirdsql.QuoteIdentifier(g.ObjectName), g.Column, granteeClause(g), g.GrantOption}
+		if _, ok = first[k]; !ok {
+			order = append(order, k)
+			first[k] = g
+		}
+		privileges[k] = append(privileges[k], g.Privilege)
+	}
+	for _, k := range order {
+		list := privileges[k]
+		if k.column == "" && len(list) == 5 && containsAll(list, "SELECT", "INSERT", "UPDATE", "DELETE", "REFERENCES") {
+			list = []string{"ALL"}
+		}
+		if k.column != "" {
+			// each privilege takes its own column list
+			column := " (" + firebirdsql.QuoteIdentifier(k.column) + ")"
+			list = append([]string(nil), list...)
+			for i := range list {
+				list[i] += column
+			}
+		}
+		privilege := strings.Join(list, ", ")
+		ddl := "GRANT " + privilege + " ON " + k.object + " TO " + k.grantee
+		if k.grantOption {
+			ddl += " WITH GRANT OPTION"
+		}
+		statements = append(statements, ddl)
+	}
+	return statements
+}
+
+func containsAll(list []string, values ...string) bool {
+	for _, v := range values {
+		found := false
+		for _, s := range list {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *ddlWriter) grants() {
+	w.begin("Grant permissions")
+	for _, ddl := range GrantDDL(w.s.Grants) {
+		w.stmt(ddl)
+	}
+	w.end()
+}
+
+func (w *ddlWriter) comment(kind string, name string, description string) {
+	if description != "" {
+		w.stmt("COMMENT ON " + kind + " " + name + " IS " + firebirdsql.QuoteString(description))
+	}
+}
+
+func (w *ddlWriter) comments() {
+	w.begin("Comments")
+	s := w.s
+	for _, d := range s.Domains {
+		w.comment("DOMAIN", firebirdsql.QuoteIdentifier(d.Name), d.Description)
+	}
+	for _, t := range s.Tables {
+		w.comment("TABLE", firebirdsql.QuoteIdentifier(t.Name), t.Description)
+		for _, c := range t.Columns {
+			w.comment("COLUMN", firebirdsql.QuoteIdentifier(t.Name)+"."+firebirdsql.QuoteIdentifier(c.Name), c.Description)
+		}
+		for _, ix := range t.Indices {
+			w.comment("INDEX", firebirdsql.QuoteIdentifier(ix.Name), ix.Description)
+		}
+	}
+	for _, v := range s.Views {
+		w.comment("VIEW", firebirdsql.QuoteIdentifier(v.Name), v.Description)
+		for _, c := range v.Columns {
+			w.comment("COLUMN", firebirdsql.QuoteIdentifier(v.Name)+"."+firebirdsql.QuoteIdentifier(c.Name), c.Description)
+		}
+	}
+	for _, g := range s.Generators {
+		w.comment("GENERATOR", firebirdsql.QuoteIdentifier(g.Name), g.Description)
+	}
+	for _, e := range s.Exceptions {
+		w.comment("EXCEPTION", firebirdsql.QuoteIdentifier(e.Name), e.Description)
+	}
+	for _, p := range s.Procedures {
+		w.comment("PROCEDURE", firebirdsql.QuoteIdentifier(p.Name), p.Description)
+	}
+	for _, f := range s.Functions {
+		kind := "FUNCTION"
+		if f.Legacy {
+			kind = "EXTERNAL FUNCTION"
+		}
+		w.comment(kind, firebirdsql.QuoteIdentifier(f.Name), f.Description)
+	}
+	for _, p := range s.Packages {
+		w.comment("PACKAGE", firebirdsql.QuoteIdentifier(p.Name), p.Description)
+	}
+	for _, t := range s.Triggers {
+		w.comment("TRIGGER", firebirdsql.QuoteIdentifier(t.Name), t.Description)
+	}
+	for _, r := range s.Roles {
+		w.comment("ROLE", firebirdsql.QuoteIdentifier(r.Name), r.Description)
+	}
+	w.end()
+}
@@ -0,0 +1,236 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nakagami/firebirdsql"
+	"github.com/nakagami/firebirdsql/internal/fbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// testSchema returns a schema with one object of each kind.
+func testSchema() *Schema {
+	customer := &Table{
+		Name: "CUSTOMER",
+		Columns: []*Column{
+			{Name: "ID", Position: 0, Type: "INTEGER", Identity: "BY DEFAULT"},
+			{Name: "NAME", Position: 1, Domain: "D_NAME", Type: "VARCHAR(40)", Charset: "UTF8"},
+			{Name: "NOTE", Position: 2, Type: "VARCHAR(100)", Charset: "UTF8", Collation: "UNICODE_CI", Nullable: true, Default: "'none'", Description: "free text"},
+		},
+		Constraints: []*Constraint{
+			{Name: "PK_CUSTOMER", Type: PrimaryKey, Columns: []string{"ID"}, Index: "PK_CUSTOMER"},
+			{Name: "INTEG_5", Type: Unique, Columns: []string{"NAME"}, Index: "RDB$4"},
+		},
+		Indices: []*Index{
+			{Name: "PK_CUSTOMER", Table: "CUSTOMER", Columns: []string{"ID"}, Unique: true, Active: true, Constraint: "PK_CUSTOMER"},
+			{Name: "RDB$4", Table: "CUSTOMER", Columns: []string{"NAME"}, Unique: true, Active: true, Constraint: "INTEG_5"},
+		},
+	}
+	orders := &Table{
+		Name: "ORDERS",
+		Columns: []*Column{
+			{Name: "ID", Type: "INTEGER"},
+			{Name: "CUSTOMER_ID", Type: "INTEGER"},
+			{Name: "AMOUNT", Domain: "D_AMOUNT", Type: "NUMERIC(18,2)", Nullable: true, Default: "0"},
+			{Name: "TOTAL", Type: "NUMERIC(18,2)", Nullable: true, ComputedSource: "(amount * 2)"},
+			{Name: "Order", Type: "DATE", Nullable: true},
+		},
+		Constraints: []*Constraint{
+			{Name: "PK_ORDERS", Type: PrimaryKey, Columns: []string{"ID"}, Index: "IX_PK_ORDERS"},
+			{Name: "FK_ORDERS_CUSTOMER", Type: ForeignKey, Columns: []string{"CUSTOMER_ID"}, Index: "FK_ORDERS_CUSTOMER",
+				ReferencedTable: "CUSTOMER", ReferencedColumns: []string{"ID"}, UpdateRule: "RESTRICT", DeleteRule: "CASCADE"},
+			{Name: "CK_ORDERS_ID", Type: Check, Source: "CHECK (id > 0)"},
+		},
+		Indices: []*Index{
+			{Name: "FK_ORDERS_CUSTOMER", Table: "ORDERS", Columns: []string{"CUSTOMER_ID"}, Active: true, Constraint: "FK_ORDERS_CUSTOMER"},
+			{Name: "IX_ORDERS_AMOUNT", Table: "ORDERS", Columns: []string{"AMOUNT"}, Descending: true},
+			{Name: "IX_ORDERS_TOTAL", Table: "ORDERS", Expression: "(amount * 2)", Condition: "amount > 0", Active: true},
+			{Name: "IX_PK_ORDERS", Table: "ORDERS", Columns: []string{"ID"}, Unique: true, Active: true, Constraint: "PK_ORDERS"},
+		},
+	}
+	return &Schema{
+		Version:        Version{Major: 4, Minor: 0},
+		Dialect:        3,
+		DefaultCharset: "UTF8",
+		Domains: []*Domain{
+			{Name: "D_AMOUNT", Type: "NUMERIC(18,2)", Nullable: true, Default: "0", Check: "CHECK (VALUE >= 0)", Description: "money"},
+			{Name: "D_NAME", Type: "VARCHAR(40)", Charset: "UTF8"},
+		},
+		Tables: []*Table{customer, orders},
+		Views: []*View{
+			{Name: "TOP_ORDERS", Columns: []*Column{{Name: "ID"}}, Source: "SELECT id FROM big_orders WHERE amount > 1000"},
+			{Name: "BIG_ORDERS", Columns: []*Column{{Name: "ID"}, {Name: "AMOUNT"}}, Source: "SELECT id, amount FROM orders WHERE amount > 100"},
+		},
+		Generators: []*Generator{{Name: "GEN_ORDERS", InitialValue: 100, Increment: 1}},
+		Exceptions: []*Exception{{Name: "E_BAD", Message: "it's bad"}},
+		Procedures: []*Procedure{{
+			Name:       "ADD_ORDER",
+			Selectable: true,
+			Inputs: []*Parameter{
+				{Name: "ID", Type: "INTEGER", Nullable: true},
+				{Name: "AMOUNT", Domain: "D_AMOUNT", TypeOf: true, Nullable: true, Default: "1"},
+			},
+			Outputs: []*Parameter{{Name: "N", Type: "INTEGER", Nullable: true, Output: true}},
+			Source:  "BEGIN\n  n = id;\n  SUSPEND;\nEND",
+		}},
+		Functions: []*Function{
+			{Name: "TWICE", Arguments: []*Parameter{{Name: "X", Type: "INTEGER", Nullable: true}}, ReturnType: "INTEGER", Deterministic: true, Source: "BEGIN\n  RETURN x * 2;\nEND"},
+			{Name: "ABS", Legacy: true, Arguments: []*Parameter{{Type: "DOUBLE PRECISION"}}, ReturnType: "DOUBLE PRECISION", EntryPoint: "IB_UDF_abs", ModuleName: "ib_udf"},
+		},
+		Packages: []*Package{{
+			Name:   "PKG",
+			Header: "BEGIN\n  FUNCTION one RETURNS INTEGER;\nEND",
+			Body:   "BEGIN\n  FUNCTION one RETURNS INTEGER AS BEGIN RETURN 1; END\nEND",
+		}},
+		Triggers: []*Trigger{
+			{Name: "ORDERS_BI", Table: "ORDERS", Event: "BEFORE INSERT OR UPDATE", Position: 5, Active: true, Source: "AS\nBEGIN\nEND"},
+			{Name: "TR_CONNECT", Event: "ON CONNECT", Source: "AS\nBEGIN\nEND"},
+		},
+		Roles: []*Role{{Name: "CLERK", Owner: "SYSDBA"}},
+		Grants: []*Grant{
+			{Privilege: "MEMBER", ObjectType: "ROLE", ObjectName: "CLERK", Grantee: "ALICE", GranteeType: "USER", GrantOption: true},
+			{Privilege: "DELETE", ObjectType: "TABLE", ObjectName: "ORDERS", Grantee: "CLERK", GranteeType: "ROLE"},
+			{Privilege: "INSERT", ObjectType: "TABLE", ObjectName: "ORDERS", Grantee: "CLERK", GranteeType: "ROLE"},
+			{Privilege: "REFERENCES", ObjectType: "TABLE", ObjectName: "ORDERS", Grantee: "CLERK", GranteeType: "ROLE"},
+			{Privilege: "SELECT", ObjectType: "TABLE", ObjectName: "ORDERS", Grantee: "CLERK", GranteeType: "ROLE"},
+			{Privilege: "UPDATE", ObjectType: "TABLE", ObjectName: "ORDERS", Grantee: "CLERK", GranteeType: "ROLE"},
+			{Privilege: "UPDATE", ObjectType: "TABLE", ObjectName: "CUSTOMER", Column: "NOTE", Grantee: "ALICE", GranteeType: "USER"},
+			{Privilege: "EXECUTE", ObjectType: "PROCEDURE", ObjectName: "ADD_ORDER", Grantee: "PUBLIC", GrantOption: true},
+		},
+	}
+}
+
+func TestDDLGolden(t *testing.T) {
+	got := testSchema().DDL()
+	golden := filepath.Join("testdata", "extract.sql")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll("testdata", 0755))
+		require.NoError(t, os.WriteFile(golden, []byte(got), 0644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), got)
+}
+
+func TestGrantDDL(t *testing.T) {
+	grants := []*Grant{
+		{Privilege: "UPDATE", ObjectType: "TABLE", ObjectName: "CUSTOMER", Column: "NOTE", Grantee: "ALICE", GranteeType: "USER"},
+		{Privilege: "REFERENCES", ObjectType: "TABLE", ObjectName: "CUSTOMER", Column: "NOTE", Grantee: "ALICE", GranteeType: "USER"},
+		{Privilege: "SELECT", ObjectType: "TABLE", ObjectName: "CUSTOMER", Grantee: "ALICE", GranteeType: "USER"},
+	}
+	assert.Equal(t, []string{
+		"GRANT UPDATE (NOTE), REFERENCES (NOTE) ON TABLE CUSTOMER TO ALICE",
+		"GRANT SELECT ON TABLE CUSTOMER TO ALICE",
+	}, GrantDDL(grants))
+}
+
+func TestExternalProcedureDDL(t *testing.T) {
+	s := &Schema{Procedures: []*Procedure{{
+		Name:       "GEN_ROWS",
+		Selectable: true,
+		Inputs:     []*Parameter{{Name: "N", Type: "INTEGER", Nullable: true}},
+		Outputs:    []*Parameter{{Name: "RESULT", Output: true, Type: "INTEGER", Nullable: true}},
+		EntryPoint: "udrcpp_example!gen_rows",
+		Engine:     "UDR",
+	}}}
+	ddl := s.DDL()
+	assert.Contains(t, ddl, "CREATE OR ALTER PROCEDURE GEN_ROWS (\n  N INTEGER)\nRETURNS (\n  RESULT INTEGER)\nEXTERNAL NAME 'udrcpp_example!gen_rows' ENGINE UDR^")
+	assert.NotContains(t, ddl, "\nALTER PROCEDURE")
+
+	script := Compare(&Schema{}, s).Script()
+	assert.Contains(t, script, "EXTERNAL NAME 'udrcpp_example!gen_rows' ENGINE UDR^")
+	assert.NotContains(t, script, "\nALTER PROCEDURE")
+}
+
+func TestOrderViews(t *testing.T) {
+	s := testSchema()
+	views := orderViews(s.Views)
+	require.Len(t, views, 2)
+	assert.Equal(t, "BIG_ORDERS", views[0].Name)
+	assert.Equal(t, "TOP_ORDERS", views[1].Name)
+}
+
+func TestExtract(t *testing.T) {
+	db, err := sql.Open("firebirdsql_createdb", fbtest.GetTestDSN("test_extract_"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE t1 (id INTEGER NOT NULL PRIMARY KEY, name VARCHAR(10))",
+		"CREATE VIEW v1 AS SELECT id FROM t1",
+		"CREATE ROLE r1",
+		"GRANT SELECT ON t1 TO ROLE r1",
+		"COMMENT ON TABLE t1 IS 'first table'",
+	} {
+		_, err = db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	ddl, err := Extract(context.Background(), db)
+	require.NoError(t, err)
+	assert.Contains(t, ddl, "CREATE TABLE T1 (\n  ID INTEGER NOT NULL,\n  NAME VARCHAR(10),\n  PRIMARY KEY (ID));")
+	assert.Contains(t, ddl, "CREATE VIEW V1 (ID) AS\nSELECT id FROM t1;")
+	assert.Contains(t, ddl, "CREATE ROLE R1;")
+	assert.Contains(t, ddl, "GRANT SELECT ON TABLE T1 TO ROLE R1;")
+	assert.Contains(t, ddl, "COMMENT ON TABLE T1 IS 'first table';")
+}
+
+func TestLoadDDL(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("firebirdsql_createdb", fbtest.GetTestDSN("test_load_ddl_"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE t1 (id INTEGER NOT NULL PRIMARY KEY, name VARCHAR(10))",
+		"CREATE VIEW v1 AS SELECT id FROM t1",
+		"CREATE PROCEDURE p1 RETURNS (n INTEGER) AS BEGIN SELECT COUNT(*) FROM v1 INTO n; SUSPEND; END",
+	} {
+		_, err = db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	live, err := Load(ctx, db)
+	require.NoError(t, err)
+
+	cfg, err := firebirdsql.ParseConfig(fbtest.GetTestDSN("test_load_ddl_snapshot_"))
+	require.NoError(t, err)
+	snapshot, err := LoadDDL(ctx, cfg, firebirdsql.CreateOptions{}, strings.NewReader(live.DDL()))
+	require.NoError(t, err)
+	diff := Compare(live, snapshot)
+	assert.True(t, diff.Empty(), diff.Script())
+
+	// the scratch database is dropped
+	assert.Error(t, firebirdsql.DropDatabase(ctx, cfg))
+}
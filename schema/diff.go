@@ -0,0 +1,916 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nakagami/firebirdsql"
+)
+
+// Actions of a Change.
+const (
+	ActionCreate   = "create"
+	ActionDrop     = "drop"
+	ActionAlter    = "alter"
+	ActionRecreate = "recreate" // dropped and created again, e.g. a view whose table changes
+)
+
+// Change is one difference between two schemas with the statements that
+// apply it.
+type Change struct {
+	Action     string   `json:"action"`
+	ObjectType string   `json:"object_type"` // DOMAIN, TABLE, COLUMN, CONSTRAINT, INDEX, VIEW, ...
+	Table      string   `json:"table,omitempty"`
+	Name       string   `json:"name"`
+	Details    []string `json:"details,omitempty"` // e.g. "type: VARCHAR(10) -> VARCHAR(20)"
+	Warning    string   `json:"warning,omitempty"` // data is lost, or the change has to be done by hand
+	SQL        []string `json:"sql"`
+	Steps      []Step   `json:"steps"` // SQL with its place in the script
+
+	diff *Diff
+}
+
+// Diff is the list of changes that turn one schema into another.
+type Diff struct {
+	Changes []*Change `json:"changes"`
+
+	steps int
+}
+
+// Step is a statement of a Change with its place in Diff.Script, so a Diff
+// decoded from JSON is scripted in the same order.
+type Step struct {
+	Phase int    `json:"phase"`
+	Seq   int    `json:"seq"` // order of the statement within its phase
+	SQL   string `json:"sql"`
+	PSQL  bool   `json:"psql,omitempty"` // written inside SET TERM
+}
+
+// Phases of the script. Dependents are dropped before and created after
+// the objects they depend on.
+const (
+	phaseDropTrigger = iota
+	phaseDropView
+	phaseStubRoutine // PSQL bodies depending on a changed procedure
+	phaseDropForeignKey
+	phaseDropConstraint
+	phaseDropIndex
+	phaseDropRoutine
+	phaseDropColumn
+	phaseDropTable
+	phaseDropDomain
+	phaseDropOther
+	phaseCreateOther
+	phaseDomain
+	phaseTable
+	phaseColumn
+	phaseConstraint
+	phaseIndex
+	phaseForeignKey
+	phaseRoutineHeader
+	phaseView
+	phaseRoutineBody
+	phaseCheck
+	phaseTrigger
+)
+
+// Empty reports whether the schemas are the same.
+func (d *Diff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Script returns the statements of all changes in dependency order, with
+// PSQL statements inside SET TERM ^. The order is taken from the Steps of
+// each change.
+func (d *Diff) Script() string {
+	var steps []Step
+	for _, c := range d.Changes {
+		steps = append(steps, c.Steps...)
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		if steps[i].Phase != steps[j].Phase {
+			return steps[i].Phase < steps[j].Phase
+		}
+		return steps[i].Seq < steps[j].Seq
+	})
+	w := &ddlWriter{}
+	for _, st := range steps {
+		if st.PSQL {
+			w.psqlStmt(st.SQL)
+		} else {
+			w.stmt(st.SQL)
+		}
+	}
+	w.endTerm()
+	return w.b.String()
+}
+
+type differ struct {
+	from, to *Schema
+	diff     *Diff
+	// tables whose columns are altered or dropped
+	alteredTables map[string]bool
+	// procedures whose parameters change or that are dropped
+	alteredProcedures map[string]bool
+}
+
+// Compare returns the changes that turn the schema from into to. Statements
+// are rendered for to. A snapshot of a schema to compare with a live
+// database is either the JSON encoding of Schema or a DDL script read with
+// LoadDDL.
+func Compare(from *Schema, to *Schema) *Diff {
+	d := &differ{from: from, to: to, diff: &Diff{}, alteredTables: make(map[string]bool)}
+	d.alteredProcedures = alteredProcedures(from, to)
+	d.generators()
+	d.exceptions()
+	d.domains()
+	d.tables()
+	d.views()
+	d.functions()
+	d.procedures()
+	d.packages()
+	d.triggers()
+	d.roles()
+	return d.diff
+}
+
+func (d *differ) add(action string, objectType string, table string, name string) *Change {
+	c := &Change{Action: action, ObjectType: objectType, Table: table, Name: name, diff: d.diff}
+	d.diff.Changes = append(d.diff.Changes, c)
+	return c
+}
+
+func (c *Change) stmt(phase int, sql string) {
+	c.diff.steps++
+	c.Steps = append(c.Steps, Step{Phase: phase, Seq: c.diff.steps, SQL: sql})
+	c.SQL = append(c.SQL, sql)
+}
+
+func (c *Change) psqlStmt(phase int, sql string) {
+	c.stmt(phase, sql)
+	c.Steps[len(c.Steps)-1].PSQL = true
+}
+
+func (c *Change) detail(what string, from any, to any) {
+	c.Details = append(c.Details, fmt.Sprintf("%s: %v -> %v", what, from, to))
+}
+
+// names returns the names of objects in order, and a lookup by name.
+func names[T any](objects []T, name func(T) string) ([]string, map[string]T) {
+	var list []string
+	m := make(map[string]T)
+	for _, o := range objects {
+		list = append(list, name(o))
+		m[name(o)] = o
+	}
+	return list, m
+}
+
+func (d *differ) generators() {
+	fromNames, from := names(d.from.Generators, func(g *Generator) string { return g.Name })
+	toNames, to := names(d.to.Generators, func(g *Generator) string { return g.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "GENERATOR", "", name).stmt(phaseDropOther, "DROP GENERATOR "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+	for _, name := range toNames {
+		g := to[name]
+		old := from[name]
+		if old == nil {
+			d.add(ActionCreate, "GENERATOR", "", name).stmt(phaseCreateOther, generatorDDL(g))
+		} else if old.Increment != g.Increment {
+			c := d.add(ActionAlter, "GENERATOR", "", name)
+			c.detail("increment", old.Increment, g.Increment)
+			c.stmt(phaseCreateOther, fmt.Sprintf("ALTER SEQUENCE %s INCREMENT BY %d", firebirdsql.QuoteIdentifier(name), g.Increment))
+		}
+	}
+}
+
+func (d *differ) exceptions() {
+	fromNames, from := names(d.from.Exceptions, func(e *Exception) string { return e.Name })
+	toNames, to := names(d.to.Exceptions, func(e *Exception) string { return e.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "EXCEPTION", "", name).stmt(phaseDropOther, "DROP EXCEPTION "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+	for _, name := range toNames {
+		e := to[name]
+		old := from[name]
+		ddl := "CREATE OR ALTER EXCEPTION " + firebirdsql.QuoteIdentifier(name) + " " + firebirdsql.QuoteString(e.Message)
+		if old == nil {
+			d.add(ActionCreate, "EXCEPTION", "", name).stmt(phaseCreateOther, ddl)
+		} else if old.Message != e.Message {
+			c := d.add(ActionAlter, "EXCEPTION", "", name)
+			c.detail("message", old.Message, e.Message)
+			c.stmt(phaseCreateOther, ddl)
+		}
+	}
+}
+
+func (d *differ) domains() {
+	fromNames, from := names(d.from.Domains, func(x *Domain) string { return x.Name })
+	toNames, to := names(d.to.Domains, func(x *Domain) string { return x.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "DOMAIN", "", name).stmt(phaseDropDomain, "DROP DOMAIN "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+	for _, name := range toNames {
+		dom := to[name]
+		old := from[name]
+		if old == nil {
+			d.add(ActionCreate, "DOMAIN", "", name).stmt(phaseDomain, DomainDDL(dom, d.to.DefaultCharset))
+			continue
+		}
+		var c *Change
+		change := func() *Change {
+			if c == nil {
+				c = d.add(ActionAlter, "DOMAIN", "", name)
+			}
+			return c
+		}
+		alter := "ALTER DOMAIN " + firebirdsql.QuoteIdentifier(name)
+		w := &ddlWriter{s: d.to}
+		if old.Type != dom.Type || old.Charset != dom.Charset || old.Collation != dom.Collation {
+			change().detail("type", typeString(old.Type, old.Charset, old.Collation), typeString(dom.Type, dom.Charset, dom.Collation))
+			ddl := alter + " TYPE " + w.typeClause(dom.Type, dom.Charset)
+			if dom.Collation != "" {
+				ddl += " COLLATE " + dom.Collation
+			}
+			c.stmt(phaseDomain, ddl)
+		}
+		if old.Default != dom.Default {
+			change().detail("default", old.Default, dom.Default)
+			if dom.Default == "" {
+				c.stmt(phaseDomain, alter+" DROP DEFAULT")
+			} else {
+				c.stmt(phaseDomain, alter+" SET DEFAULT "+dom.Default)
+			}
+		}
+		if old.Nullable != dom.Nullable {
+			change().detail("nullable", old.Nullable, dom.Nullable)
+			if dom.Nullable {
+				c.stmt(phaseDomain, alter+" NULL")
+			} else {
+				c.stmt(phaseDomain, alter+" NOT NULL")
+			}
+		}
+		if old.Check != dom.Check {
+			change().detail("check", old.Check, dom.Check)
+			if old.Check != "" {
+				c.stmt(phaseDomain, alter+" DROP CONSTRAINT")
+			}
+			if dom.Check != "" {
+				c.stmt(phaseDomain, alter+" ADD "+dom.Check)
+			}
+		}
+	}
+}
+
+func typeString(typ string, charset string, collation string) string {
+	s := typ
+	if charset != "" {
+		s += " CHARACTER SET " + charset
+	}
+	if collation != "" {
+		s += " COLLATE " + collation
+	}
+	return s
+}
+
+// constraintKey identifies a constraint. Names of INTEG_n constraints are
+// generated by the server and differ between databases, so they are
+// identified by their definition.
+func constraintKey(c *Constraint) string {
+	if strings.HasPrefix(c.Name, "INTEG_") {
+		return strings.Join([]string{c.Type, strings.Join(c.Columns, ","), c.ReferencedTable,
+			strings.Join(c.ReferencedColumns, ","), c.Source}, "|")
+	}
+	return c.Name
+}
+
+func sameConstraint(a *Constraint, b *Constraint) bool {
+	return a.Type == b.Type &&
+		reflect.DeepEqual(a.Columns, b.Columns) &&
+		a.ReferencedTable == b.ReferencedTable &&
+		reflect.DeepEqual(a.ReferencedColumns, b.ReferencedColumns) &&
+		a.UpdateRule == b.UpdateRule &&
+		a.DeleteRule == b.DeleteRule &&
+		a.Source == b.Source
+}
+
+func sameColumn(a *Column, b *Column) bool {
+	return a.Domain == b.Domain && a.Type == b.Type && a.Charset == b.Charset &&
+		a.Collation == b.Collation && a.Nullable == b.Nullable && a.Default == b.Default &&
+		a.ComputedSource == b.ComputedSource && a.Identity == b.Identity
+}
+
+func sameIndex(a *Index, b *Index) bool {
+	return a.Table == b.Table && reflect.DeepEqual(a.Columns, b.Columns) &&
+		a.Expression == b.Expression && a.Condition == b.Condition &&
+		a.Unique == b.Unique && a.Descending == b.Descending && a.Active == b.Active
+}
+
+func constraintPhases(c *Constraint) (drop int, create int) {
+	switch c.Type {
+	case ForeignKey:
+		return phaseDropForeignKey, phaseForeignKey
+	case Check:
+		return phaseDropConstraint, phaseCheck
+	}
+	return phaseDropConstraint, phaseConstraint
+}
+
+func (d *differ) tables() {
+	fromNames, from := names(d.from.Tables, func(t *Table) string { return t.Name })
+	toNames, to := names(d.to.Tables, func(t *Table) string { return t.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.alteredTables[name] = true
+			d.add(ActionDrop, "TABLE", "", name).stmt(phaseDropTable, "DROP TABLE "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+
+	// foreign keys that have to be recreated because the key they
+	// reference is dropped or changed
+	recreateFKs := make(map[*Constraint]bool)
+	for _, name := range toNames {
+		t := to[name]
+		old := from[name]
+		if old == nil {
+			d.createTable(t)
+			continue
+		}
+		d.alterColumns(old, t)
+		for _, k := range d.alterConstraints(old, t) {
+			for _, other := range d.from.Tables {
+				for _, fk := range other.Constraints {
+					if fk.Type == ForeignKey && fk.ReferencedTable == old.Name && reflect.DeepEqual(fk.ReferencedColumns, k.Columns) {
+						recreateFKs[fk] = true
+					}
+				}
+			}
+		}
+		d.alterIndices(old, t)
+	}
+
+	for _, old := range d.from.Tables {
+		t := to[old.Name]
+		if t == nil {
+			continue
+		}
+		_, toConstraints := names(t.Constraints, constraintKey)
+		for _, fk := range old.Constraints {
+			n := toConstraints[constraintKey(fk)]
+			if !recreateFKs[fk] || n == nil || !sameConstraint(fk, n) {
+				continue // dropped or changed anyway
+			}
+			c := d.add(ActionRecreate, "CONSTRAINT", t.Name, n.Name)
+			c.Details = append(c.Details, "referenced key changed")
+			c.stmt(phaseDropForeignKey, "ALTER TABLE "+firebirdsql.QuoteIdentifier(t.Name)+" DROP CONSTRAINT "+firebirdsql.QuoteIdentifier(fk.Name))
+			c.stmt(phaseForeignKey, "ALTER TABLE "+firebirdsql.QuoteIdentifier(t.Name)+" ADD "+t.ConstraintDDL(n))
+		}
+	}
+}
+
+func (d *differ) createTable(t *Table) {
+	c := d.add(ActionCreate, "TABLE", "", t.Name)
+	c.stmt(phaseTable, d.to.TableDDL(t))
+	for _, k := range t.Constraints {
+		if k.Type == ForeignKey || k.Type == Check {
+			_, phase := constraintPhases(k)
+			c.stmt(phase, "ALTER TABLE "+firebirdsql.QuoteIdentifier(t.Name)+" ADD "+t.ConstraintDDL(k))
+		}
+	}
+	for _, ix := range t.Indices {
+		if ix.Constraint == "" {
+			c.stmt(phaseIndex, IndexDDL(ix))
+			if !ix.Active {
+				c.stmt(phaseIndex, "ALTER INDEX "+firebirdsql.QuoteIdentifier(ix.Name)+" INACTIVE")
+			}
+		}
+	}
+}
+
+func (d *differ) alterColumns(old *Table, t *Table) {
+	table := firebirdsql.QuoteIdentifier(t.Name)
+	for _, col := range old.Columns {
+		if t.Column(col.Name) == nil {
+			d.alteredTables[t.Name] = true
+			c := d.add(ActionDrop, "COLUMN", t.Name, col.Name)
+			if col.ComputedSource == "" {
+				c.Warning = "the data of the column is lost"
+			}
+			c.stmt(phaseDropColumn, "ALTER TABLE "+table+" DROP "+firebirdsql.QuoteIdentifier(col.Name))
+		}
+	}
+	for _, col := range t.Columns {
+		prev := old.Column(col.Name)
+		if prev == nil {
+			d.add(ActionCreate, "COLUMN", t.Name, col.Name).stmt(phaseColumn, "ALTER TABLE "+table+" ADD "+d.to.ColumnDDL(col))
+			continue
+		}
+		if sameColumn(prev, col) {
+			continue
+		}
+		d.alteredTables[t.Name] = true
+		alter := "ALTER TABLE " + table + " ALTER " + firebirdsql.QuoteIdentifier(col.Name)
+		if (prev.ComputedSource == "") != (col.ComputedSource == "") {
+			c := d.add(ActionRecreate, "COLUMN", t.Name, col.Name)
+			c.detail("computed by", prev.ComputedSource, col.ComputedSource)
+			if prev.ComputedSource == "" {
+				c.Warning = "the data of the column is lost"
+			}
+			c.stmt(phaseDropColumn, "ALTER TABLE "+table+" DROP "+firebirdsql.QuoteIdentifier(col.Name))
+			c.stmt(phaseColumn, "ALTER TABLE "+table+" ADD "+d.to.ColumnDDL(col))
+			continue
+		}
+		c := d.add(ActionAlter, "COLUMN", t.Name, col.Name)
+		if col.ComputedSource != "" {
+			c.detail("computed by", prev.ComputedSource, col.ComputedSource)
+			c.stmt(phaseColumn, alter+" COMPUTED BY "+col.ComputedSource)
+			continue
+		}
+		w := &ddlWriter{s: d.to}
+		switch {
+		case prev.Domain != col.Domain && col.Domain != "":
+			c.detail("domain", prev.Domain, col.Domain)
+			c.stmt(phaseColumn, alter+" TYPE "+firebirdsql.QuoteIdentifier(col.Domain))
+		case prev.Domain != col.Domain || prev.Type != col.Type || prev.Charset != col.Charset || prev.Collation != col.Collation:
+			c.detail("type", typeString(prev.Type, prev.Charset, prev.Collation), typeString(col.Type, col.Charset, col.Collation))
+			ddl := alter + " TYPE " + w.typeClause(col.Type, col.Charset)
+			if col.Collation != "" {
+				ddl += " COLLATE " + col.Collation
+			}
+			c.stmt(phaseColumn, ddl)
+		}
+		if prev.Default != col.Default {
+			c.detail("default", prev.Default, col.Default)
+			if col.Default == "" {
+				c.stmt(phaseColumn, alter+" DROP DEFAULT")
+			} else {
+				c.stmt(phaseColumn, alter+" SET DEFAULT "+col.Default)
+			}
+		}
+		if prev.Nullable != col.Nullable {
+			c.detail("nullable", prev.Nullable, col.Nullable)
+			if col.Nullable {
+				c.stmt(phaseColumn, alter+" DROP NOT NULL")
+			} else {
+				c.stmt(phaseColumn, alter+" SET NOT NULL")
+			}
+		}
+		if prev.Identity != col.Identity {
+			c.detail("identity", prev.Identity, col.Identity)
+			c.Warning = "identity changes are not scripted"
+		}
+	}
+}
+
+// alterConstraints diffs the constraints of a table and returns the
+// primary and unique keys that are dropped or changed.
+func (d *differ) alterConstraints(old *Table, t *Table) []*Constraint {
+	var changedKeys []*Constraint
+	table := firebirdsql.QuoteIdentifier(t.Name)
+	_, toConstraints := names(t.Constraints, constraintKey)
+	_, fromConstraints := names(old.Constraints, constraintKey)
+	for _, k := range old.Constraints {
+		n := toConstraints[constraintKey(k)]
+		if n != nil && sameConstraint(k, n) {
+			continue
+		}
+		if k.Type == PrimaryKey || k.Type == Unique {
+			changedKeys = append(changedKeys, k)
+		}
+		drop, create := constraintPhases(k)
+		if n == nil {
+			d.add(ActionDrop, "CONSTRAINT", t.Name, k.Name).stmt(drop, "ALTER TABLE "+table+" DROP CONSTRAINT "+firebirdsql.QuoteIdentifier(k.Name))
+			continue
+		}
+		c := d.add(ActionRecreate, "CONSTRAINT", t.Name, n.Name)
+		c.detail("definition", old.ConstraintDDL(k), t.ConstraintDDL(n))
+		c.stmt(drop, "ALTER TABLE "+table+" DROP CONSTRAINT "+firebirdsql.QuoteIdentifier(k.Name))
+		c.stmt(create, "ALTER TABLE "+table+" ADD "+t.ConstraintDDL(n))
+	}
+	for _, k := range t.Constraints {
+		if fromConstraints[constraintKey(k)] == nil {
+			_, create := constraintPhases(k)
+			d.add(ActionCreate, "CONSTRAINT", t.Name, k.Name).stmt(create, "ALTER TABLE "+table+" ADD "+t.ConstraintDDL(k))
+		}
+	}
+	return changedKeys
+}
+
+func (d *differ) alterIndices(old *Table, t *Table) {
+	userIndices := func(t *Table) []*Index {
+		var list []*Index
+		for _, ix := range t.Indices {
+			if ix.Constraint == "" {
+				list = append(list, ix)
+			}
+		}
+		return list
+	}
+	_, to := names(userIndices(t), func(ix *Index) string { return ix.Name })
+	_, from := names(userIndices(old), func(ix *Index) string { return ix.Name })
+	for _, ix := range userIndices(old) {
+		if to[ix.Name] == nil {
+			d.add(ActionDrop, "INDEX", t.Name, ix.Name).stmt(phaseDropIndex, "DROP INDEX "+firebirdsql.QuoteIdentifier(ix.Name))
+		}
+	}
+	for _, ix := range userIndices(t) {
+		prev := from[ix.Name]
+		switch {
+		case prev == nil:
+			c := d.add(ActionCreate, "INDEX", t.Name, ix.Name)
+			c.stmt(phaseIndex, IndexDDL(ix))
+			if !ix.Active {
+				c.stmt(phaseIndex, "ALTER INDEX "+firebirdsql.QuoteIdentifier(ix.Name)+" INACTIVE")
+			}
+		case sameIndex(prev, ix):
+		case reflect.DeepEqual(*prev, Index{Name: ix.Name, Table: ix.Table, Columns: ix.Columns, Expression: ix.Expression,
+			Condition: ix.Condition, Unique: ix.Unique, Descending: ix.Descending, Active: prev.Active, Description: prev.Description}):
+			c := d.add(ActionAlter, "INDEX", t.Name, ix.Name)
+			c.detail("active", prev.Active, ix.Active)
+			if ix.Active {
+				c.stmt(phaseIndex, "ALTER INDEX "+firebirdsql.QuoteIdentifier(ix.Name)+" ACTIVE")
+			} else {
+				c.stmt(phaseIndex, "ALTER INDEX "+firebirdsql.QuoteIdentifier(ix.Name)+" INACTIVE")
+			}
+		default:
+			c := d.add(ActionRecreate, "INDEX", t.Name, ix.Name)
+			c.detail("definition", IndexDDL(prev), IndexDDL(ix))
+			c.stmt(phaseDropIndex, "DROP INDEX "+firebirdsql.QuoteIdentifier(ix.Name))
+			c.stmt(phaseIndex, IndexDDL(ix))
+			if !ix.Active {
+				c.stmt(phaseIndex, "ALTER INDEX "+firebirdsql.QuoteIdentifier(ix.Name)+" INACTIVE")
+			}
+		}
+	}
+}
+
+func viewColumns(v *View) []string {
+	list := make([]string, len(v.Columns))
+	for i, c := range v.Columns {
+		list[i] = c.Name
+	}
+	return list
+}
+
+func mentions(source string, name string) bool {
+	return regexp.MustCompile(`(?i)(^|[^A-Za-z0-9_$])` + regexp.QuoteMeta(name) + `($|[^A-Za-z0-9_$])`).MatchString(source)
+}
+
+func (d *differ) views() {
+	_, from := names(d.from.Views, func(v *View) string { return v.Name })
+	_, to := names(d.to.Views, func(v *View) string { return v.Name })
+
+	// views to drop: dropped, changed, or depending on a changed table or
+	// a view that is dropped
+	drop := make(map[string]bool)
+	for _, v := range d.from.Views {
+		n := to[v.Name]
+		if n == nil || n.Source != v.Source || !reflect.DeepEqual(viewColumns(n), viewColumns(v)) {
+			drop[v.Name] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, v := range d.from.Views {
+			if drop[v.Name] {
+				continue
+			}
+			for name := range d.alteredTables {
+				if mentions(v.Source, name) {
+					drop[v.Name] = true
+				}
+			}
+			if d.usesAlteredProcedure(v.Source, "") {
+				drop[v.Name] = true
+			}
+			for name := range drop {
+				if mentions(v.Source, name) {
+					drop[v.Name] = true
+				}
+			}
+			changed = changed || drop[v.Name]
+		}
+	}
+
+	changes := make(map[string]*Change)
+	ordered := orderViews(d.from.Views)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		v := ordered[i]
+		if !drop[v.Name] {
+			continue
+		}
+		n := to[v.Name]
+		var c *Change
+		switch {
+		case n == nil:
+			c = d.add(ActionDrop, "VIEW", "", v.Name)
+		case n.Source != v.Source || !reflect.DeepEqual(viewColumns(n), viewColumns(v)):
+			c = d.add(ActionAlter, "VIEW", "", v.Name)
+			if n.Source != v.Source {
+				c.detail("source", v.Source, n.Source)
+			}
+		default:
+			c = d.add(ActionRecreate, "VIEW", "", v.Name)
+			c.Details = append(c.Details, "depends on a changed object")
+		}
+		c.stmt(phaseDropView, "DROP VIEW "+firebirdsql.QuoteIdentifier(v.Name))
+		changes[v.Name] = c
+	}
+	for _, v := range orderViews(d.to.Views) {
+		if from[v.Name] != nil && !drop[v.Name] {
+			continue
+		}
+		c := changes[v.Name]
+		if c == nil {
+			c = d.add(ActionCreate, "VIEW", "", v.Name)
+		}
+		c.stmt(phaseView, ViewDDL(v))
+	}
+}
+
+func sameParameters(a []*Parameter, b []*Parameter) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := *a[i], *b[i]
+		x.Position, y.Position = 0, 0
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *differ) functions() {
+	fromNames, from := names(d.from.Functions, func(f *Function) string { return f.Name })
+	toNames, to := names(d.to.Functions, func(f *Function) string { return f.Name })
+	dropFunction := func(f *Function) string {
+		if f.Legacy {
+			return "DROP EXTERNAL FUNCTION " + firebirdsql.QuoteIdentifier(f.Name)
+		}
+		return "DROP FUNCTION " + firebirdsql.QuoteIdentifier(f.Name)
+	}
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "FUNCTION", "", name).stmt(phaseDropRoutine, dropFunction(from[name]))
+		}
+	}
+	for _, name := range toNames {
+		f := to[name]
+		old := from[name]
+		// the body is emptied while a procedure it calls changes
+		dependent := old != nil && !old.Legacy && old.Engine == "" && !f.Legacy && f.Engine == "" &&
+			d.usesAlteredProcedure(old.Source, "")
+		unchanged := old != nil && old.Source == f.Source && old.ReturnType == f.ReturnType && old.ReturnCharset == f.ReturnCharset &&
+			old.Deterministic == f.Deterministic && old.Legacy == f.Legacy && old.EntryPoint == f.EntryPoint &&
+			old.ModuleName == f.ModuleName && old.Engine == f.Engine && sameParameters(old.Arguments, f.Arguments)
+		if unchanged && !dependent {
+			continue
+		}
+		action := ActionCreate
+		switch {
+		case unchanged:
+			action = ActionRecreate
+		case old != nil:
+			action = ActionAlter
+		}
+		c := d.add(action, "FUNCTION", "", name)
+		if dependent {
+			c.Details = append(c.Details, "depends on a changed procedure")
+			c.psqlStmt(phaseStubRoutine, d.from.functionStub(old))
+		}
+		switch {
+		case f.Legacy:
+			if old != nil {
+				c.stmt(phaseDropRoutine, dropFunction(old))
+			}
+			c.stmt(phaseRoutineHeader, d.to.udfDDL(f))
+		case f.Engine != "":
+			c.psqlStmt(phaseRoutineHeader, d.to.functionStub(f))
+		default:
+			if old == nil {
+				c.psqlStmt(phaseRoutineHeader, d.to.functionStub(f))
+			}
+			c.psqlStmt(phaseRoutineBody, d.to.functionBody(f))
+		}
+	}
+}
+
+// alteredProcedures returns the procedures of from that are dropped or
+// whose parameters change, which the server refuses while other objects
+// depend on them.
+func alteredProcedures(from *Schema, to *Schema) map[string]bool {
+	_, toByName := names(to.Procedures, func(p *Procedure) string { return p.Name })
+	altered := make(map[string]bool)
+	for _, old := range from.Procedures {
+		p := toByName[old.Name]
+		if p == nil || old.Selectable != p.Selectable ||
+			!sameParameters(old.Inputs, p.Inputs) || !sameParameters(old.Outputs, p.Outputs) {
+			altered[old.Name] = true
+		}
+	}
+	return altered
+}
+
+// usesAlteredProcedure reports whether source mentions a procedure other
+// than self that is dropped or whose parameters change.
+func (d *differ) usesAlteredProcedure(source string, self string) bool {
+	for name := range d.alteredProcedures {
+		if name != self && mentions(source, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) procedures() {
+	fromNames, from := names(d.from.Procedures, func(p *Procedure) string { return p.Name })
+	toNames, to := names(d.to.Procedures, func(p *Procedure) string { return p.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "PROCEDURE", "", name).stmt(phaseDropRoutine, "DROP PROCEDURE "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+	for _, name := range toNames {
+		p := to[name]
+		old := from[name]
+		if old == nil {
+			c := d.add(ActionCreate, "PROCEDURE", "", name)
+			c.psqlStmt(phaseRoutineHeader, d.to.procedureStub(p))
+			if p.Engine == "" {
+				c.psqlStmt(phaseRoutineBody, d.to.procedureBody(p))
+			}
+			continue
+		}
+		// the body is emptied while a procedure it calls changes
+		dependent := old.Engine == "" && p.Engine == "" && d.usesAlteredProcedure(old.Source, name)
+		unchanged := old.Source == p.Source && old.EntryPoint == p.EntryPoint && old.Engine == p.Engine &&
+			!d.alteredProcedures[name]
+		if unchanged && !dependent {
+			continue
+		}
+		action := ActionAlter
+		if unchanged {
+			action = ActionRecreate
+		}
+		c := d.add(action, "PROCEDURE", "", name)
+		if dependent {
+			c.Details = append(c.Details, "depends on a changed procedure")
+			c.psqlStmt(phaseStubRoutine, d.from.procedureStub(old))
+		}
+		if d.alteredProcedures[name] {
+			// recreated with the new parameters once its dependents are
+			// emptied or dropped
+			c.Details = append(c.Details, "parameters changed")
+			c.psqlStmt(phaseRoutineHeader, d.to.procedureStub(p))
+		}
+		if old.Source != p.Source {
+			c.Details = append(c.Details, "source changed")
+		}
+		if old.EntryPoint != p.EntryPoint || old.Engine != p.Engine {
+			c.detail("external name", old.EntryPoint, p.EntryPoint)
+		}
+		switch {
+		case p.Engine == "":
+			c.psqlStmt(phaseRoutineBody, d.to.procedureBody(p))
+		case !d.alteredProcedures[name]:
+			c.psqlStmt(phaseRoutineHeader, d.to.procedureStub(p))
+		}
+	}
+}
+
+func (d *differ) packages() {
+	fromNames, from := names(d.from.Packages, func(p *Package) string { return p.Name })
+	toNames, to := names(d.to.Packages, func(p *Package) string { return p.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "PACKAGE", "", name).stmt(phaseDropRoutine, "DROP PACKAGE "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+	for _, name := range toNames {
+		p := to[name]
+		old := from[name]
+		// the body is dropped while a procedure it calls changes
+		dependent := old != nil && old.Body != "" && d.usesAlteredProcedure(old.Body, "")
+		unchanged := old != nil && old.Header == p.Header && old.Body == p.Body
+		if unchanged && !dependent {
+			continue
+		}
+		action := ActionCreate
+		switch {
+		case unchanged:
+			action = ActionRecreate
+		case old != nil:
+			action = ActionAlter
+		}
+		c := d.add(action, "PACKAGE", "", name)
+		if dependent {
+			c.Details = append(c.Details, "depends on a changed procedure")
+			c.stmt(phaseStubRoutine, "DROP PACKAGE BODY "+firebirdsql.QuoteIdentifier(name))
+		}
+		// changing the header drops the body
+		if old == nil || old.Header != p.Header {
+			c.psqlStmt(phaseRoutineHeader, packageHeaderDDL(p))
+		}
+		if p.Body != "" {
+			c.psqlStmt(phaseRoutineBody, packageBodyDDL(p))
+		}
+	}
+}
+
+func (d *differ) triggers() {
+	fromNames, from := names(d.from.Triggers, func(t *Trigger) string { return t.Name })
+	toNames, to := names(d.to.Triggers, func(t *Trigger) string { return t.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "TRIGGER", from[name].Table, name).stmt(phaseDropTrigger, "DROP TRIGGER "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+	for _, name := range toNames {
+		t := to[name]
+		old := from[name]
+		changed := old != nil &&
+			(old.Event != t.Event || old.Position != t.Position || old.Active != t.Active || old.Source != t.Source)
+		switch {
+		case old == nil:
+			d.add(ActionCreate, "TRIGGER", t.Table, name).psqlStmt(phaseTrigger, TriggerDDL(t))
+		case old.Table != t.Table || d.usesAlteredProcedure(old.Source, ""):
+			action := ActionRecreate
+			if changed {
+				action = ActionAlter
+			}
+			c := d.add(action, "TRIGGER", t.Table, name)
+			if old.Table != t.Table {
+				c.detail("table", old.Table, t.Table)
+			} else {
+				c.Details = append(c.Details, "depends on a changed procedure")
+			}
+			if old.Source != t.Source {
+				c.Details = append(c.Details, "source changed")
+			}
+			c.stmt(phaseDropTrigger, "DROP TRIGGER "+firebirdsql.QuoteIdentifier(name))
+			c.psqlStmt(phaseTrigger, TriggerDDL(t))
+		case changed:
+			c := d.add(ActionAlter, "TRIGGER", t.Table, name)
+			if old.Event != t.Event {
+				c.detail("event", old.Event, t.Event)
+			}
+			if old.Active != t.Active {
+				c.detail("active", old.Active, t.Active)
+			}
+			if old.Source != t.Source {
+				c.Details = append(c.Details, "source changed")
+			}
+			c.psqlStmt(phaseTrigger, "CREATE OR ALTER"+strings.TrimPrefix(TriggerDDL(t), "CREATE"))
+		}
+	}
+}
+
+func (d *differ) roles() {
+	fromNames, from := names(d.from.Roles, func(r *Role) string { return r.Name })
+	toNames, to := names(d.to.Roles, func(r *Role) string { return r.Name })
+	for _, name := range fromNames {
+		if to[name] == nil {
+			d.add(ActionDrop, "ROLE", "", name).stmt(phaseDropOther, "DROP ROLE "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+	for _, name := range toNames {
+		if from[name] == nil {
+			d.add(ActionCreate, "ROLE", "", name).stmt(phaseCreateOther, "CREATE ROLE "+firebirdsql.QuoteIdentifier(name))
+		}
+	}
+}
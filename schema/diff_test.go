@@ -0,0 +1,206 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSame(t *testing.T) {
+	d := Compare(testSchema(), testSchema())
+	assert.True(t, d.Empty())
+	assert.Equal(t, "", d.Script())
+}
+
+func TestDiffSnapshot(t *testing.T) {
+	b, err := json.Marshal(testSchema())
+	require.NoError(t, err)
+	var snapshot Schema
+	require.NoError(t, json.Unmarshal(b, &snapshot))
+	assert.True(t, Compare(&snapshot, testSchema()).Empty())
+}
+
+func TestDiffCreate(t *testing.T) {
+	script := Compare(&Schema{}, testSchema()).Script()
+	// tables before the foreign keys and views, stubs before views before bodies
+	order := []string{
+		"CREATE DOMAIN D_AMOUNT",
+		"CREATE TABLE CUSTOMER",
+		"CREATE TABLE ORDERS",
+		"ADD CONSTRAINT FK_ORDERS_CUSTOMER",
+		"CREATE OR ALTER PROCEDURE ADD_ORDER",
+		"CREATE VIEW BIG_ORDERS",
+		"CREATE VIEW TOP_ORDERS",
+		"\nALTER PROCEDURE ADD_ORDER",
+		"CREATE TRIGGER ORDERS_BI",
+	}
+	pos := -1
+	for _, s := range order {
+		i := strings.Index(script, s)
+		require.Greater(t, i, pos, s)
+		pos = i
+	}
+}
+
+func TestDiffAlter(t *testing.T) {
+	from := testSchema()
+	to := testSchema()
+	customer := to.Table("CUSTOMER")
+	customer.Column("NOTE").Type = "VARCHAR(200)"
+	customer.Constraints[0].Columns = []string{"ID", "NAME"}
+	orders := to.Table("ORDERS")
+	orders.Columns = orders.Columns[:4]
+	to.Generators = nil
+	to.Exceptions = append(to.Exceptions, &Exception{Name: "E_WORSE", Message: "worse"})
+	to.Domains[0].Default = ""
+	to.Triggers[0].Active = false
+
+	d := Compare(from, to)
+	assert.Equal(t, `DROP VIEW TOP_ORDERS;
+DROP VIEW BIG_ORDERS;
+ALTER TABLE ORDERS DROP CONSTRAINT FK_ORDERS_CUSTOMER;
+ALTER TABLE CUSTOMER DROP CONSTRAINT PK_CUSTOMER;
+ALTER TABLE ORDERS DROP "Order";
+DROP GENERATOR GEN_ORDERS;
+CREATE OR ALTER EXCEPTION E_WORSE 'worse';
+ALTER DOMAIN D_AMOUNT DROP DEFAULT;
+ALTER TABLE CUSTOMER ALTER NOTE TYPE VARCHAR(200) COLLATE UNICODE_CI;
+ALTER TABLE CUSTOMER ADD CONSTRAINT PK_CUSTOMER PRIMARY KEY (ID, NAME);
+ALTER TABLE ORDERS ADD CONSTRAINT FK_ORDERS_CUSTOMER FOREIGN KEY (CUSTOMER_ID) REFERENCES CUSTOMER (ID) ON DELETE CASCADE;
+CREATE VIEW BIG_ORDERS (ID, AMOUNT) AS
+SELECT id, amount FROM orders WHERE amount > 100;
+CREATE VIEW TOP_ORDERS (ID) AS
+SELECT id FROM big_orders WHERE amount > 1000;
+SET TERM ^ ;
+CREATE OR ALTER TRIGGER ORDERS_BI FOR ORDERS INACTIVE BEFORE INSERT OR UPDATE POSITION 5
+AS
+BEGIN
+END^
+
+SET TERM ; ^
+`, d.Script())
+
+	b, err := json.Marshal(d)
+	require.NoError(t, err)
+	var decoded Diff
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, d.Script(), decoded.Script())
+
+	byName := make(map[string]*Change)
+	for _, c := range d.Changes {
+		byName[c.ObjectType+" "+c.Name] = c
+	}
+	c := byName["COLUMN Order"]
+	require.NotNil(t, c)
+	assert.Equal(t, ActionDrop, c.Action)
+	assert.Equal(t, "ORDERS", c.Table)
+	assert.NotEmpty(t, c.Warning)
+
+	c = byName["COLUMN NOTE"]
+	require.NotNil(t, c)
+	assert.Equal(t, ActionAlter, c.Action)
+	assert.Equal(t, []string{"type: VARCHAR(100) CHARACTER SET UTF8 COLLATE UNICODE_CI -> VARCHAR(200) CHARACTER SET UTF8 COLLATE UNICODE_CI"}, c.Details)
+
+	assert.Equal(t, ActionRecreate, byName["CONSTRAINT FK_ORDERS_CUSTOMER"].Action)
+	assert.Equal(t, ActionRecreate, byName["VIEW TOP_ORDERS"].Action)
+}
+
+func TestDiffIntegConstraint(t *testing.T) {
+	// INTEG_n names differ between databases
+	from := testSchema()
+	to := testSchema()
+	to.Table("CUSTOMER").Constraints[1].Name = "INTEG_42"
+	assert.True(t, Compare(from, to).Empty())
+}
+
+func TestDiffProcedureDependents(t *testing.T) {
+	schema := func(inputs ...*Parameter) *Schema {
+		return &Schema{
+			Procedures: []*Procedure{
+				{Name: "P", Selectable: true, Inputs: inputs,
+					Outputs: []*Parameter{{Name: "R", Output: true, Type: "INTEGER", Nullable: true}},
+					Source:  "AS\nBEGIN\n  R = 1;\n  SUSPEND;\nEND"},
+				{Name: "Q", Source: "AS\nDECLARE R INTEGER;\nBEGIN\n  SELECT R FROM P INTO R;\nEND"},
+			},
+			Functions: []*Function{
+				{Name: "F", ReturnType: "INTEGER", Source: "AS\nBEGIN\n  RETURN (SELECT R FROM P);\nEND"},
+			},
+			Views: []*View{
+				{Name: "V", Columns: []*Column{{Name: "R", Type: "INTEGER", Nullable: true}}, Source: "SELECT R FROM P"},
+			},
+			Triggers: []*Trigger{
+				{Name: "T", Event: "ON CONNECT", Active: true, Source: "AS\nDECLARE R INTEGER;\nBEGIN\n  SELECT R FROM P INTO R;\nEND"},
+			},
+		}
+	}
+	from := schema()
+	to := schema(&Parameter{Name: "A", Type: "INTEGER", Nullable: true})
+
+	d := Compare(from, to)
+	script := d.Script()
+	order := []string{
+		"DROP TRIGGER T",
+		"DROP VIEW V",
+		"CREATE OR ALTER FUNCTION F ()\nRETURNS INTEGER\nAS\nBEGIN\n  RETURN NULL;\nEND",
+		"CREATE OR ALTER PROCEDURE Q\nAS\nBEGIN\n  EXIT;\nEND",
+		"CREATE OR ALTER PROCEDURE P (\n  A INTEGER)",
+		"CREATE VIEW V",
+		"\nALTER FUNCTION F",
+		"\nALTER PROCEDURE P",
+		"\nALTER PROCEDURE Q",
+		"CREATE TRIGGER T",
+	}
+	pos := -1
+	for _, s := range order {
+		i := strings.Index(script, s)
+		require.Greater(t, i, pos, s)
+		pos = i
+	}
+
+	byName := make(map[string]*Change)
+	for _, c := range d.Changes {
+		byName[c.ObjectType+" "+c.Name] = c
+	}
+	assert.Equal(t, ActionAlter, byName["PROCEDURE P"].Action)
+	assert.Equal(t, []string{"parameters changed"}, byName["PROCEDURE P"].Details)
+	assert.Equal(t, ActionRecreate, byName["PROCEDURE Q"].Action)
+	assert.Equal(t, ActionRecreate, byName["FUNCTION F"].Action)
+	assert.Equal(t, ActionRecreate, byName["TRIGGER T"].Action)
+
+	// dropping P empties its dependents first
+	to = schema()
+	to.Procedures = to.Procedures[:0]
+	to.Views, to.Triggers, to.Functions = nil, nil, nil
+	to.Procedures = append(to.Procedures, &Procedure{Name: "Q", Source: "AS\nBEGIN\nEND"})
+	script = Compare(from, to).Script()
+	stub := strings.Index(script, "CREATE OR ALTER PROCEDURE Q\nAS\nBEGIN\n  EXIT;\nEND")
+	drop := strings.Index(script, "DROP PROCEDURE P")
+	require.GreaterOrEqual(t, stub, 0)
+	assert.Greater(t, drop, stub)
+}
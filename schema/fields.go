@@ -0,0 +1,206 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fieldColumns selects the type of the RDB$FIELDS row aliased f, the
+// character set and the collation; scan them with fieldType.dest.
+// collationID is the expression of the collation id.
+func fieldColumns(collationID string) string {
+	return typeColumns(func(column string) string { return "f." + column }, collationID)
+}
+
+// typeColumns is fieldColumns with the expression of each RDB$FIELDS
+// column given by col.
+func typeColumns(col func(column string) string, collationID string) string {
+	return col("RDB$FIELD_TYPE") + ", " + col("RDB$FIELD_SUB_TYPE") + ", " + col("RDB$FIELD_LENGTH") + `,
+		` + col("RDB$FIELD_PRECISION") + ", " + col("RDB$FIELD_SCALE") + ", " + col("RDB$CHARACTER_LENGTH") + `,
+		` + col("RDB$SEGMENT_LENGTH") + ", " + col("RDB$DIMENSIONS") + `,
+		(SELECT LIST(d.RDB$DIMENSION || ':' || d.RDB$LOWER_BOUND || ':' || d.RDB$UPPER_BOUND)
+			FROM RDB$FIELD_DIMENSIONS d WHERE d.RDB$FIELD_NAME = ` + col("RDB$FIELD_NAME") + `),
+		(SELECT cs.RDB$CHARACTER_SET_NAME FROM RDB$CHARACTER_SETS cs
+			WHERE cs.RDB$CHARACTER_SET_ID = ` + col("RDB$CHARACTER_SET_ID") + `),
+		(SELECT co.RDB$COLLATION_NAME FROM RDB$COLLATIONS co
+			WHERE co.RDB$CHARACTER_SET_ID = ` + col("RDB$CHARACTER_SET_ID") + `
+			AND co.RDB$COLLATION_ID = ` + collationID + `)`
+}
+
+type fieldType struct {
+	typ        sql.NullInt64
+	subType    sql.NullInt64
+	length     sql.NullInt64
+	precision  sql.NullInt64
+	scale      sql.NullInt64
+	charLength sql.NullInt64
+	segment    sql.NullInt64
+	dimensions sql.NullInt64
+	bounds     sql.NullString // "dimension:lower:upper" of each dimension, comma separated
+	charset    sql.NullString
+	collation  sql.NullString
+}
+
+func (f *fieldType) dest() []any {
+	return []any{&f.typ, &f.subType, &f.length, &f.precision, &f.scale,
+		&f.charLength, &f.segment, &f.dimensions, &f.bounds, &f.charset, &f.collation}
+}
+
+// isText reports whether the character set of the field matters.
+func (f *fieldType) isText() bool {
+	switch f.typ.Int64 {
+	case 14, 37, 40:
+		return true
+	case 261:
+		return f.subType.Int64 == 1
+	}
+	return false
+}
+
+// charsetName returns the character set of text fields.
+func (f *fieldType) charsetName() string {
+	if !f.isText() {
+		return ""
+	}
+	return trim(f.charset)
+}
+
+// collationName returns the collation of text fields unless it is the
+// default collation of the character set.
+func (f *fieldType) collationName() string {
+	if !f.isText() || trim(f.collation) == trim(f.charset) {
+		return ""
+	}
+	return trim(f.collation)
+}
+
+// sqlType renders the type as it is written in DDL.
+func (f *fieldType) sqlType() string {
+	s := f.baseType()
+	if f.dimensions.Int64 > 0 {
+		s += "[" + f.arrayBounds() + "]"
+	}
+	return s
+}
+
+// arrayBounds renders the bounds of an array field, e.g. "1:10,0:3".
+func (f *fieldType) arrayBounds() string {
+	type dimension struct {
+		n            int
+		lower, upper string
+	}
+	var dims []dimension
+	for _, item := range strings.Split(trim(f.bounds), ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 3 {
+			continue
+		}
+		n, _ := strconv.Atoi(parts[0])
+		dims = append(dims, dimension{n, parts[1], parts[2]})
+	}
+	sort.Slice(dims, func(i, j int) bool { return dims[i].n < dims[j].n })
+	bounds := make([]string, len(dims))
+	for i, d := range dims {
+		bounds[i] = d.lower + ":" + d.upper
+	}
+	return strings.Join(bounds, ",")
+}
+
+func (f *fieldType) baseType() string {
+	scale := -f.scale.Int64
+	switch f.typ.Int64 {
+	case 7, 8, 16, 26:
+		if f.subType.Int64 > 0 || scale > 0 {
+			precision := f.precision.Int64
+			if precision == 0 {
+				precision = map[int64]int64{7: 4, 8: 9, 16: 18, 26: 38}[f.typ.Int64]
+			}
+			name := "NUMERIC"
+			if f.subType.Int64 == 2 {
+				name = "DECIMAL"
+			}
+			return fmt.Sprintf("%s(%d,%d)", name, precision, scale)
+		}
+		return map[int64]string{7: "SMALLINT", 8: "INTEGER", 16: "BIGINT", 26: "INT128"}[f.typ.Int64]
+	case 10:
+		return "FLOAT"
+	case 11, 27:
+		if scale > 0 {
+			// dialect 1 NUMERIC stored as DOUBLE PRECISION
+			return fmt.Sprintf("NUMERIC(%d,%d)", f.precision.Int64, scale)
+		}
+		return "DOUBLE PRECISION"
+	case 12:
+		return "DATE"
+	case 13:
+		return "TIME"
+	case 28:
+		return "TIME WITH TIME ZONE"
+	case 35:
+		return "TIMESTAMP"
+	case 29:
+		return "TIMESTAMP WITH TIME ZONE"
+	case 23:
+		return "BOOLEAN"
+	case 24:
+		return "DECFLOAT(16)"
+	case 25:
+		return "DECFLOAT(34)"
+	case 14:
+		return fmt.Sprintf("CHAR(%d)", f.characters())
+	case 37:
+		return fmt.Sprintf("VARCHAR(%d)", f.characters())
+	case 40:
+		return fmt.Sprintf("CSTRING(%d)", f.characters())
+	case 45:
+		return "BLOB_ID"
+	case 261:
+		var s string
+		switch f.subType.Int64 {
+		case 0:
+			s = "BLOB SUB_TYPE BINARY"
+		case 1:
+			s = "BLOB SUB_TYPE TEXT"
+		default:
+			s = fmt.Sprintf("BLOB SUB_TYPE %d", f.subType.Int64)
+		}
+		if f.segment.Int64 > 0 && f.segment.Int64 != 80 {
+			s += fmt.Sprintf(" SEGMENT SIZE %d", f.segment.Int64)
+		}
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", f.typ.Int64)
+}
+
+func (f *fieldType) characters() int64 {
+	if f.charLength.Valid {
+		return f.charLength.Int64
+	}
+	return f.length.Int64
+}
@@ -0,0 +1,270 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/nakagami/firebirdsql"
+)
+
+func (l *loader) loadGenerators(s *Schema) error {
+	query := `
+		SELECT RDB$GENERATOR_NAME, ` + l.since(3, 0, "RDB$INITIAL_VALUE", "BIGINT") + `,
+			` + l.since(3, 0, "RDB$GENERATOR_INCREMENT", "INTEGER") + `, RDB$DESCRIPTION
+		FROM RDB$GENERATORS
+		WHERE COALESCE(RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY RDB$GENERATOR_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, description    sql.NullString
+			initial, incrementBy sql.NullInt64
+		)
+		if err := rows.Scan(&name, &initial, &incrementBy, &description); err != nil {
+			return err
+		}
+		g := &Generator{
+			Name:         trim(name),
+			InitialValue: initial.Int64,
+			Increment:    incrementBy.Int64,
+			Description:  trim(description),
+		}
+		if !incrementBy.Valid {
+			g.Increment = 1
+		}
+		s.Generators = append(s.Generators, g)
+		return nil
+	})
+}
+
+func (l *loader) loadExceptions(s *Schema) error {
+	query := `
+		SELECT RDB$EXCEPTION_NAME, RDB$EXCEPTION_NUMBER, RDB$MESSAGE, RDB$DESCRIPTION
+		FROM RDB$EXCEPTIONS
+		WHERE COALESCE(RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY RDB$EXCEPTION_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, message, description sql.NullString
+			number                     sql.NullInt64
+		)
+		if err := rows.Scan(&name, &number, &message, &description); err != nil {
+			return err
+		}
+		s.Exceptions = append(s.Exceptions, &Exception{
+			Name:        trim(name),
+			Number:      int(number.Int64),
+			Message:     message.String,
+			Description: trim(description),
+		})
+		return nil
+	})
+}
+
+func (l *loader) loadTriggers(s *Schema) error {
+	// triggers of CHECK constraints are system generated, but not flagged so
+	query := `
+		SELECT t.RDB$TRIGGER_NAME, t.RDB$RELATION_NAME, t.RDB$TRIGGER_SEQUENCE, t.RDB$TRIGGER_TYPE,
+			t.RDB$TRIGGER_INACTIVE, t.RDB$TRIGGER_SOURCE, t.RDB$DESCRIPTION
+		FROM RDB$TRIGGERS t
+		WHERE COALESCE(t.RDB$SYSTEM_FLAG, 0) = 0
+			AND NOT EXISTS (SELECT * FROM RDB$CHECK_CONSTRAINTS cc
+				WHERE cc.RDB$TRIGGER_NAME = t.RDB$TRIGGER_NAME)
+		ORDER BY t.RDB$RELATION_NAME, t.RDB$TRIGGER_TYPE, t.RDB$TRIGGER_SEQUENCE, t.RDB$TRIGGER_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, relation, source, description sql.NullString
+			sequence, typ, inactive             sql.NullInt64
+		)
+		if err := rows.Scan(&name, &relation, &sequence, &typ, &inactive, &source, &description); err != nil {
+			return err
+		}
+		s.Triggers = append(s.Triggers, &Trigger{
+			Name:        trim(name),
+			Table:       trim(relation),
+			Event:       triggerEvent(typ.Int64),
+			Type:        typ.Int64,
+			Position:    int(sequence.Int64),
+			Active:      inactive.Int64 == 0,
+			Source:      trim(source),
+			Description: trim(description),
+		})
+		return nil
+	})
+}
+
+func (l *loader) loadRoles(s *Schema) error {
+	query := `
+		SELECT RDB$ROLE_NAME, RDB$OWNER_NAME, RDB$DESCRIPTION
+		FROM RDB$ROLES
+		WHERE COALESCE(RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY RDB$ROLE_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var name, owner, description sql.NullString
+		if err := rows.Scan(&name, &owner, &description); err != nil {
+			return err
+		}
+		s.Roles = append(s.Roles, &Role{Name: trim(name), Owner: trim(owner), Description: trim(description)})
+		return nil
+	})
+}
+
+const (
+	triggerTypeDB  = 0x2000
+	triggerTypeDDL = 0x4000
+)
+
+var dbTriggerEvents = []string{"CONNECT", "DISCONNECT", "TRANSACTION START", "TRANSACTION COMMIT", "TRANSACTION ROLLBACK"}
+
+// ddlTriggerEvents is indexed by the bit of the event in RDB$TRIGGER_TYPE.
+var ddlTriggerEvents = []string{
+	1: "CREATE TABLE", "ALTER TABLE", "DROP TABLE",
+	"CREATE PROCEDURE", "ALTER PROCEDURE", "DROP PROCEDURE",
+	"CREATE FUNCTION", "ALTER FUNCTION", "DROP FUNCTION",
+	"CREATE TRIGGER", "ALTER TRIGGER", "DROP TRIGGER",
+	// bits 13 to 15 are the trigger type
+	16: "CREATE EXCEPTION", "ALTER EXCEPTION", "DROP EXCEPTION",
+	"CREATE VIEW", "ALTER VIEW", "DROP VIEW",
+	"CREATE DOMAIN", "ALTER DOMAIN", "DROP DOMAIN",
+	"CREATE ROLE", "ALTER ROLE", "DROP ROLE",
+	"CREATE INDEX", "ALTER INDEX", "DROP INDEX",
+	"CREATE SEQUENCE", "ALTER SEQUENCE", "DROP SEQUENCE",
+	"CREATE USER", "ALTER USER", "DROP USER",
+	"CREATE COLLATION", "DROP COLLATION", "ALTER CHARACTER SET",
+	"CREATE PACKAGE", "ALTER PACKAGE", "DROP PACKAGE",
+	"CREATE PACKAGE BODY", "DROP PACKAGE BODY",
+	"CREATE MAPPING", "ALTER MAPPING", "DROP MAPPING",
+}
+
+// triggerEvent decodes RDB$TRIGGER_TYPE into the clause of CREATE TRIGGER,
+// e.g. "BEFORE INSERT OR UPDATE".
+func triggerEvent(typ int64) string {
+	switch {
+	case typ&triggerTypeDDL != 0:
+		prefix := "BEFORE "
+		if typ&1 != 0 {
+			prefix = "AFTER "
+		}
+		var events []string
+		all := true
+		for bit, event := range ddlTriggerEvents {
+			if event == "" {
+				continue
+			}
+			if typ&(1<<bit) != 0 {
+				events = append(events, event)
+			} else {
+				all = false
+			}
+		}
+		if all {
+			return prefix + "ANY DDL STATEMENT"
+		}
+		return prefix + strings.Join(events, " OR ")
+	case typ&triggerTypeDB != 0:
+		if n := typ &^ triggerTypeDB; n < int64(len(dbTriggerEvents)) {
+			return "ON " + dbTriggerEvents[n]
+		}
+		return ""
+	}
+	// DML triggers: bit 0 of type+1 is the phase, then two bits per action
+	prefix := "BEFORE "
+	if (typ+1)&1 != 0 {
+		prefix = "AFTER "
+	}
+	var events []string
+	for slot := 1; slot <= 3; slot++ {
+		switch ((typ + 1) >> (slot*2 - 1)) & 3 {
+		case 1:
+			events = append(events, "INSERT")
+		case 2:
+			events = append(events, "UPDATE")
+		case 3:
+			events = append(events, "DELETE")
+		}
+	}
+	return prefix + strings.Join(events, " OR ")
+}
+
+func (l *loader) loadDatabase(s *Schema) error {
+	query := `
+		SELECT RDB$CHARACTER_SET_NAME,
+			(SELECT MON$SQL_DIALECT FROM MON$DATABASE)
+		FROM RDB$DATABASE`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			charset sql.NullString
+			dialect sql.NullInt64
+		)
+		if err := rows.Scan(&charset, &dialect); err != nil {
+			return err
+		}
+		s.DefaultCharset = trim(charset)
+		if s.DefaultCharset == "" {
+			s.DefaultCharset = "NONE"
+		}
+		s.Dialect = int(dialect.Int64)
+		return nil
+	})
+}
+
+func (l *loader) loadGrants(s *Schema) error {
+	// privileges on system objects and those an owner has on its own
+	// objects are created by the server
+	query := `
+		SELECT p.RDB$USER, p.RDB$GRANTOR, p.RDB$PRIVILEGE, p.RDB$GRANT_OPTION,
+			p.RDB$RELATION_NAME, p.RDB$FIELD_NAME, p.RDB$USER_TYPE, p.RDB$OBJECT_TYPE
+		FROM RDB$USER_PRIVILEGES p
+		WHERE p.RDB$RELATION_NAME NOT STARTING WITH 'RDB$'
+			AND p.RDB$RELATION_NAME NOT STARTING WITH 'MON$'
+			AND p.RDB$RELATION_NAME NOT STARTING WITH 'SEC$'
+			AND p.RDB$RELATION_NAME NOT STARTING WITH 'SQL$'
+			AND (p.RDB$USER <> p.RDB$GRANTOR OR p.RDB$GRANTOR IS NULL)
+		ORDER BY p.RDB$RELATION_NAME, p.RDB$USER, p.RDB$PRIVILEGE, p.RDB$FIELD_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			user, grantor, privilege, relation, field sql.NullString
+			grantOption, userType, objectType         sql.NullInt64
+		)
+		if err := rows.Scan(&user, &grantor, &privilege, &grantOption, &relation, &field, &userType, &objectType); err != nil {
+			return err
+		}
+		g := &Grant{
+			Privilege:   firebirdsql.PrivilegeName(privilege.String),
+			ObjectType:  firebirdsql.ObjectTypeName(int(objectType.Int64)),
+			ObjectName:  trim(relation),
+			Column:      trim(field),
+			Grantee:     trim(user),
+			GranteeType: firebirdsql.ObjectTypeName(int(userType.Int64)),
+			GrantOption: grantOption.Int64 != 0,
+			Grantor:     trim(grantor),
+		}
+		if g.Grantee == "PUBLIC" {
+			g.GranteeType = ""
+		}
+		s.Grants = append(s.Grants, g)
+		return nil
+	})
+}
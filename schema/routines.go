@@ -0,0 +1,277 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// qualifiedName returns the key of a routine in a package.
+func qualifiedName(pkg string, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+func (l *loader) loadProcedures(s *Schema) error {
+	procedures := make(map[string]*Procedure)
+	query := `
+		SELECT p.RDB$PROCEDURE_NAME, ` + l.since(3, 0, "p.RDB$PACKAGE_NAME", "VARCHAR(63)") + `,
+			p.RDB$PROCEDURE_TYPE, p.RDB$PROCEDURE_SOURCE,
+			` + l.since(3, 0, "p.RDB$ENTRYPOINT", "VARCHAR(255)") + `,
+			` + l.since(3, 0, "p.RDB$ENGINE_NAME", "VARCHAR(63)") + `, p.RDB$DESCRIPTION
+		FROM RDB$PROCEDURES p
+		WHERE COALESCE(p.RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY p.RDB$PROCEDURE_NAME`
+	err := l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, pkg, source, description sql.NullString
+			entry, engine                  sql.NullString
+			procedureType                  sql.NullInt64
+		)
+		if err := rows.Scan(&name, &pkg, &procedureType, &source, &entry, &engine, &description); err != nil {
+			return err
+		}
+		p := &Procedure{
+			Name:        trim(name),
+			Package:     trim(pkg),
+			Selectable:  procedureType.Int64 != 2,
+			Source:      trim(source),
+			EntryPoint:  trim(entry),
+			Engine:      trim(engine),
+			Description: trim(description),
+		}
+		procedures[qualifiedName(p.Package, p.Name)] = p
+		s.Procedures = append(s.Procedures, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	query = `
+		SELECT pp.RDB$PROCEDURE_NAME, ` + l.since(3, 0, "pp.RDB$PACKAGE_NAME", "VARCHAR(63)") + `,
+			pp.RDB$PARAMETER_NAME, pp.RDB$PARAMETER_NUMBER, pp.RDB$PARAMETER_TYPE, pp.RDB$FIELD_SOURCE,
+			` + fieldColumns("COALESCE(pp.RDB$COLLATION_ID, f.RDB$COLLATION_ID)") + `,
+			pp.RDB$NULL_FLAG, pp.RDB$DEFAULT_SOURCE, pp.RDB$PARAMETER_MECHANISM,
+			pp.RDB$RELATION_NAME, pp.RDB$FIELD_NAME
+		FROM RDB$PROCEDURE_PARAMETERS pp
+		JOIN RDB$FIELDS f ON f.RDB$FIELD_NAME = pp.RDB$FIELD_SOURCE
+		ORDER BY pp.RDB$PROCEDURE_NAME, pp.RDB$PARAMETER_TYPE, pp.RDB$PARAMETER_NUMBER`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			procedure, pkg, name, source        sql.NullString
+			defaultSource, relation, field      sql.NullString
+			number, parameterType, notNull, mec sql.NullInt64
+			ft                                  fieldType
+		)
+		dest := append([]any{&procedure, &pkg, &name, &number, &parameterType, &source}, ft.dest()...)
+		dest = append(dest, &notNull, &defaultSource, &mec, &relation, &field)
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		p := procedures[qualifiedName(trim(pkg), trim(procedure))]
+		if p == nil {
+			return nil
+		}
+		param := newParameter(source, &ft, notNull, defaultSource, mec, relation, field)
+		param.Name = trim(name)
+		param.Position = int(number.Int64)
+		param.Output = parameterType.Int64 == 1
+		if param.Output {
+			p.Outputs = append(p.Outputs, param)
+		} else {
+			p.Inputs = append(p.Inputs, param)
+		}
+		return nil
+	})
+}
+
+func newParameter(source sql.NullString, ft *fieldType, notNull sql.NullInt64, defaultSource sql.NullString,
+	mechanism sql.NullInt64, relation sql.NullString, field sql.NullString) *Parameter {
+	param := &Parameter{
+		Type:      ft.sqlType(),
+		Charset:   ft.charsetName(),
+		Collation: ft.collationName(),
+		Nullable:  notNull.Int64 == 0,
+		Default:   strings.TrimSpace(strings.TrimPrefix(stripKeyword(defaultSource, "DEFAULT"), "=")),
+		TypeOf:    mechanism.Int64 == 1,
+		Relation:  trim(relation),
+		Field:     trim(field),
+	}
+	if domain := trim(source); domain != "" && !strings.HasPrefix(domain, "RDB$") {
+		param.Domain = domain
+	}
+	return param
+}
+
+func (l *loader) loadFunctions(s *Schema) error {
+	functions := make(map[string]*Function)
+	returnArguments := make(map[*Function]int64)
+	query := `
+		SELECT fn.RDB$FUNCTION_NAME, ` + l.since(3, 0, "fn.RDB$PACKAGE_NAME", "VARCHAR(63)") + `,
+			` + l.since(3, 0, "fn.RDB$FUNCTION_SOURCE", "BLOB SUB_TYPE TEXT") + `,
+			fn.RDB$MODULE_NAME, fn.RDB$ENTRYPOINT,
+			` + l.since(3, 0, "fn.RDB$ENGINE_NAME", "VARCHAR(63)") + `,
+			` + l.since(3, 0, "fn.RDB$LEGACY_FLAG", "SMALLINT") + `,
+			` + l.since(3, 0, "fn.RDB$DETERMINISTIC_FLAG", "SMALLINT") + `,
+			fn.RDB$RETURN_ARGUMENT, fn.RDB$DESCRIPTION
+		FROM RDB$FUNCTIONS fn
+		WHERE COALESCE(fn.RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY fn.RDB$FUNCTION_NAME`
+	err := l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, pkg, source, module, entry, engine, description sql.NullString
+			legacy, deterministic, returnArgument                 sql.NullInt64
+		)
+		if err := rows.Scan(&name, &pkg, &source, &module, &entry, &engine, &legacy, &deterministic, &returnArgument, &description); err != nil {
+			return err
+		}
+		f := &Function{
+			Name:          trim(name),
+			Package:       trim(pkg),
+			Source:        trim(source),
+			ModuleName:    trim(module),
+			EntryPoint:    trim(entry),
+			Engine:        trim(engine),
+			Legacy:        legacy.Int64 == 1 || !l.version.AtLeast(3, 0),
+			Deterministic: deterministic.Int64 == 1,
+			Description:   trim(description),
+		}
+		functions[qualifiedName(f.Package, f.Name)] = f
+		returnArguments[f] = returnArgument.Int64
+		s.Functions = append(s.Functions, f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the type of UDF arguments is stored in RDB$FUNCTION_ARGUMENTS itself
+	col := func(column string) string {
+		switch column {
+		case "RDB$SEGMENT_LENGTH", "RDB$DIMENSIONS", "RDB$FIELD_NAME":
+			return "f." + column
+		}
+		return "COALESCE(f." + column + ", a." + column + ")"
+	}
+	query = `
+		SELECT a.RDB$FUNCTION_NAME, ` + l.since(3, 0, "a.RDB$PACKAGE_NAME", "VARCHAR(63)") + `,
+			` + l.since(3, 0, "a.RDB$ARGUMENT_NAME", "VARCHAR(63)") + `, a.RDB$ARGUMENT_POSITION,
+			` + l.since(3, 0, "a.RDB$FIELD_SOURCE", "VARCHAR(63)") + `,
+			` + typeColumns(col, "COALESCE("+l.since(3, 0, "a.RDB$COLLATION_ID", "SMALLINT")+", f.RDB$COLLATION_ID, 0)") + `,
+			` + l.since(3, 0, "a.RDB$NULL_FLAG", "SMALLINT") + `,
+			` + l.since(3, 0, "a.RDB$DEFAULT_SOURCE", "BLOB SUB_TYPE TEXT") + `,
+			` + l.since(3, 0, "a.RDB$ARGUMENT_MECHANISM", "SMALLINT") + `,
+			` + l.since(3, 0, "a.RDB$RELATION_NAME", "VARCHAR(63)") + `,
+			` + l.since(3, 0, "a.RDB$FIELD_NAME", "VARCHAR(63)") + `
+		FROM RDB$FUNCTION_ARGUMENTS a
+		LEFT JOIN RDB$FIELDS f ON f.RDB$FIELD_NAME = ` + l.since(3, 0, "a.RDB$FIELD_SOURCE", "VARCHAR(63)") + `
+		ORDER BY a.RDB$FUNCTION_NAME, a.RDB$ARGUMENT_POSITION`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			function, pkg, name, source    sql.NullString
+			defaultSource, relation, field sql.NullString
+			position, notNull, mechanism   sql.NullInt64
+			ft                             fieldType
+		)
+		dest := append([]any{&function, &pkg, &name, &position, &source}, ft.dest()...)
+		dest = append(dest, &notNull, &defaultSource, &mechanism, &relation, &field)
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		f := functions[qualifiedName(trim(pkg), trim(function))]
+		if f == nil {
+			return nil
+		}
+		if ret := returnArguments[f]; position.Int64 == ret {
+			if ret == 0 {
+				f.ReturnType = ft.sqlType()
+				f.ReturnCharset = ft.charsetName()
+				return nil
+			}
+			// DECLARE EXTERNAL FUNCTION ... RETURNS PARAMETER n
+			f.ReturnType = fmt.Sprintf("PARAMETER %d", ret)
+		}
+		param := newParameter(source, &ft, notNull, defaultSource, mechanism, relation, field)
+		param.Name = trim(name)
+		param.Position = int(position.Int64)
+		f.Arguments = append(f.Arguments, param)
+		return nil
+	})
+}
+
+func (l *loader) loadPackages(s *Schema) error {
+	if l.version.AtLeast(3, 0) {
+		query := `
+			SELECT RDB$PACKAGE_NAME, RDB$PACKAGE_HEADER_SOURCE, RDB$PACKAGE_BODY_SOURCE, RDB$DESCRIPTION
+			FROM RDB$PACKAGES
+			WHERE COALESCE(RDB$SYSTEM_FLAG, 0) = 0
+			ORDER BY RDB$PACKAGE_NAME`
+		err := l.query(query, func(rows *sql.Rows) error {
+			var name, header, body, description sql.NullString
+			if err := rows.Scan(&name, &header, &body, &description); err != nil {
+				return err
+			}
+			s.Packages = append(s.Packages, &Package{
+				Name:        trim(name),
+				Header:      trim(header),
+				Body:        trim(body),
+				Description: trim(description),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// move the routines of packages to their package
+	packages := make(map[string]*Package)
+	for _, p := range s.Packages {
+		packages[p.Name] = p
+	}
+	var procedures []*Procedure
+	for _, p := range s.Procedures {
+		if pkg := packages[p.Package]; pkg != nil {
+			pkg.Procedures = append(pkg.Procedures, p)
+		} else if p.Package == "" {
+			procedures = append(procedures, p)
+		}
+	}
+	s.Procedures = procedures
+	var functions []*Function
+	for _, f := range s.Functions {
+		if pkg := packages[f.Package]; pkg != nil {
+			pkg.Functions = append(pkg.Functions, f)
+		} else if f.Package == "" {
+			functions = append(functions, f)
+		}
+	}
+	s.Functions = functions
+	return nil
+}
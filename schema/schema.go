@@ -0,0 +1,393 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+/*
+Package schema reads the metadata of a Firebird database from the RDB$
+system tables into Go structs.
+
+	db, _ := sql.Open("firebirdsql", dsn)
+	s, err := schema.Load(ctx, db)
+	for _, table := range s.Tables {
+		fmt.Println(table.Name, len(table.Columns))
+	}
+
+Firebird 2.5 to 5.0 are supported; objects a server version does not have
+(packages, PSQL functions, identity columns, ...) are left empty.
+*/
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nakagami/firebirdsql"
+)
+
+// Queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Schema holds the user objects of a database. Names are as stored in the
+// system tables, i.e. upper-cased unless they were created quoted.
+type Schema struct {
+	Version        Version
+	Dialect        int
+	DefaultCharset string
+	Domains        []*Domain
+	Tables         []*Table
+	Views          []*View
+	Generators     []*Generator
+	Exceptions     []*Exception
+	Procedures     []*Procedure // procedures outside of packages
+	Functions      []*Function  // functions and UDFs outside of packages
+	Packages       []*Package
+	Triggers       []*Trigger // table, database and DDL triggers
+	Roles          []*Role
+	Grants         []*Grant // without the privileges of object owners
+}
+
+// Version is the engine version of the server, e.g. 3.0.
+type Version struct {
+	Major int
+	Minor int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// AtLeast reports whether v is major.minor or later.
+func (v Version) AtLeast(major int, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+type Domain struct {
+	Name        string
+	Type        string // SQL type, e.g. "VARCHAR(40)"
+	Charset     string
+	Collation   string
+	Nullable    bool
+	Default     string // without the DEFAULT keyword
+	Check       string // CHECK (...) clause
+	Description string
+}
+
+type Table struct {
+	Name         string
+	Columns      []*Column
+	Constraints  []*Constraint
+	Indices      []*Index // including the indices of constraints
+	Temporary    string   // "", "PRESERVE ROWS" or "DELETE ROWS" for global temporary tables
+	ExternalFile string
+	Description  string
+}
+
+// Column returns the column name, or nil.
+func (t *Table) Column(name string) *Column {
+	return findColumn(t.Columns, name)
+}
+
+// PrimaryKey returns the primary key constraint, or nil.
+func (t *Table) PrimaryKey() *Constraint {
+	for _, c := range t.Constraints {
+		if c.Type == PrimaryKey {
+			return c
+		}
+	}
+	return nil
+}
+
+type View struct {
+	Name        string
+	Columns     []*Column
+	Source      string // the SELECT statement
+	Description string
+}
+
+type Column struct {
+	Name           string
+	Position       int
+	Domain         string // empty unless the column is based on a user domain
+	Type           string // SQL type, e.g. "NUMERIC(18,2)"
+	Charset        string
+	Collation      string
+	Nullable       bool
+	Default        string // without the DEFAULT keyword
+	ComputedSource string // COMPUTED BY (...) expression
+	Identity       string // "", "ALWAYS" or "BY DEFAULT" (Firebird 3.0+)
+	Description    string
+}
+
+const (
+	PrimaryKey = "PRIMARY KEY"
+	ForeignKey = "FOREIGN KEY"
+	Unique     = "UNIQUE"
+	Check      = "CHECK"
+)
+
+type Constraint struct {
+	Name              string
+	Type              string // PrimaryKey, ForeignKey, Unique or Check
+	Columns           []string
+	Index             string
+	ReferencedTable   string // ForeignKey only
+	ReferencedColumns []string
+	UpdateRule        string // e.g. "CASCADE", "RESTRICT"
+	DeleteRule        string
+	Source            string // CHECK (...) clause
+}
+
+type Index struct {
+	Name        string
+	Table       string
+	Columns     []string
+	Expression  string // COMPUTED BY (...) expression index
+	Condition   string // partial index condition (Firebird 5.0+)
+	Unique      bool
+	Descending  bool
+	Active      bool
+	Constraint  string // the constraint the index enforces, if any
+	Description string
+}
+
+type Generator struct {
+	Name         string
+	InitialValue int64 // Firebird 3.0+
+	Increment    int64 // Firebird 3.0+, 1 for older servers
+	Description  string
+}
+
+type Exception struct {
+	Name        string
+	Number      int
+	Message     string
+	Description string
+}
+
+type Parameter struct {
+	Name      string
+	Position  int
+	Output    bool
+	Domain    string
+	TypeOf    bool   // declared as TYPE OF the domain or column
+	Relation  string // TYPE OF COLUMN relation.field
+	Field     string
+	Type      string
+	Charset   string
+	Collation string
+	Nullable  bool
+	Default   string
+}
+
+type Procedure struct {
+	Name        string
+	Package     string
+	Selectable  bool
+	Inputs      []*Parameter
+	Outputs     []*Parameter
+	Source      string // body, starting at AS or the first DECLARE/BEGIN
+	EntryPoint  string // external procedure, Firebird 3.0+
+	Engine      string // external engine, e.g. "UDR"
+	Description string
+}
+
+type Function struct {
+	Name          string
+	Package       string
+	Arguments     []*Parameter
+	ReturnType    string // or "PARAMETER n" for a UDF returning an argument
+	ReturnCharset string
+	Deterministic bool
+	Source        string // PSQL body (Firebird 3.0+)
+	ModuleName    string // external function (UDF) library
+	EntryPoint    string
+	Engine        string // external engine, e.g. "UDR"
+	Legacy        bool   // DECLARE EXTERNAL FUNCTION
+	Description   string
+}
+
+type Package struct {
+	Name        string
+	Header      string
+	Body        string
+	Procedures  []*Procedure
+	Functions   []*Function
+	Description string
+}
+
+type Trigger struct {
+	Name        string
+	Table       string // empty for database and DDL triggers
+	Event       string // e.g. "BEFORE INSERT OR UPDATE", "ON CONNECT", "AFTER CREATE TABLE"
+	Type        int64  // RDB$TRIGGER_TYPE
+	Position    int
+	Active      bool
+	Source      string
+	Description string
+}
+
+type Role struct {
+	Name        string
+	Owner       string
+	Description string
+}
+
+// Grant is a row of RDB$USER_PRIVILEGES.
+type Grant = firebirdsql.Privilege
+
+// Table returns the table name, or nil.
+func (s *Schema) Table(name string) *Table {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// View returns the view name, or nil.
+func (s *Schema) View(name string) *View {
+	for _, v := range s.Views {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func findColumn(columns []*Column, name string) *Column {
+	for _, c := range columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+var versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// ServerVersion returns the engine version of the database q is attached to.
+func ServerVersion(ctx context.Context, q Queryer) (Version, error) {
+	var s string
+	rows, err := q.QueryContext(ctx, "SELECT RDB$GET_CONTEXT('SYSTEM', 'ENGINE_VERSION') FROM RDB$DATABASE")
+	if err != nil {
+		return Version{}, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err = rows.Scan(&s); err != nil {
+			return Version{}, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return Version{}, err
+	}
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("unknown engine version %q", s)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// Load reads the metadata of the database q is attached to. Run it in a
+// snapshot transaction (q a *sql.Tx) for a consistent result while the
+// schema may change.
+func Load(ctx context.Context, q Queryer) (*Schema, error) {
+	version, err := ServerVersion(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Version: version}
+	l := &loader{ctx: ctx, q: q, version: version}
+	for _, load := range []func(*Schema) error{
+		l.loadDatabase,
+		l.loadDomains,
+		l.loadRelations,
+		l.loadIndices,
+		l.loadConstraints,
+		l.loadGenerators,
+		l.loadExceptions,
+		l.loadProcedures,
+		l.loadFunctions,
+		l.loadPackages,
+		l.loadTriggers,
+		l.loadRoles,
+		l.loadGrants,
+	} {
+		if err = load(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+type loader struct {
+	ctx      context.Context
+	q        Queryer
+	version  Version
+	segments map[string][]string // index name -> columns
+}
+
+// query runs query and calls scan for each row.
+func (l *loader) query(query string, scan func(rows *sql.Rows) error) error {
+	rows, err := l.q.QueryContext(l.ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// since returns expr on servers of version major.minor or later and a NULL
+// of sqlType on older ones, so that one query serves all versions.
+func (l *loader) since(major int, minor int, expr string, sqlType string) string {
+	if l.version.AtLeast(major, minor) {
+		return expr
+	}
+	return "CAST(NULL AS " + sqlType + ")"
+}
+
+func trim(s sql.NullString) string {
+	return strings.TrimSpace(s.String)
+}
+
+// stripKeyword removes a leading keyword like DEFAULT from a source column.
+func stripKeyword(s sql.NullString, keyword string) string {
+	src := strings.TrimSpace(s.String)
+	if len(src) >= len(keyword) && strings.EqualFold(src[:len(keyword)], keyword) {
+		src = strings.TrimSpace(src[len(keyword):])
+	}
+	return src
+}
@@ -0,0 +1,209 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/nakagami/firebirdsql"
+	"github.com/nakagami/firebirdsql/internal/fbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nullInt(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: true}
+}
+
+func TestFieldTypeSQLType(t *testing.T) {
+	tests := []struct {
+		field fieldType
+		want  string
+	}{
+		{fieldType{typ: nullInt(8)}, "INTEGER"},
+		{fieldType{typ: nullInt(16), subType: nullInt(1), precision: nullInt(18), scale: nullInt(-2)}, "NUMERIC(18,2)"},
+		{fieldType{typ: nullInt(8), subType: nullInt(2), precision: nullInt(9), scale: nullInt(-3)}, "DECIMAL(9,3)"},
+		{fieldType{typ: nullInt(7), scale: nullInt(-1)}, "NUMERIC(4,1)"},
+		{fieldType{typ: nullInt(26)}, "INT128"},
+		{fieldType{typ: nullInt(37), length: nullInt(160), charLength: nullInt(40)}, "VARCHAR(40)"},
+		{fieldType{typ: nullInt(14), length: nullInt(10)}, "CHAR(10)"},
+		{fieldType{typ: nullInt(261), subType: nullInt(1), segment: nullInt(80)}, "BLOB SUB_TYPE TEXT"},
+		{fieldType{typ: nullInt(261), subType: nullInt(0), segment: nullInt(4096)}, "BLOB SUB_TYPE BINARY SEGMENT SIZE 4096"},
+		{fieldType{typ: nullInt(29)}, "TIMESTAMP WITH TIME ZONE"},
+		{fieldType{typ: nullInt(25)}, "DECFLOAT(34)"},
+		{fieldType{typ: nullInt(8), dimensions: nullInt(1), bounds: nullString("1:1:10")}, "INTEGER[1:10]"},
+		{fieldType{typ: nullInt(8), dimensions: nullInt(2), bounds: nullString("2:0:3,1:-1:5")}, "INTEGER[-1:5,0:3]"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.field.sqlType())
+	}
+
+	f := fieldType{typ: nullInt(37), charset: nullString("UTF8   "), collation: nullString("UNICODE_CI")}
+	assert.Equal(t, "UTF8", f.charsetName())
+	assert.Equal(t, "UNICODE_CI", f.collationName())
+	f.collation = nullString("UTF8")
+	assert.Equal(t, "", f.collationName())
+	f = fieldType{typ: nullInt(8), charset: nullString("NONE")}
+	assert.Equal(t, "", f.charsetName())
+}
+
+func TestTriggerEvent(t *testing.T) {
+	assert.Equal(t, "BEFORE INSERT", triggerEvent(1))
+	assert.Equal(t, "AFTER INSERT", triggerEvent(2))
+	assert.Equal(t, "BEFORE UPDATE", triggerEvent(3))
+	assert.Equal(t, "AFTER DELETE", triggerEvent(6))
+	assert.Equal(t, "BEFORE INSERT OR UPDATE", triggerEvent(17))
+	assert.Equal(t, "AFTER INSERT OR UPDATE OR DELETE", triggerEvent(114))
+	assert.Equal(t, "ON CONNECT", triggerEvent(8192))
+	assert.Equal(t, "ON TRANSACTION ROLLBACK", triggerEvent(8196))
+	assert.Equal(t, "BEFORE CREATE TABLE", triggerEvent(triggerTypeDDL|1<<1))
+	assert.Equal(t, "AFTER CREATE TABLE OR DROP TABLE", triggerEvent(triggerTypeDDL|1|1<<1|1<<3))
+	assert.Equal(t, "AFTER ANY DDL STATEMENT", triggerEvent(0x7FFFFFFFFFFFDFFF))
+}
+
+func TestVersion(t *testing.T) {
+	v := Version{Major: 3, Minor: 0}
+	assert.True(t, v.AtLeast(2, 5))
+	assert.True(t, v.AtLeast(3, 0))
+	assert.False(t, v.AtLeast(4, 0))
+	assert.Equal(t, "3.0", v.String())
+}
+
+func TestLoad(t *testing.T) {
+	db, err := sql.Open("firebirdsql_createdb", fbtest.GetTestDSN("test_schema_"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE DOMAIN d_name AS VARCHAR(40) CHARACTER SET UTF8 NOT NULL",
+		"CREATE DOMAIN d_amount AS NUMERIC(18,2) DEFAULT 0 CHECK (VALUE >= 0)",
+		`CREATE TABLE customer (
+			id INTEGER NOT NULL PRIMARY KEY,
+			name d_name,
+			note VARCHAR(100) DEFAULT 'none',
+			CONSTRAINT uq_customer_name UNIQUE (name))`,
+		`CREATE TABLE orders (
+			id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL,
+			amount d_amount,
+			total COMPUTED BY (amount * 2),
+			CONSTRAINT pk_orders PRIMARY KEY (id),
+			CONSTRAINT fk_orders_customer FOREIGN KEY (customer_id) REFERENCES customer (id) ON DELETE CASCADE,
+			CONSTRAINT ck_orders_id CHECK (id > 0))`,
+		"CREATE DESCENDING INDEX ix_orders_amount ON orders (amount)",
+		"CREATE VIEW big_orders (id, amount) AS SELECT id, amount FROM orders WHERE amount > 100",
+		"CREATE GENERATOR gen_orders",
+		"CREATE EXCEPTION e_bad 'bad thing'",
+		"CREATE ROLE clerk",
+		`CREATE PROCEDURE add_order (id INTEGER, amount NUMERIC(18,2) = 1) RETURNS (n INTEGER) AS
+		BEGIN
+			n = id;
+			SUSPEND;
+		END`,
+		`CREATE TRIGGER orders_bi FOR orders ACTIVE BEFORE INSERT OR UPDATE POSITION 5 AS
+		BEGIN
+		END`,
+	} {
+		_, err = db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	s, err := Load(context.Background(), db)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, s.Version.Major, 2)
+
+	require.Len(t, s.Domains, 2)
+	assert.Equal(t, &Domain{Name: "D_AMOUNT", Type: "NUMERIC(18,2)", Nullable: true, Default: "0", Check: "CHECK (VALUE >= 0)"}, s.Domains[0])
+	assert.Equal(t, "VARCHAR(40)", s.Domains[1].Type)
+	assert.Equal(t, "UTF8", s.Domains[1].Charset)
+	assert.False(t, s.Domains[1].Nullable)
+
+	customer := s.Table("CUSTOMER")
+	require.NotNil(t, customer)
+	require.Len(t, customer.Columns, 3)
+	assert.Equal(t, "D_NAME", customer.Column("NAME").Domain)
+	assert.False(t, customer.Column("NAME").Nullable)
+	assert.Equal(t, "'none'", customer.Column("NOTE").Default)
+	assert.Equal(t, []string{"ID"}, customer.PrimaryKey().Columns)
+
+	orders := s.Table("ORDERS")
+	require.NotNil(t, orders)
+	assert.Equal(t, "(amount * 2)", orders.Column("TOTAL").ComputedSource)
+	var fk, check *Constraint
+	for _, c := range orders.Constraints {
+		switch c.Type {
+		case ForeignKey:
+			fk = c
+		case Check:
+			check = c
+		}
+	}
+	require.NotNil(t, fk)
+	assert.Equal(t, "CUSTOMER", fk.ReferencedTable)
+	assert.Equal(t, []string{"ID"}, fk.ReferencedColumns)
+	assert.Equal(t, "CASCADE", fk.DeleteRule)
+	require.NotNil(t, check)
+	assert.Equal(t, "CHECK (id > 0)", check.Source)
+	for _, ix := range orders.Indices {
+		if ix.Name == "IX_ORDERS_AMOUNT" {
+			assert.True(t, ix.Descending)
+			assert.Equal(t, "", ix.Constraint)
+		}
+		if ix.Name == "PK_ORDERS" {
+			assert.Equal(t, "PK_ORDERS", ix.Constraint)
+		}
+	}
+
+	view := s.View("BIG_ORDERS")
+	require.NotNil(t, view)
+	assert.Len(t, view.Columns, 2)
+	assert.Contains(t, view.Source, "amount > 100")
+
+	require.Len(t, s.Generators, 1)
+	assert.Equal(t, "GEN_ORDERS", s.Generators[0].Name)
+	require.Len(t, s.Exceptions, 1)
+	assert.Equal(t, "bad thing", s.Exceptions[0].Message)
+	require.Len(t, s.Roles, 1)
+	assert.Equal(t, "CLERK", s.Roles[0].Name)
+
+	require.Len(t, s.Procedures, 1)
+	p := s.Procedures[0]
+	assert.True(t, p.Selectable)
+	require.Len(t, p.Inputs, 2)
+	assert.Equal(t, "NUMERIC(18,2)", p.Inputs[1].Type)
+	assert.Equal(t, "1", p.Inputs[1].Default)
+	require.Len(t, p.Outputs, 1)
+	assert.Equal(t, "N", p.Outputs[0].Name)
+
+	require.Len(t, s.Triggers, 1)
+	assert.Equal(t, "ORDERS", s.Triggers[0].Table)
+	assert.Equal(t, "BEFORE INSERT OR UPDATE", s.Triggers[0].Event)
+	assert.Equal(t, 5, s.Triggers[0].Position)
+}
@@ -0,0 +1,253 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package schema
+
+import (
+	"database/sql"
+	"strings"
+)
+
+func (l *loader) loadDomains(s *Schema) error {
+	query := `
+		SELECT f.RDB$FIELD_NAME, ` + fieldColumns("f.RDB$COLLATION_ID") + `,
+			f.RDB$NULL_FLAG, f.RDB$DEFAULT_SOURCE, f.RDB$VALIDATION_SOURCE, f.RDB$DESCRIPTION
+		FROM RDB$FIELDS f
+		WHERE f.RDB$FIELD_NAME NOT STARTING WITH 'RDB$'
+			AND COALESCE(f.RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY f.RDB$FIELD_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, defaultSource, check, description sql.NullString
+			notNull                                 sql.NullInt64
+			ft                                      fieldType
+		)
+		dest := append([]any{&name}, ft.dest()...)
+		dest = append(dest, &notNull, &defaultSource, &check, &description)
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		s.Domains = append(s.Domains, &Domain{
+			Name:        trim(name),
+			Type:        ft.sqlType(),
+			Charset:     ft.charsetName(),
+			Collation:   ft.collationName(),
+			Nullable:    notNull.Int64 == 0,
+			Default:     stripKeyword(defaultSource, "DEFAULT"),
+			Check:       trim(check),
+			Description: trim(description),
+		})
+		return nil
+	})
+}
+
+func (l *loader) loadRelations(s *Schema) error {
+	query := `
+		SELECT r.RDB$RELATION_NAME, IIF(r.RDB$VIEW_BLR IS NULL, 0, 1), r.RDB$VIEW_SOURCE,
+			r.RDB$EXTERNAL_FILE, ` + l.since(2, 5, "r.RDB$RELATION_TYPE", "SMALLINT") + `,
+			r.RDB$DESCRIPTION
+		FROM RDB$RELATIONS r
+		WHERE COALESCE(r.RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY r.RDB$RELATION_NAME`
+	err := l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, source, external, description sql.NullString
+			isView                              int
+			relationType                        sql.NullInt64
+		)
+		if err := rows.Scan(&name, &isView, &source, &external, &relationType, &description); err != nil {
+			return err
+		}
+		if isView != 0 {
+			v := &View{Name: trim(name), Source: trim(source), Description: trim(description)}
+			s.Views = append(s.Views, v)
+			return nil
+		}
+		t := &Table{Name: trim(name), ExternalFile: trim(external), Description: trim(description)}
+		switch relationType.Int64 {
+		case 4:
+			t.Temporary = "PRESERVE ROWS"
+		case 5:
+			t.Temporary = "DELETE ROWS"
+		}
+		s.Tables = append(s.Tables, t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return l.loadColumns(s)
+}
+
+func (l *loader) loadColumns(s *Schema) error {
+	query := `
+		SELECT rf.RDB$RELATION_NAME, rf.RDB$FIELD_NAME, rf.RDB$FIELD_POSITION, rf.RDB$FIELD_SOURCE,
+			` + fieldColumns("COALESCE(rf.RDB$COLLATION_ID, f.RDB$COLLATION_ID)") + `,
+			rf.RDB$NULL_FLAG, f.RDB$NULL_FLAG,
+			COALESCE(rf.RDB$DEFAULT_SOURCE, f.RDB$DEFAULT_SOURCE), f.RDB$COMPUTED_SOURCE,
+			` + l.since(3, 0, "rf.RDB$IDENTITY_TYPE", "SMALLINT") + `, rf.RDB$DESCRIPTION
+		FROM RDB$RELATION_FIELDS rf
+		JOIN RDB$FIELDS f ON f.RDB$FIELD_NAME = rf.RDB$FIELD_SOURCE
+		JOIN RDB$RELATIONS r ON r.RDB$RELATION_NAME = rf.RDB$RELATION_NAME
+		WHERE COALESCE(r.RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY rf.RDB$RELATION_NAME, rf.RDB$FIELD_POSITION`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			relation, name, source           sql.NullString
+			defaultSource, computed, descr   sql.NullString
+			position, notNull, domainNotNull sql.NullInt64
+			identity                         sql.NullInt64
+			ft                               fieldType
+		)
+		dest := append([]any{&relation, &name, &position, &source}, ft.dest()...)
+		dest = append(dest, &notNull, &domainNotNull, &defaultSource, &computed, &identity, &descr)
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		c := &Column{
+			Name:           trim(name),
+			Position:       int(position.Int64),
+			Type:           ft.sqlType(),
+			Charset:        ft.charsetName(),
+			Collation:      ft.collationName(),
+			Nullable:       notNull.Int64 == 0 && domainNotNull.Int64 == 0,
+			Default:        stripKeyword(defaultSource, "DEFAULT"),
+			ComputedSource: trim(computed),
+			Description:    trim(descr),
+		}
+		if domain := trim(source); !strings.HasPrefix(domain, "RDB$") {
+			c.Domain = domain
+		}
+		if identity.Valid {
+			c.Identity = map[int64]string{0: "ALWAYS", 1: "BY DEFAULT"}[identity.Int64]
+		}
+		if t := s.Table(trim(relation)); t != nil {
+			t.Columns = append(t.Columns, c)
+		} else if v := s.View(trim(relation)); v != nil {
+			v.Columns = append(v.Columns, c)
+		}
+		return nil
+	})
+}
+
+func (l *loader) loadIndices(s *Schema) error {
+	segments := make(map[string][]string)
+	err := l.query(`
+		SELECT RDB$INDEX_NAME, RDB$FIELD_NAME FROM RDB$INDEX_SEGMENTS
+		ORDER BY RDB$INDEX_NAME, RDB$FIELD_POSITION`, func(rows *sql.Rows) error {
+		var index, field sql.NullString
+		if err := rows.Scan(&index, &field); err != nil {
+			return err
+		}
+		segments[trim(index)] = append(segments[trim(index)], trim(field))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	l.segments = segments
+
+	query := `
+		SELECT i.RDB$INDEX_NAME, i.RDB$RELATION_NAME, i.RDB$UNIQUE_FLAG, i.RDB$INDEX_TYPE,
+			i.RDB$INDEX_INACTIVE, i.RDB$EXPRESSION_SOURCE,
+			` + l.since(5, 0, "i.RDB$CONDITION_SOURCE", "BLOB SUB_TYPE TEXT") + `, i.RDB$DESCRIPTION
+		FROM RDB$INDICES i
+		JOIN RDB$RELATIONS r ON r.RDB$RELATION_NAME = i.RDB$RELATION_NAME
+		WHERE COALESCE(r.RDB$SYSTEM_FLAG, 0) = 0
+		ORDER BY i.RDB$RELATION_NAME, i.RDB$INDEX_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, relation, expression, condition, description sql.NullString
+			unique, indexType, inactive                        sql.NullInt64
+		)
+		if err := rows.Scan(&name, &relation, &unique, &indexType, &inactive, &expression, &condition, &description); err != nil {
+			return err
+		}
+		t := s.Table(trim(relation))
+		if t == nil {
+			return nil
+		}
+		t.Indices = append(t.Indices, &Index{
+			Name:        trim(name),
+			Table:       t.Name,
+			Columns:     segments[trim(name)],
+			Expression:  trim(expression),
+			Condition:   stripKeyword(condition, "WHERE"),
+			Unique:      unique.Int64 == 1,
+			Descending:  indexType.Int64 == 1,
+			Active:      inactive.Int64 == 0,
+			Description: trim(description),
+		})
+		return nil
+	})
+}
+
+func (l *loader) loadConstraints(s *Schema) error {
+	query := `
+		SELECT rc.RDB$CONSTRAINT_NAME, rc.RDB$CONSTRAINT_TYPE, rc.RDB$RELATION_NAME, rc.RDB$INDEX_NAME,
+			refc.RDB$UPDATE_RULE, refc.RDB$DELETE_RULE, uq.RDB$RELATION_NAME, uq.RDB$INDEX_NAME,
+			(SELECT FIRST 1 t.RDB$TRIGGER_SOURCE
+				FROM RDB$CHECK_CONSTRAINTS cc
+				JOIN RDB$TRIGGERS t ON t.RDB$TRIGGER_NAME = cc.RDB$TRIGGER_NAME
+				WHERE cc.RDB$CONSTRAINT_NAME = rc.RDB$CONSTRAINT_NAME
+					AND rc.RDB$CONSTRAINT_TYPE = 'CHECK')
+		FROM RDB$RELATION_CONSTRAINTS rc
+		LEFT JOIN RDB$REF_CONSTRAINTS refc ON refc.RDB$CONSTRAINT_NAME = rc.RDB$CONSTRAINT_NAME
+		LEFT JOIN RDB$RELATION_CONSTRAINTS uq ON uq.RDB$CONSTRAINT_NAME = refc.RDB$CONST_NAME_UQ
+		WHERE rc.RDB$CONSTRAINT_TYPE IN ('PRIMARY KEY', 'FOREIGN KEY', 'UNIQUE', 'CHECK')
+		ORDER BY rc.RDB$RELATION_NAME, rc.RDB$CONSTRAINT_NAME`
+	return l.query(query, func(rows *sql.Rows) error {
+		var (
+			name, typ, relation, index            sql.NullString
+			updateRule, deleteRule, refRel, refIx sql.NullString
+			source                                sql.NullString
+		)
+		if err := rows.Scan(&name, &typ, &relation, &index, &updateRule, &deleteRule, &refRel, &refIx, &source); err != nil {
+			return err
+		}
+		t := s.Table(trim(relation))
+		if t == nil {
+			return nil
+		}
+		c := &Constraint{
+			Name:    trim(name),
+			Type:    trim(typ),
+			Columns: l.segments[trim(index)],
+			Index:   trim(index),
+			Source:  trim(source),
+		}
+		if c.Type == ForeignKey {
+			c.ReferencedTable = trim(refRel)
+			c.ReferencedColumns = l.segments[trim(refIx)]
+			c.UpdateRule = trim(updateRule)
+			c.DeleteRule = trim(deleteRule)
+		}
+		for _, ix := range t.Indices {
+			if ix.Name == c.Index {
+				ix.Constraint = c.Name
+			}
+		}
+		t.Constraints = append(t.Constraints, c)
+		return nil
+	})
+}
@@ -0,0 +1,149 @@
+SET SQL DIALECT 3;
+
+/* External functions */
+DECLARE EXTERNAL FUNCTION ABS
+  DOUBLE PRECISION
+  RETURNS DOUBLE PRECISION
+  ENTRY_POINT 'IB_UDF_abs' MODULE_NAME 'ib_udf';
+
+/* Generators or sequences */
+CREATE GENERATOR GEN_ORDERS START WITH 100;
+
+/* Exceptions */
+CREATE EXCEPTION E_BAD 'it''s bad';
+
+/* Domain definitions */
+CREATE DOMAIN D_AMOUNT AS NUMERIC(18,2) DEFAULT 0 CHECK (VALUE >= 0);
+CREATE DOMAIN D_NAME AS VARCHAR(40) NOT NULL;
+
+/* Table definitions */
+CREATE TABLE CUSTOMER (
+  ID INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+  NAME D_NAME,
+  NOTE VARCHAR(100) DEFAULT 'none' COLLATE UNICODE_CI,
+  CONSTRAINT PK_CUSTOMER PRIMARY KEY (ID),
+  UNIQUE (NAME));
+CREATE TABLE ORDERS (
+  ID INTEGER NOT NULL,
+  CUSTOMER_ID INTEGER NOT NULL,
+  AMOUNT D_AMOUNT,
+  TOTAL COMPUTED BY (amount * 2),
+  "Order" DATE,
+  CONSTRAINT PK_ORDERS PRIMARY KEY (ID) USING INDEX IX_PK_ORDERS);
+
+/* Stored functions headers */
+SET TERM ^ ;
+CREATE OR ALTER FUNCTION TWICE (
+  X INTEGER)
+RETURNS INTEGER DETERMINISTIC
+AS
+BEGIN
+  RETURN NULL;
+END^
+
+SET TERM ; ^
+
+/* Stored procedures headers */
+SET TERM ^ ;
+CREATE OR ALTER PROCEDURE ADD_ORDER (
+  ID INTEGER,
+  AMOUNT TYPE OF D_AMOUNT = 1)
+RETURNS (
+  N INTEGER)
+AS
+BEGIN
+  SUSPEND;
+END^
+
+SET TERM ; ^
+
+/* Package headers */
+SET TERM ^ ;
+CREATE OR ALTER PACKAGE PKG
+AS
+BEGIN
+  FUNCTION one RETURNS INTEGER;
+END^
+
+SET TERM ; ^
+
+/* Index definitions */
+CREATE DESCENDING INDEX IX_ORDERS_AMOUNT ON ORDERS (AMOUNT);
+ALTER INDEX IX_ORDERS_AMOUNT INACTIVE;
+CREATE INDEX IX_ORDERS_TOTAL ON ORDERS COMPUTED BY (amount * 2) WHERE amount > 0;
+
+/* Foreign key constraints */
+ALTER TABLE ORDERS ADD CONSTRAINT FK_ORDERS_CUSTOMER FOREIGN KEY (CUSTOMER_ID) REFERENCES CUSTOMER (ID) ON DELETE CASCADE;
+
+/* View definitions */
+CREATE VIEW BIG_ORDERS (ID, AMOUNT) AS
+SELECT id, amount FROM orders WHERE amount > 100;
+CREATE VIEW TOP_ORDERS (ID) AS
+SELECT id FROM big_orders WHERE amount > 1000;
+
+/* Stored functions bodies */
+SET TERM ^ ;
+ALTER FUNCTION TWICE (
+  X INTEGER)
+RETURNS INTEGER DETERMINISTIC
+AS
+BEGIN
+  RETURN x * 2;
+END^
+
+SET TERM ; ^
+
+/* Stored procedures bodies */
+SET TERM ^ ;
+ALTER PROCEDURE ADD_ORDER (
+  ID INTEGER,
+  AMOUNT TYPE OF D_AMOUNT = 1)
+RETURNS (
+  N INTEGER)
+AS
+BEGIN
+  n = id;
+  SUSPEND;
+END^
+
+SET TERM ; ^
+
+/* Package bodies */
+SET TERM ^ ;
+RECREATE PACKAGE BODY PKG
+AS
+BEGIN
+  FUNCTION one RETURNS INTEGER AS BEGIN RETURN 1; END
+END^
+
+SET TERM ; ^
+
+/* Check constraints */
+ALTER TABLE ORDERS ADD CONSTRAINT CK_ORDERS_ID CHECK (id > 0);
+
+/* Triggers */
+SET TERM ^ ;
+CREATE TRIGGER ORDERS_BI FOR ORDERS ACTIVE BEFORE INSERT OR UPDATE POSITION 5
+AS
+BEGIN
+END^
+
+CREATE TRIGGER TR_CONNECT INACTIVE ON CONNECT POSITION 0
+AS
+BEGIN
+END^
+
+SET TERM ; ^
+
+/* Roles */
+CREATE ROLE CLERK;
+
+/* Grant permissions */
+GRANT CLERK TO ALICE WITH ADMIN OPTION;
+GRANT ALL ON TABLE ORDERS TO ROLE CLERK;
+GRANT UPDATE (NOTE) ON TABLE CUSTOMER TO ALICE;
+GRANT EXECUTE ON PROCEDURE ADD_ORDER TO PUBLIC WITH GRANT OPTION;
+
+/* Comments */
+COMMENT ON DOMAIN D_AMOUNT IS 'money';
+COMMENT ON COLUMN CUSTOMER.NOTE IS 'free text';
@@ -0,0 +1,273 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ScriptStatement is a statement of an isql script.
+type ScriptStatement struct {
+	SQL    string // without the terminator and leading comments
+	Line   int    // 1-based position of the statement in the script
+	Column int
+}
+
+// ScriptError is returned when a script cannot be parsed or one of its
+// statements fails.
+type ScriptError struct {
+	Line   int
+	Column int
+	SQL    string // empty for parse errors
+	Err    error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	scriptSetTermPattern  = regexp.MustCompile(`(?is)^SET\s+TERM\s+(\S+)$`)
+	scriptAutoDDLPattern  = regexp.MustCompile(`(?is)^SET\s+AUTODDL(\s+(ON|OFF))?$`)
+	scriptCommitPattern   = regexp.MustCompile(`(?is)^COMMIT(\s+WORK)?$`)
+	scriptRollbackPattern = regexp.MustCompile(`(?is)^ROLLBACK(\s+WORK)?$`)
+	scriptExitPattern     = regexp.MustCompile(`(?is)^(EXIT|QUIT)$`)
+	// isql commands that only change the output or the session of isql
+	scriptSkipPattern = regexp.MustCompile(`(?is)^(CREATE\s+(DATABASE|SCHEMA)\s|CONNECT\s|SHOW\s|INPUT\s|OUTPUT\b|SET\s+(NAMES|SQL\s+DIALECT|ECHO|LIST|STATS|PLAN|PLANONLY|EXPLAIN|COUNT|HEADING|WARNINGS|WNG|BAIL|BLOB|BLOBDISPLAY|ROWCOUNT|SQLDA_DISPLAY|PER_TABLE_STATS|KEEP_TRAN_PARAMS|WIDTH)\b|SET\s+TIME(\s+(ON|OFF))?$)`)
+	scriptDDLPattern  = regexp.MustCompile(`(?is)^(CREATE|ALTER|DROP|RECREATE|DECLARE|COMMENT|GRANT|REVOKE|SET\s+GENERATOR|SET\s+STATISTICS)\b`)
+)
+
+// q'<...>' literal delimiters that close with a different character
+var scriptQuoteClose = map[byte]byte{'(': ')', '[': ']', '{': '}', '<': '>'}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= 0x80
+}
+
+// SplitScript splits an isql script into statements. SET TERM changes the
+// terminator and is not returned; terminators in string literals, q'{...}'
+// literals, quoted identifiers and comments are ignored.
+func SplitScript(r io.Reader) ([]ScriptStatement, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	script := string(b)
+
+	line, lineStart := 1, 0
+	position := func(i int) (int, int) {
+		for j := lineStart; j < i; j++ {
+			if script[j] == '\n' {
+				line++
+				lineStart = j + 1
+			}
+		}
+		return line, utf8.RuneCountInString(script[lineStart:i]) + 1
+	}
+	parseError := func(i int, format string, args ...any) error {
+		l, c := position(i)
+		return &ScriptError{Line: l, Column: c, Err: fmt.Errorf(format, args...)}
+	}
+
+	var statements []ScriptStatement
+	term := ";"
+	start := -1 // offset of the first token of the current statement
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+			continue
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				return nil, parseError(i, "unterminated comment")
+			}
+			i += end + 4
+			continue
+		}
+		if start < 0 && !strings.ContainsRune(" \t\r\n", rune(c)) {
+			start = i
+		}
+		switch {
+		case (c == 'q' || c == 'Q') && i+2 < len(script) && script[i+1] == '\'' &&
+			(i == 0 || !isIdentifierByte(script[i-1])):
+			open := script[i+2]
+			closing, ok := scriptQuoteClose[open]
+			if !ok {
+				closing = open
+			}
+			end := strings.Index(script[i+3:], string([]byte{closing, '\''}))
+			if end < 0 {
+				return nil, parseError(i, "unterminated string literal")
+			}
+			i += end + 5
+		case c == '\'' || c == '"':
+			j := i + 1
+			for {
+				k := strings.IndexByte(script[j:], c)
+				if k < 0 {
+					if c == '"' {
+						return nil, parseError(i, "unterminated quoted identifier")
+					}
+					return nil, parseError(i, "unterminated string literal")
+				}
+				j += k + 1
+				if j < len(script) && script[j] == c {
+					j++ // doubled quote
+					continue
+				}
+				break
+			}
+			i = j
+		case strings.HasPrefix(script[i:], term):
+			if start >= 0 && start < i {
+				stmt := strings.TrimSpace(script[start:i])
+				if m := scriptSetTermPattern.FindStringSubmatch(stmt); m != nil {
+					term = m[1]
+				} else {
+					l, col := position(start)
+					statements = append(statements, ScriptStatement{SQL: stmt, Line: l, Column: col})
+				}
+			}
+			i += len(term)
+			start = -1
+		default:
+			i++
+		}
+	}
+	if start >= 0 {
+		l, col := position(start)
+		statements = append(statements, ScriptStatement{SQL: strings.TrimSpace(script[start:]), Line: l, Column: col})
+	}
+	return statements, nil
+}
+
+// ExecScript runs an isql script on conn. Like isql with AUTODDL ON, the
+// transaction is committed after each DDL statement; COMMIT, ROLLBACK,
+// SET AUTODDL, EXIT and QUIT are honoured, and the transaction is committed
+// at the end. isql commands such as SET NAMES or SHOW are skipped, and so
+// are CREATE DATABASE and CONNECT: conn is already attached, create the
+// database with CreateDatabase first.
+//
+// If a statement fails, the work not yet committed is rolled back and a
+// *ScriptError with the position of the statement is returned.
+func ExecScript(ctx context.Context, conn *sql.Conn, r io.Reader) error {
+	statements, err := SplitScript(r)
+	if err != nil {
+		return err
+	}
+	return conn.Raw(func(driverConn any) error {
+		fc, ok := driverConn.(*firebirdsqlConn)
+		if !ok {
+			return ErrNotFirebirdConn
+		}
+		return fc.execScript(ctx, statements)
+	})
+}
+
+func (fc *firebirdsqlConn) execScript(ctx context.Context, statements []ScriptStatement) (err error) {
+	// The statements run in a transaction of their own, so neither client
+	// nor server autocommit ends it before COMMIT or ROLLBACK.
+	saved := fc.tx
+	if saved.implicit && !saved.needBegin {
+		if err = saved.Commit(); err != nil {
+			return err
+		}
+	}
+	tx, err := newFirebirdsqlTx(fc, ISOLATION_LEVEL_READ_COMMITED, false, false)
+	if err != nil {
+		return err
+	}
+	fc.tx = tx
+	defer func() { fc.tx = saved }()
+
+	// Commit and Rollback turn tx back into an implicit transaction.
+	commit := func() error {
+		if tx.needBegin {
+			return nil
+		}
+		err := tx.Commit()
+		tx.isAutocommit, tx.implicit = false, false
+		return err
+	}
+	rollback := func() error {
+		if tx.needBegin {
+			return nil
+		}
+		err := tx.Rollback()
+		tx.isAutocommit, tx.implicit = false, false
+		return err
+	}
+	autoDDL := true
+	for _, st := range statements {
+		if err = ctx.Err(); err != nil {
+			_ = rollback()
+			return err
+		}
+		switch {
+		case scriptAutoDDLPattern.MatchString(st.SQL):
+			m := scriptAutoDDLPattern.FindStringSubmatch(st.SQL)
+			if m[2] == "" {
+				autoDDL = !autoDDL
+			} else {
+				autoDDL = strings.EqualFold(m[2], "ON")
+			}
+		case scriptCommitPattern.MatchString(st.SQL):
+			err = commit()
+		case scriptRollbackPattern.MatchString(st.SQL):
+			err = rollback()
+		case scriptExitPattern.MatchString(st.SQL):
+			if strings.EqualFold(st.SQL, "QUIT") {
+				return rollback()
+			}
+			return commit()
+		case scriptSkipPattern.MatchString(st.SQL):
+		default:
+			_, err = fc.exec(ctx, st.SQL, nil)
+			if err == nil && autoDDL && scriptDDLPattern.MatchString(st.SQL) {
+				err = commit()
+			}
+		}
+		if err != nil {
+			_ = rollback()
+			return &ScriptError{Line: st.Line, Column: st.Column, SQL: st.SQL, Err: err}
+		}
+	}
+	return commit()
+}
@@ -0,0 +1,129 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitScript(t *testing.T) {
+	script := `-- create the table
+CREATE TABLE t1 (id INTEGER, s VARCHAR(10) DEFAULT 'a;b');
+/* a ; comment */ INSERT INTO "T;1" VALUES (1, 'it''s;');
+INSERT INTO t1 VALUES (2, q'{it's; }');
+SET TERM ^ ;
+CREATE PROCEDURE p1 AS
+BEGIN
+  -- end;
+  INSERT INTO t1 VALUES (1, ';');
+END^
+EXECUTE BLOCK AS BEGIN END^
+SET TERM ; ^
+COMMIT;
+DROP TABLE t2`
+	statements, err := SplitScript(strings.NewReader(script))
+	require.NoError(t, err)
+	assert.Equal(t, []ScriptStatement{
+		{"CREATE TABLE t1 (id INTEGER, s VARCHAR(10) DEFAULT 'a;b')", 2, 1},
+		{"INSERT INTO \"T;1\" VALUES (1, 'it''s;')", 3, 19},
+		{"INSERT INTO t1 VALUES (2, q'{it's; }')", 4, 1},
+		{"CREATE PROCEDURE p1 AS\nBEGIN\n  -- end;\n  INSERT INTO t1 VALUES (1, ';');\nEND", 6, 1},
+		{"EXECUTE BLOCK AS BEGIN END", 11, 1},
+		{"COMMIT", 13, 1},
+		{"DROP TABLE t2", 14, 1},
+	}, statements)
+
+	for _, tc := range []struct {
+		script string
+		line   int
+		column int
+	}{
+		{"SELECT 1 FROM t1;\nSELECT 'abc FROM t1;", 2, 8},
+		{"SELECT 1 /* FROM t1;", 1, 10},
+		{"SELECT \"abc FROM t1;", 1, 8},
+		{"SELECT q'(abc' FROM t1;", 1, 8},
+	} {
+		_, err = SplitScript(strings.NewReader(tc.script))
+		var scriptErr *ScriptError
+		require.True(t, errors.As(err, &scriptErr), tc.script)
+		assert.Equal(t, tc.line, scriptErr.Line, tc.script)
+		assert.Equal(t, tc.column, scriptErr.Column, tc.script)
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	db, err := sql.Open("firebirdsql_createdb", GetTestDSN("test_exec_script_"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	err = ExecScript(ctx, conn, strings.NewReader(`
+SET SQL DIALECT 3;
+SET NAMES UTF8;
+CREATE TABLE t1 (id INTEGER NOT NULL PRIMARY KEY, s VARCHAR(20));
+INSERT INTO t1 VALUES (1, 'a;b');
+SET TERM ^ ;
+CREATE PROCEDURE p1 (n INTEGER) AS
+BEGIN
+  INSERT INTO t1 VALUES (:n, q'{it's}');
+END^
+EXECUTE BLOCK AS
+BEGIN
+  EXECUTE PROCEDURE p1 2;
+END^
+SET TERM ; ^
+COMMIT;
+INSERT INTO t1 VALUES (3, 'rolled back');
+ROLLBACK;
+`))
+	require.NoError(t, err)
+
+	var n int
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM t1").Scan(&n))
+	assert.Equal(t, 2, n)
+	var s string
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT s FROM t1 WHERE id = 2").Scan(&s))
+	assert.Equal(t, "it's", s)
+
+	err = ExecScript(ctx, conn, strings.NewReader("INSERT INTO t1 VALUES (4, 'x');\n\n  INSERT INTO t1 VALUES (1, 'duplicate');"))
+	var scriptErr *ScriptError
+	require.True(t, errors.As(err, &scriptErr))
+	assert.Equal(t, 3, scriptErr.Line)
+	assert.Equal(t, 3, scriptErr.Column)
+	var fbErr *FbError
+	assert.True(t, errors.As(err, &fbErr))
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM t1").Scan(&n))
+	assert.Equal(t, 2, n)
+}
@@ -25,11 +25,17 @@ package firebirdsql
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 )
 
+// serviceStreamBufferLength is the response buffer size used when moving
+// data through the service output or input.
+const serviceStreamBufferLength = 32768
+
 type ServiceManager struct {
 	wp     *wireProtocol
 	handle int32
@@ -55,6 +61,15 @@ type SrvDbInfo struct {
 type ServiceManagerOptions struct {
 	WireCrypt  bool
 	AuthPlugin string
+	// WireCryptRequired fails the attachment when it cannot be encrypted,
+	// as wire_crypt=required.
+	WireCryptRequired bool
+	// CryptKeyCallback answers the crypt plugin of an encrypted database
+	// that asks for its key, as Config.CryptKeyCallback.
+	CryptKeyCallback func(serverData []byte) ([]byte, error)
+	// CredentialsProvider, if set, replaces the user and the password
+	// passed to NewServiceManager, as Config.CredentialsProvider.
+	CredentialsProvider func(ctx context.Context) (user string, password string, err error)
 }
 
 type ServiceManagerOption func(*ServiceManagerOptions)
@@ -164,6 +179,14 @@ func WithWireCrypt() ServiceManagerOption {
 func WithoutWireCrypt() ServiceManagerOption {
 	return func(opts *ServiceManagerOptions) {
 		opts.WireCrypt = false
+		opts.WireCryptRequired = false
+	}
+}
+
+func WithWireCryptRequired() ServiceManagerOption {
+	return func(opts *ServiceManagerOptions) {
+		opts.WireCrypt = true
+		opts.WireCryptRequired = true
 	}
 }
 
@@ -173,6 +196,18 @@ func WithAuthPlugin(authPlugin string) ServiceManagerOption {
 	}
 }
 
+func WithCryptKeyCallback(callback func(serverData []byte) ([]byte, error)) ServiceManagerOption {
+	return func(opts *ServiceManagerOptions) {
+		opts.CryptKeyCallback = callback
+	}
+}
+
+func WithCredentialsProvider(provider func(ctx context.Context) (user string, password string, err error)) ServiceManagerOption {
+	return func(opts *ServiceManagerOptions) {
+		opts.CredentialsProvider = provider
+	}
+}
+
 func NewServiceManagerOptions(opts ...ServiceManagerOption) ServiceManagerOptions {
 	res := GetDefaultServiceManagerOptions()
 	for _, opt := range opts {
@@ -183,6 +218,7 @@ func NewServiceManagerOptions(opts ...ServiceManagerOption) ServiceManagerOption
 
 func (sm ServiceManagerOptions) WithoutWireCrypt() ServiceManagerOptions {
 	sm.WireCrypt = false
+	sm.WireCryptRequired = false
 	return sm
 }
 
@@ -191,40 +227,62 @@ func (sm ServiceManagerOptions) WithWireCrypt() ServiceManagerOptions {
 	return sm
 }
 
+func (sm ServiceManagerOptions) WithWireCryptRequired() ServiceManagerOptions {
+	sm.WireCrypt = true
+	sm.WireCryptRequired = true
+	return sm
+}
+
 func (sm ServiceManagerOptions) WithAuthPlugin(authPlugin string) ServiceManagerOptions {
 	sm.AuthPlugin = authPlugin
 	return sm
 }
 
+func (sm ServiceManagerOptions) WithCryptKeyCallback(callback func(serverData []byte) ([]byte, error)) ServiceManagerOptions {
+	sm.CryptKeyCallback = callback
+	return sm
+}
+
+func (sm ServiceManagerOptions) WithCredentialsProvider(provider func(ctx context.Context) (user string, password string, err error)) ServiceManagerOptions {
+	sm.CredentialsProvider = provider
+	return sm
+}
+
 func NewServiceManager(addr string, user string, password string, options ServiceManagerOptions) (*ServiceManager, error) {
 	var err error
 	var wp *wireProtocol
 	if !strings.ContainsRune(addr, ':') {
 		addr += ":3050"
 	}
+	if options.CredentialsProvider != nil {
+		if user, password, err = options.CredentialsProvider(context.Background()); err != nil {
+			return nil, fmt.Errorf("credentials provider: %w", err)
+		}
+	}
 	if wp, err = newWireProtocol(addr, "", ""); err != nil {
 		return nil, err
 	}
+	wp.cryptKeyCallback = options.CryptKeyCallback
 
 	wireCryptStr := "false"
-	if options.WireCrypt {
+	if options.WireCryptRequired {
+		wireCryptStr = "required"
+	} else if options.WireCrypt {
 		wireCryptStr = "true"
 	}
 
 	var connOptions = map[string]string{
-		"auth_plugin_name": options.AuthPlugin,
-		"wire_crypt":       wireCryptStr,
+		"auth_plugin_name":   options.AuthPlugin,
+		"auth_plugin_list":   defaultDsnOptions["auth_plugin_list"],
+		"wire_crypt":         wireCryptStr,
+		"wire_crypt_plugins": defaultDsnOptions["wire_crypt_plugins"],
 	}
 
-	clientPublic, clientSecret, err := getClientSeed()
-	if err != nil {
-		return nil, err
-	}
-	if err = wp.opConnect("", user, password, connOptions, clientPublic); err != nil {
+	if err = wp.opConnect("", user, password, connOptions); err != nil {
 		return nil, err
 	}
 
-	if err = wp._parse_connect_response(user, password, connOptions, clientPublic, clientSecret); err != nil {
+	if err = wp._parse_connect_response(user, password, connOptions); err != nil {
 		return nil, err
 	}
 
@@ -242,6 +300,12 @@ func NewServiceManager(addr string, user string, password string, options Servic
 	return manager, nil
 }
 
+// ConnectionInfo returns what the service attachment negotiated with the
+// server.
+func (svc *ServiceManager) ConnectionInfo() ConnInfo {
+	return svc.wp.connInfo()
+}
+
 func (svc *ServiceManager) Close() (err error) {
 	if err = svc.wp.opServiceDetach(); err != nil {
 		svc.wp.conn.Close()
@@ -341,6 +405,108 @@ func (svc *ServiceManager) WaitBuffer(stream chan []byte) error {
 	return nil
 }
 
+// readStdout copies the output of a service started with "stdout" as its
+// target file to w until the service ends.
+func (svc *ServiceManager) readStdout(ctx context.Context, w io.Writer, progress func(transferred int64)) error {
+	var transferred int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		spb := NewXPBWriterFromBytes(GetServiceInfoSPBPreamble())
+		spb.PutByte(isc_info_svc_timeout, 1)
+		buf, err := svc.GetServiceInfo(spb.Bytes(), []byte{isc_info_svc_to_eof}, serviceStreamBufferLength)
+		if err != nil {
+			return err
+		}
+		if len(buf) < 4 {
+			return fmt.Errorf("unexpected end of stream")
+		}
+		dataLen := int(uint16(bytes_to_int16(buf[1:3])))
+		if 3+dataLen >= len(buf) {
+			return fmt.Errorf("unexpected end of stream")
+		}
+		if dataLen > 0 {
+			if _, err = w.Write(buf[3 : 3+dataLen]); err != nil {
+				return err
+			}
+			transferred += int64(dataLen)
+			if progress != nil {
+				progress(transferred)
+			}
+		} else if buf[3] == isc_info_end {
+			return nil
+		}
+	}
+}
+
+// writeStdin feeds r to a service started with "stdin" as its source file
+// until the service ends.
+func (svc *ServiceManager) writeStdin(ctx context.Context, r io.Reader, progress func(transferred int64)) error {
+	var (
+		transferred int64
+		requested   int
+		eof         bool
+		chunk       = make([]byte, 0xffff)
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		spb := NewXPBWriterFromBytes(GetServiceInfoSPBPreamble())
+		if requested > 0 {
+			n := 0
+			if !eof {
+				var err error
+				n, err = io.ReadFull(r, chunk[:min(requested, len(chunk))])
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					eof = true
+				} else if err != nil {
+					return err
+				}
+			}
+			spb.PutInt16(isc_info_svc_line, int16(n))
+			spb.PutBytes(chunk[:n])
+			transferred += int64(n)
+			if n > 0 && progress != nil {
+				progress(transferred)
+			}
+		}
+
+		buf, err := svc.GetServiceInfo(spb.Bytes(), []byte{isc_info_svc_stdin, isc_info_svc_line}, serviceStreamBufferLength)
+		if err != nil {
+			return err
+		}
+		requested = 0
+		notReady, output := false, false
+		for pos := 0; pos < len(buf) && buf[pos] != isc_info_end; {
+			switch buf[pos] {
+			case isc_info_svc_stdin:
+				if pos+5 > len(buf) {
+					return fmt.Errorf("unexpected end of stream")
+				}
+				requested = int(bytes_to_int32(buf[pos+1 : pos+5]))
+				pos += 5
+			case isc_info_svc_line:
+				if pos+3 > len(buf) {
+					return fmt.Errorf("unexpected end of stream")
+				}
+				lineLen := int(uint16(bytes_to_int16(buf[pos+1 : pos+3])))
+				output = output || lineLen > 0
+				pos += 3 + lineLen
+			case isc_info_data_not_ready, isc_info_svc_timeout:
+				notReady = true
+				pos++
+			default:
+				return fmt.Errorf("wrong item '%d' response buffer", buf[pos])
+			}
+		}
+		if !notReady && !output && requested == 0 {
+			return nil
+		}
+	}
+}
+
 func (svc *ServiceManager) WaitStrings(result chan string) error {
 	var (
 		err  error
@@ -566,6 +732,28 @@ func (svc *ServiceManager) GetDbStats(database string, options StatisticsOptions
 	return svc.WaitStrings(result)
 }
 
+// GetDbStatsContext is like GetDbStats, but aborts when ctx is done and
+// sends the lines of the report to progress if it is not nil. The
+// connection of svc is closed on cancellation and svc can't be used anymore.
+func (svc *ServiceManager) GetDbStatsContext(ctx context.Context, database string, options StatisticsOptions, progress chan ServiceProgress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := svc.watchContext(ctx)
+	err := svc.doGetDbStats(database, options)
+	if err == nil {
+		if progress != nil {
+			err = svc.WaitProgress(ctx, progress)
+		} else {
+			err = svc.Wait()
+		}
+	}
+	if ctxErr := stop(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
 func (svc *ServiceManager) GetDbStatsString(database string, options StatisticsOptions) (string, error) {
 	if err := svc.doGetDbStats(database, options); err != nil {
 		return "", err
@@ -0,0 +1,170 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceProgress describes a verbose line reported by a running service.
+type ServiceProgress struct {
+	Phase   string        // current step, e.g. "writing tables" or "restoring data"
+	Table   string        // table whose data is being copied, if any
+	Records int64         // records of Table copied so far
+	Elapsed time.Duration // time since the service was started
+	Message string        // line as sent by the server
+}
+
+var (
+	progressTablePattern   = regexp.MustCompile(`^(writing|restoring) data for table (.+)$`)
+	progressRecordsPattern = regexp.MustCompile(`^(\d+) records? (?:written|restored)`)
+)
+
+type serviceProgressParser struct {
+	start   time.Time
+	phase   string
+	table   string
+	records int64
+}
+
+func newServiceProgressParser() *serviceProgressParser {
+	return &serviceProgressParser{start: time.Now()}
+}
+
+// parse folds a gbak verbose line or a gstat line into the current
+// progress.
+func (p *serviceProgressParser) parse(line string) ServiceProgress {
+	msg := strings.TrimPrefix(line, "gbak:")
+	text := strings.TrimSpace(msg)
+
+	if m := progressTablePattern.FindStringSubmatch(text); m != nil {
+		p.phase = m[1] + " data"
+		p.table = m[2]
+		p.records = 0
+	} else if m := progressRecordsPattern.FindStringSubmatch(text); m != nil {
+		p.records, _ = strconv.ParseInt(m[1], 10, 64)
+	} else if text != "" && !strings.HasPrefix(msg, " ") {
+		p.phase = text
+		p.table = ""
+		p.records = 0
+	}
+
+	return ServiceProgress{
+		Phase:   p.phase,
+		Table:   p.table,
+		Records: p.records,
+		Elapsed: time.Since(p.start),
+		Message: line,
+	}
+}
+
+// WaitProgress waits for the running service to finish, sending its verbose
+// output to progress.
+func (svc *ServiceManager) WaitProgress(ctx context.Context, progress chan ServiceProgress) error {
+	return svc.waitLines(sendProgress(ctx, progress))
+}
+
+// sendProgress returns a line handler that parses lines into progress events.
+func sendProgress(ctx context.Context, progress chan ServiceProgress) func(line string) error {
+	parser := newServiceProgressParser()
+	return func(line string) error {
+		select {
+		case progress <- parser.parse(line):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitLines calls fn for every output line of the running service until
+// the service ends or fn fails.
+func (svc *ServiceManager) waitLines(fn func(line string) error) error {
+	for {
+		line, end, err := svc.GetString()
+		if err != nil {
+			return err
+		}
+		if end {
+			return nil
+		}
+		if err = fn(line); err != nil {
+			return err
+		}
+	}
+}
+
+// watchContext closes the connection of svc when ctx is done. Closing the
+// connection detaches the service, which makes the server abort the running
+// utility. The returned function must be called once the operation ends; it
+// reports ctx.Err() if the connection was closed.
+func (svc *ServiceManager) watchContext(ctx context.Context) func() error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = svc.wp.conn.Close()
+	})
+	return func() error {
+		if !stop() {
+			return ctx.Err()
+		}
+		return nil
+	}
+}
+
+func serviceAttachContext(ctx context.Context, connBuilder func() (*ServiceManager, error), spb []byte, progress chan ServiceProgress) error {
+	if progress == nil {
+		return serviceRunContext(ctx, connBuilder, spb, nil)
+	}
+	return serviceRunContext(ctx, connBuilder, spb, sendProgress(ctx, progress))
+}
+
+// serviceRunContext starts a service on a new connection and waits for it to
+// finish, passing its output lines to onLine if it is not nil.
+func serviceRunContext(ctx context.Context, connBuilder func() (*ServiceManager, error), spb []byte, onLine func(line string) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn, err := connBuilder()
+	if err != nil {
+		return err
+	}
+	stop := conn.watchContext(ctx)
+	if err = conn.ServiceStart(spb); err == nil {
+		if onLine != nil {
+			err = conn.waitLines(onLine)
+		} else {
+			err = conn.Wait()
+		}
+	}
+	if ctxErr := stop(); ctxErr != nil {
+		return ctxErr
+	}
+	if closeErr := conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
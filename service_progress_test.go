@@ -0,0 +1,108 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceProgressParser(t *testing.T) {
+	p := newServiceProgressParser()
+	lines := []struct {
+		line    string
+		phase   string
+		table   string
+		records int64
+	}{
+		{"gbak:readied database employee for backup", "readied database employee for backup", "", 0},
+		{"gbak:writing domains", "writing domains", "", 0},
+		{"gbak:    writing domain RDB$1", "writing domains", "", 0},
+		{"gbak:writing data for table COUNTRY", "writing data", "COUNTRY", 0},
+		{"gbak:   14 records written", "writing data", "COUNTRY", 14},
+		{"gbak:    restoring data for table EMPLOYEE", "restoring data", "EMPLOYEE", 0},
+		{"gbak:    42 records restored", "restoring data", "EMPLOYEE", 42},
+		{"gbak:1 record restored", "restoring data", "EMPLOYEE", 1},
+		{"gbak:restoring index NAMEX", "restoring index NAMEX", "", 0},
+		{"gbak:finishing, closing, and going home", "finishing, closing, and going home", "", 0},
+		// gstat
+		{"COUNTRY (128)", "COUNTRY (128)", "", 0},
+		{"    Primary pointer page: 180, Index root page: 181", "COUNTRY (128)", "", 0},
+	}
+	for _, l := range lines {
+		ev := p.parse(l.line)
+		assert.Equal(t, l.phase, ev.Phase, l.line)
+		assert.Equal(t, l.table, ev.Table, l.line)
+		assert.Equal(t, l.records, ev.Records, l.line)
+		assert.Equal(t, l.line, ev.Message)
+		assert.GreaterOrEqual(t, ev.Elapsed, time.Duration(0))
+	}
+}
+
+func TestBackupManagerContext(t *testing.T) {
+	dbPath := GetTestDatabase("test_backup_context_")
+	dbBackup := GetTestBackup("test_backup_context_")
+	conn, err := sql.Open("firebirdsql_createdb", GetTestDSNFromDatabase(dbPath))
+	require.NoError(t, err, "sql.Open")
+	_, err = conn.Exec("create table test(a int)")
+	require.NoError(t, err, "Exec")
+	_, err = conn.Exec("insert into test values(123)")
+	require.NoError(t, err, "Exec")
+	conn.Close()
+
+	bm, err := NewBackupManager("localhost", GetTestUser(), GetTestPassword(), GetDefaultServiceManagerOptions())
+	require.NoError(t, err, "NewBackupManager")
+
+	progress := make(chan ServiceProgress)
+	var events []ServiceProgress
+	done := make(chan struct{})
+	go func() {
+		for ev := range progress {
+			events = append(events, ev)
+		}
+		close(done)
+	}()
+	err = bm.BackupContext(context.Background(), dbPath, dbBackup, GetDefaultBackupOptions(), progress)
+	close(progress)
+	<-done
+	require.NoError(t, err, "BackupContext")
+	require.NotEmpty(t, events)
+	found := false
+	for _, ev := range events {
+		if ev.Table == "TEST" && ev.Records == 1 {
+			found = true
+		}
+	}
+	assert.True(t, found, "records of TEST reported")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = bm.RestoreContext(ctx, dbBackup, GetTestDatabase("test_backup_context_rest_"), GetDefaultRestoreOptions(), nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,464 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SQLUserManager manages users, roles and privileges with SQL statements
+// over a normal connection. Unlike UserManager it can manage the users of
+// each authentication plugin, user tags and the active flag (Firebird 3.0+).
+type SQLUserManager struct {
+	db *sql.DB
+}
+
+// Privilege is a row of RDB$USER_PRIVILEGES.
+type Privilege struct {
+	Privilege   string // SELECT, INSERT, UPDATE, DELETE, REFERENCES, EXECUTE, USAGE or MEMBER (role membership)
+	ObjectType  string // TABLE, VIEW, PROCEDURE, FUNCTION, PACKAGE, GENERATOR, EXCEPTION, ROLE, ...
+	ObjectName  string
+	Column      string // UPDATE and REFERENCES only
+	Grantee     string
+	GranteeType string // USER (default), ROLE, PROCEDURE, FUNCTION, PACKAGE, TRIGGER or VIEW
+	GrantOption bool   // WITH GRANT OPTION, or WITH ADMIN OPTION for MEMBER
+	Grantor     string
+}
+
+var privilegeNames = map[string]string{
+	"S": "SELECT",
+	"I": "INSERT",
+	"U": "UPDATE",
+	"D": "DELETE",
+	"R": "REFERENCES",
+	"X": "EXECUTE",
+	"G": "USAGE",
+	"M": "MEMBER",
+	"C": "CREATE",
+	"L": "ALTER",
+	"O": "DROP",
+}
+
+var objectTypeNames = map[int]string{
+	0:  "TABLE",
+	1:  "VIEW",
+	2:  "TRIGGER",
+	5:  "PROCEDURE",
+	7:  "EXCEPTION",
+	8:  "USER",
+	9:  "DOMAIN",
+	11: "CHARACTER SET",
+	13: "ROLE",
+	14: "GENERATOR",
+	15: "FUNCTION",
+	17: "COLLATION",
+	18: "PACKAGE",
+}
+
+// PrivilegeName returns the name of an RDB$PRIVILEGE code, e.g. "SELECT"
+// for "S". Unknown codes are returned unchanged.
+func PrivilegeName(code string) string {
+	code = strings.TrimSpace(code)
+	if name, ok := privilegeNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// ObjectTypeName returns the name of an RDB$OBJECT_TYPE or RDB$USER_TYPE
+// value, e.g. "TABLE" for 0, or "" if it is unknown.
+func ObjectTypeName(objectType int) string {
+	return objectTypeNames[objectType]
+}
+
+var regularIdentifierPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$]*$`)
+
+var errEmptyUsername = errors.New("username is required")
+
+func NewSQLUserManager(db *sql.DB) *SQLUserManager {
+	return &SQLUserManager{db: db}
+}
+
+// identifierName returns the name the server stores for name. Regular
+// identifiers are upper-cased the way the server does for unquoted names,
+// so "alice" and "ALICE" refer to the same user; a name in double quotes,
+// e.g. `"Alice"`, is taken as is.
+func identifierName(name string) string {
+	if unquoted, ok := unquoteUser(name); ok && delimitIdentifier(unquoted) == name {
+		return unquoted
+	}
+	if regularIdentifierPattern.MatchString(name) {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+// quoteIdentifier returns name as it is written in DDL, see identifierName.
+func quoteIdentifier(name string) string {
+	return QuoteIdentifier(identifierName(name))
+}
+
+// userClauses renders the options shared by CREATE USER and ALTER USER.
+func userClauses(user *User, alter bool) ([]string, error) {
+	var clauses []string
+	if user.Password != nil {
+		clauses = append(clauses, "PASSWORD "+QuoteString(*user.Password))
+	}
+	if user.FirstName != nil {
+		clauses = append(clauses, "FIRSTNAME "+QuoteString(*user.FirstName))
+	}
+	if user.MiddleName != nil {
+		clauses = append(clauses, "MIDDLENAME "+QuoteString(*user.MiddleName))
+	}
+	if user.LastName != nil {
+		clauses = append(clauses, "LASTNAME "+QuoteString(*user.LastName))
+	}
+	if user.Active != nil {
+		if *user.Active {
+			clauses = append(clauses, "ACTIVE")
+		} else {
+			clauses = append(clauses, "INACTIVE")
+		}
+	}
+	if user.Plugin != nil {
+		if !regularIdentifierPattern.MatchString(*user.Plugin) {
+			return nil, fmt.Errorf("invalid plugin name %q", *user.Plugin)
+		}
+		clauses = append(clauses, "USING PLUGIN "+*user.Plugin)
+	}
+	if len(user.Tags) > 0 {
+		keys := make([]string, 0, len(user.Tags))
+		for key := range user.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		tags := make([]string, 0, len(keys))
+		for _, key := range keys {
+			// tag names are plain symbols, the server does not accept delimited names
+			if !regularIdentifierPattern.MatchString(key) {
+				return nil, fmt.Errorf("invalid tag name %q", key)
+			}
+			if alter && user.Tags[key] == "" {
+				tags = append(tags, "DROP "+key)
+			} else {
+				tags = append(tags, key+" = "+QuoteString(user.Tags[key]))
+			}
+		}
+		clauses = append(clauses, "TAGS ("+strings.Join(tags, ", ")+")")
+	}
+	if user.Admin != nil {
+		if *user.Admin {
+			clauses = append(clauses, "GRANT ADMIN ROLE")
+		} else if alter {
+			clauses = append(clauses, "REVOKE ADMIN ROLE")
+		}
+	}
+	return clauses, nil
+}
+
+func createUserStatement(user *User) (string, error) {
+	if user.Username == nil || *user.Username == "" {
+		return "", errEmptyUsername
+	}
+	if user.Password == nil {
+		return "", errors.New("password is required")
+	}
+	clauses, err := userClauses(user, false)
+	if err != nil {
+		return "", err
+	}
+	return "CREATE USER " + quoteIdentifier(*user.Username) + " " + strings.Join(clauses, " "), nil
+}
+
+func alterUserStatement(user *User) (string, error) {
+	if user.Username == nil || *user.Username == "" {
+		return "", errEmptyUsername
+	}
+	clauses, err := userClauses(user, true)
+	if err != nil {
+		return "", err
+	}
+	if len(clauses) == 0 {
+		return "", errors.New("nothing to modify")
+	}
+	return "ALTER USER " + quoteIdentifier(*user.Username) + " " + strings.Join(clauses, " "), nil
+}
+
+func dropUserStatement(user *User) (string, error) {
+	if user.Username == nil || *user.Username == "" {
+		return "", errEmptyUsername
+	}
+	stmt := "DROP USER " + quoteIdentifier(*user.Username)
+	if user.Plugin != nil {
+		if !regularIdentifierPattern.MatchString(*user.Plugin) {
+			return "", fmt.Errorf("invalid plugin name %q", *user.Plugin)
+		}
+		stmt += " USING PLUGIN " + *user.Plugin
+	}
+	return stmt, nil
+}
+
+// AddUser runs CREATE USER. Username and Password are required.
+func (um *SQLUserManager) AddUser(ctx context.Context, user User) error {
+	stmt, err := createUserStatement(&user)
+	if err != nil {
+		return err
+	}
+	_, err = um.db.ExecContext(ctx, stmt)
+	return err
+}
+
+// ModifyUser runs ALTER USER with the fields of user that are set. A tag
+// with an empty value is dropped, and WithoutAdmin revokes the admin role.
+func (um *SQLUserManager) ModifyUser(ctx context.Context, user User) error {
+	stmt, err := alterUserStatement(&user)
+	if err != nil {
+		return err
+	}
+	_, err = um.db.ExecContext(ctx, stmt)
+	return err
+}
+
+// DeleteUser runs DROP USER, for the plugin of user if it is set.
+func (um *SQLUserManager) DeleteUser(ctx context.Context, user User) error {
+	stmt, err := dropUserStatement(&user)
+	if err != nil {
+		return err
+	}
+	_, err = um.db.ExecContext(ctx, stmt)
+	return err
+}
+
+// GetUsers lists the users of all plugins from SEC$USERS and
+// SEC$USER_ATTRIBUTES. UserId and GroupId are not available and left at -1.
+func (um *SQLUserManager) GetUsers(ctx context.Context) ([]User, error) {
+	rows, err := um.db.QueryContext(ctx, `
+		SELECT SEC$USER_NAME, SEC$FIRST_NAME, SEC$MIDDLE_NAME, SEC$LAST_NAME,
+			SEC$ACTIVE, SEC$ADMIN, SEC$PLUGIN
+		FROM SEC$USERS
+		ORDER BY SEC$USER_NAME, SEC$PLUGIN`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	index := make(map[string]int)
+	for rows.Next() {
+		var (
+			name, plugin        string
+			first, middle, last sql.NullString
+			active, admin       sql.NullBool
+		)
+		if err = rows.Scan(&name, &first, &middle, &last, &active, &admin, &plugin); err != nil {
+			return nil, err
+		}
+		user := NewUser(WithUsername(strings.TrimSpace(name)), WithPlugin(strings.TrimSpace(plugin)))
+		if first.Valid {
+			user.FirstName = &first.String
+		}
+		if middle.Valid {
+			user.MiddleName = &middle.String
+		}
+		if last.Valid {
+			user.LastName = &last.String
+		}
+		user.Active = &active.Bool
+		user.Admin = &admin.Bool
+		index[*user.Username+"\x00"+*user.Plugin] = len(users)
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := um.db.QueryContext(ctx, `SELECT SEC$USER_NAME, SEC$KEY, SEC$VALUE, SEC$PLUGIN FROM SEC$USER_ATTRIBUTES`)
+	if err != nil {
+		return nil, err
+	}
+	defer tags.Close()
+	for tags.Next() {
+		var (
+			name, key, plugin string
+			value             sql.NullString
+		)
+		if err = tags.Scan(&name, &key, &value, &plugin); err != nil {
+			return nil, err
+		}
+		i, ok := index[strings.TrimSpace(name)+"\x00"+strings.TrimSpace(plugin)]
+		if !ok {
+			continue
+		}
+		if users[i].Tags == nil {
+			users[i].Tags = make(map[string]string)
+		}
+		users[i].Tags[strings.TrimSpace(key)] = value.String
+	}
+	return users, tags.Err()
+}
+
+func (um *SQLUserManager) CreateRole(ctx context.Context, role string) error {
+	_, err := um.db.ExecContext(ctx, "CREATE ROLE "+quoteIdentifier(role))
+	return err
+}
+
+func (um *SQLUserManager) DropRole(ctx context.Context, role string) error {
+	_, err := um.db.ExecContext(ctx, "DROP ROLE "+quoteIdentifier(role))
+	return err
+}
+
+// GrantRole grants role to user, WITH ADMIN OPTION if admin is true.
+func (um *SQLUserManager) GrantRole(ctx context.Context, role string, user string, admin bool) error {
+	return um.GrantPrivilege(ctx, Privilege{
+		Privilege:   "MEMBER",
+		ObjectType:  "ROLE",
+		ObjectName:  role,
+		Grantee:     user,
+		GrantOption: admin,
+	})
+}
+
+func (um *SQLUserManager) RevokeRole(ctx context.Context, role string, user string) error {
+	return um.RevokePrivilege(ctx, Privilege{
+		Privilege:  "MEMBER",
+		ObjectType: "ROLE",
+		ObjectName: role,
+		Grantee:    user,
+	})
+}
+
+func privilegeStatement(p *Privilege, revoke bool) (string, error) {
+	if p.ObjectName == "" || p.Grantee == "" {
+		return "", errors.New("object name and grantee are required")
+	}
+	granteeType := strings.ToUpper(p.GranteeType)
+	if granteeType == "" {
+		granteeType = "USER"
+	}
+	grantee := granteeType + " " + quoteIdentifier(p.Grantee)
+
+	var what string
+	privilege := strings.ToUpper(p.Privilege)
+	switch privilege {
+	case "":
+		return "", errors.New("privilege is required")
+	case "MEMBER":
+		what = quoteIdentifier(p.ObjectName)
+	default:
+		what = privilege
+		if p.Column != "" {
+			what += " (" + quoteIdentifier(p.Column) + ")"
+		}
+		objectType := strings.ToUpper(p.ObjectType)
+		if objectType == "" || objectType == "VIEW" {
+			objectType = "TABLE"
+		}
+		what += " ON " + objectType + " " + quoteIdentifier(p.ObjectName)
+	}
+
+	if revoke {
+		return "REVOKE " + what + " FROM " + grantee, nil
+	}
+	stmt := "GRANT " + what + " TO " + grantee
+	if p.GrantOption {
+		if privilege == "MEMBER" {
+			stmt += " WITH ADMIN OPTION"
+		} else {
+			stmt += " WITH GRANT OPTION"
+		}
+	}
+	return stmt, nil
+}
+
+// GrantPrivilege runs GRANT for p. Names are quoted with quoteIdentifier.
+func (um *SQLUserManager) GrantPrivilege(ctx context.Context, p Privilege) error {
+	stmt, err := privilegeStatement(&p, false)
+	if err != nil {
+		return err
+	}
+	_, err = um.db.ExecContext(ctx, stmt)
+	return err
+}
+
+// RevokePrivilege runs REVOKE for p; GrantOption is ignored.
+func (um *SQLUserManager) RevokePrivilege(ctx context.Context, p Privilege) error {
+	stmt, err := privilegeStatement(&p, true)
+	if err != nil {
+		return err
+	}
+	_, err = um.db.ExecContext(ctx, stmt)
+	return err
+}
+
+// GetPrivileges lists RDB$USER_PRIVILEGES of the database, for grantee only
+// if it is not empty.
+func (um *SQLUserManager) GetPrivileges(ctx context.Context, grantee string) ([]Privilege, error) {
+	query := `
+		SELECT RDB$USER, RDB$GRANTOR, RDB$PRIVILEGE, RDB$GRANT_OPTION,
+			RDB$RELATION_NAME, RDB$FIELD_NAME, RDB$USER_TYPE, RDB$OBJECT_TYPE
+		FROM RDB$USER_PRIVILEGES`
+	var args []any
+	if grantee != "" {
+		query += " WHERE RDB$USER = ?"
+		args = append(args, identifierName(grantee))
+	}
+	query += " ORDER BY RDB$USER, RDB$RELATION_NAME, RDB$PRIVILEGE"
+
+	rows, err := um.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var privileges []Privilege
+	for rows.Next() {
+		var (
+			user, privilege          string
+			grantor, relation, field sql.NullString
+			grantOption              sql.NullInt64
+			userType, objectType     int
+		)
+		if err = rows.Scan(&user, &grantor, &privilege, &grantOption, &relation, &field, &userType, &objectType); err != nil {
+			return nil, err
+		}
+		p := Privilege{
+			Privilege:   PrivilegeName(privilege),
+			ObjectType:  ObjectTypeName(objectType),
+			ObjectName:  strings.TrimSpace(relation.String),
+			Column:      strings.TrimSpace(field.String),
+			Grantee:     strings.TrimSpace(user),
+			GranteeType: ObjectTypeName(userType),
+			GrantOption: grantOption.Int64 != 0,
+			Grantor:     strings.TrimSpace(grantor.String),
+		}
+		privileges = append(privileges, p)
+	}
+	return privileges, rows.Err()
+}
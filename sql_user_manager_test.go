@@ -0,0 +1,141 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLUserStatements(t *testing.T) {
+	user := NewUser(WithUsername("alice"), WithPassword("it's"), WithFirstName("Alice"), WithInactive(),
+		WithPlugin("Srp"), WithTag("dept", "ops"), WithTag("floor", "3"), WithAdmin())
+	stmt, err := createUserStatement(&user)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE USER ALICE PASSWORD 'it''s' FIRSTNAME 'Alice' INACTIVE USING PLUGIN Srp TAGS (dept = 'ops', floor = '3') GRANT ADMIN ROLE`, stmt)
+
+	user = NewUser(WithUsername("Mixed Case"), WithActive(), WithTag("dept", ""), WithoutAdmin())
+	stmt, err = alterUserStatement(&user)
+	require.NoError(t, err)
+	assert.Equal(t, `ALTER USER "Mixed Case" ACTIVE TAGS (DROP dept) REVOKE ADMIN ROLE`, stmt)
+
+	user = NewUser(WithUsername("bob"), WithPlugin("Legacy_UserManager"))
+	stmt, err = dropUserStatement(&user)
+	require.NoError(t, err)
+	assert.Equal(t, `DROP USER BOB USING PLUGIN Legacy_UserManager`, stmt)
+
+	user = NewUser(WithUsername(`"Alice"`))
+	stmt, err = dropUserStatement(&user)
+	require.NoError(t, err)
+	assert.Equal(t, `DROP USER "Alice"`, stmt)
+
+	user = NewUser(WithUsername("bob"))
+	_, err = createUserStatement(&user)
+	assert.Error(t, err, "password is required")
+	_, err = alterUserStatement(&user)
+	assert.Error(t, err, "nothing to modify")
+	user = NewUser(WithUsername("bob"), WithPassword("x"), WithTag("bad key", "v"))
+	_, err = createUserStatement(&user)
+	assert.Error(t, err)
+}
+
+func TestPrivilegeStatement(t *testing.T) {
+	stmt, err := privilegeStatement(&Privilege{Privilege: "select", ObjectName: "orders", Grantee: "alice", GrantOption: true}, false)
+	require.NoError(t, err)
+	assert.Equal(t, `GRANT SELECT ON TABLE ORDERS TO USER ALICE WITH GRANT OPTION`, stmt)
+
+	stmt, err = privilegeStatement(&Privilege{Privilege: "UPDATE", Column: "status", ObjectName: "orders", Grantee: "clerk", GranteeType: "ROLE"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, `REVOKE UPDATE (STATUS) ON TABLE ORDERS FROM ROLE CLERK`, stmt)
+
+	stmt, err = privilegeStatement(&Privilege{Privilege: "EXECUTE", ObjectType: "PROCEDURE", ObjectName: "ship", Grantee: "alice"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, `GRANT EXECUTE ON PROCEDURE SHIP TO USER ALICE`, stmt)
+
+	stmt, err = privilegeStatement(&Privilege{Privilege: "MEMBER", ObjectType: "ROLE", ObjectName: "clerk", Grantee: "alice", GrantOption: true}, false)
+	require.NoError(t, err)
+	assert.Equal(t, `GRANT CLERK TO USER ALICE WITH ADMIN OPTION`, stmt)
+
+	stmt, err = privilegeStatement(&Privilege{Privilege: "SELECT", ObjectName: `"Orders"`, Grantee: `"Say ""hi"""`}, false)
+	require.NoError(t, err)
+	assert.Equal(t, `GRANT SELECT ON TABLE "Orders" TO USER "Say ""hi"""`, stmt)
+
+	stmt, err = privilegeStatement(&Privilege{Privilege: "SELECT", ObjectName: "order", Grantee: "alice"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, `GRANT SELECT ON TABLE "ORDER" TO USER ALICE`, stmt)
+
+	_, err = privilegeStatement(&Privilege{ObjectName: "orders", Grantee: "alice"}, false)
+	assert.Error(t, err)
+}
+
+func TestSQLUserManager(t *testing.T) {
+	if get_firebird_major_version(t) < 3 {
+		t.Skip("SEC$USERS requires Firebird 3.0+")
+	}
+	conn, err := sql.Open("firebirdsql_createdb", GetTestDSN("test_sql_user_manager_"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	um := NewSQLUserManager(conn)
+	err = um.AddUser(ctx, NewUser(WithUsername("sqltest"), WithPassword("sqltest"), WithLastName("last"), WithTag("dept", "ops")))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, um.DeleteUser(ctx, NewUser(WithUsername("sqltest"))))
+	}()
+	require.NoError(t, um.ModifyUser(ctx, NewUser(WithUsername("sqltest"), WithInactive())))
+
+	users, err := um.GetUsers(ctx)
+	require.NoError(t, err)
+	found := false
+	for _, user := range users {
+		if *user.Username == "SQLTEST" {
+			found = true
+			assert.Equal(t, "last", *user.LastName)
+			assert.False(t, *user.Active)
+			assert.Equal(t, map[string]string{"DEPT": "ops"}, user.Tags)
+		}
+	}
+	assert.True(t, found, "user listed")
+
+	_, err = conn.Exec("CREATE TABLE sql_user_test (id INTEGER)")
+	require.NoError(t, err)
+	require.NoError(t, um.CreateRole(ctx, "sqltest_role"))
+	require.NoError(t, um.GrantRole(ctx, "sqltest_role", "sqltest", false))
+	require.NoError(t, um.GrantPrivilege(ctx, Privilege{Privilege: "SELECT", ObjectName: "sql_user_test", Grantee: "sqltest"}))
+
+	privileges, err := um.GetPrivileges(ctx, "sqltest")
+	require.NoError(t, err)
+	assert.Contains(t, privileges, Privilege{Privilege: "MEMBER", ObjectType: "ROLE", ObjectName: "SQLTEST_ROLE", Grantee: "SQLTEST", GranteeType: "USER", Grantor: strings.ToUpper(GetTestUser())})
+	assert.Contains(t, privileges, Privilege{Privilege: "SELECT", ObjectType: "TABLE", ObjectName: "SQL_USER_TEST", Grantee: "SQLTEST", GranteeType: "USER", Grantor: strings.ToUpper(GetTestUser())})
+
+	require.NoError(t, um.RevokePrivilege(ctx, Privilege{Privilege: "SELECT", ObjectName: "sql_user_test", Grantee: "sqltest"}))
+	require.NoError(t, um.RevokeRole(ctx, "sqltest_role", "sqltest"))
+	require.NoError(t, um.DropRole(ctx, "sqltest_role"))
+}
@@ -28,6 +28,7 @@ import (
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"hash"
 	"math/big"
 
@@ -41,6 +42,16 @@ const (
 	DEBUG_SRP         = false
 )
 
+// srpProofHashes are the hashes of the client proof of each Srp plugin.
+// The session key is a SHA-1 hash whatever the plugin.
+var srpProofHashes = map[string]func() hash.Hash{
+	"Srp":    sha1.New,
+	"Srp224": sha256.New224,
+	"Srp256": sha256.New,
+	"Srp384": sha512.New384,
+	"Srp512": sha512.New,
+}
+
 func pad(v *big.Int) []byte {
 	buf := make([]byte, SRP_KEY_SIZE)
 	var m big.Int
@@ -201,14 +212,11 @@ func getClientProof(user string, password string, salt []byte, keyA *big.Int, ke
 	n3 := mathutil.ModPowBigInt(n1, n2, prime)
 	n4 := getStringHash(user)
 
-	var digest hash.Hash
-	if pluginName == "Srp" {
-		digest = sha1.New()
-	} else if pluginName == "Srp256" {
-		digest = sha256.New()
-	} else {
+	newHash, ok := srpProofHashes[pluginName]
+	if !ok {
 		panic("srp protocol error")
 	}
+	digest := newHash()
 	digest.Write(n3.Bytes())
 	digest.Write(n4.Bytes())
 	digest.Write(salt)
@@ -24,6 +24,13 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"math/big"
 	"testing"
 )
 
@@ -59,3 +66,96 @@ func TestSrp(t *testing.T) {
 		}
 	}
 }
+
+// srpServerProof is the proof the server expects from the client, checked
+// with its own session key: H(H(N)^H(g) mod N, H(I), s, A, B, K). It is
+// computed with the standard library only, apart from the parameters.
+func srpServerProof(newHash func() hash.Hash, user string, salt []byte, keyA *big.Int, keyB *big.Int, serverKey []byte) []byte {
+	prime, g, _ := getPrime()
+	hN := sha1.Sum(prime.Bytes())
+	hg := sha1.Sum(g.Bytes())
+	hI := sha1.Sum([]byte(user))
+	n := new(big.Int).Exp(new(big.Int).SetBytes(hN[:]), new(big.Int).SetBytes(hg[:]), prime)
+	digest := newHash()
+	digest.Write(n.Bytes())
+	digest.Write(new(big.Int).SetBytes(hI[:]).Bytes())
+	digest.Write(salt)
+	digest.Write(keyA.Bytes())
+	digest.Write(keyB.Bytes())
+	digest.Write(serverKey)
+	return digest.Sum(nil)
+}
+
+func TestSrpVectors(t *testing.T) {
+	// fixed client and server secrets
+	keya := bigIntFromString(DEBUG_PRIVATE_KEY)
+	keyb, _ := new(big.Int).SetString("0123456789abcdef0123456789abcdef", 16)
+	salt := make([]byte, SRP_SALT_SIZE)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+	prime, g, k := getPrime()
+	keyA := new(big.Int).Exp(g, keya, prime)
+
+	// the verifier stored by the server: g^H(s, H(I:p)) mod N
+	hIP := sha1.Sum([]byte("SYSDBA:masterkey"))
+	x := sha1.Sum(append(append([]byte(nil), salt...), hIP[:]...))
+	v := getVerifier("SYSDBA", "masterkey", salt)
+	if v.Cmp(new(big.Int).Exp(g, new(big.Int).SetBytes(x[:]), prime)) != 0 {
+		t.Fatalf("Error verifier")
+	}
+	// B = k*v + g^b mod N
+	keyB := new(big.Int).Add(new(big.Int).Mul(k, v), new(big.Int).Exp(g, keyb, prime))
+	keyB.Mod(keyB, prime)
+	serverKey := getServerSession("SYSDBA", "masterkey", salt, keyA, keyB, keyb)
+
+	var testProofs = []struct {
+		pluginName string
+		newHash    func() hash.Hash
+	}{
+		{"Srp", sha1.New},
+		{"Srp224", sha256.New224},
+		{"Srp256", sha256.New},
+		{"Srp384", sha512.New384},
+		{"Srp512", sha512.New},
+	}
+	for _, d := range testProofs {
+		keyM, keyK := getClientProof("SYSDBA", "masterkey", salt, keyA, keyB, keya, d.pluginName)
+		if !bytes.Equal(keyK, serverKey) {
+			t.Errorf("Error %s key exchange", d.pluginName)
+		}
+		if len(keyM) != d.newHash().Size() {
+			t.Errorf("Error %s proof size:%d", d.pluginName, len(keyM))
+		}
+		if want := srpServerProof(d.newHash, "SYSDBA", salt, keyA, keyB, serverKey); !bytes.Equal(keyM, want) {
+			t.Errorf("Error %s proof:%x != %x", d.pluginName, keyM, want)
+		}
+	}
+}
+
+func TestSrpQuotedUser(t *testing.T) {
+	// a user created as CREATE USER "Alice" PASSWORD 'secret'
+	salt, _ := getSalt()
+	v := getVerifier("Alice", "secret", salt)
+	keyB, keyb, err := getServerSeed(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := newAuthPlugin("Srp256", `"Alice"`, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srp := plugin.(*srpPlugin)
+	serverPublic := []byte(hex.EncodeToString(bigIntToBytes(keyB)))
+	serverData := append([]byte{byte(len(salt)), 0}, salt...)
+	serverData = append(serverData, byte(len(serverPublic)), 0)
+	serverData = append(serverData, serverPublic...)
+	_, sessionKey, err := plugin.Continue(serverData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKey := getServerSession("Alice", "secret", salt, srp.clientPublic, keyB, keyb)
+	if !bytes.Equal(sessionKey, serverKey) {
+		t.Fatalf("Error srp key exchange of quoted user")
+	}
+}
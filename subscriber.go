@@ -4,152 +4,108 @@ package firebirdsql
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"net"
 	"net/netip"
 	"reflect"
-	"strconv"
 	"sync"
 	"sync/atomic"
 	"syscall"
 )
 
 type Subscription struct {
-	mu               sync.RWMutex
-	revent           *remoteEvent
-	auxHandle        int32
+	mu               sync.Mutex // guards pending
+	fbEvent          *FbEvent
+	events           []string
+	names            map[string]bool
 	callback         EventHandler
 	chEvent          chan Event
-	eventCounts      chan Event
+	pending          []Event
+	signal           chan struct{}
 	closed           int32
 	muClose          sync.Mutex
 	closes           []chan error
 	closer           sync.Once
 	chDoneEvent      chan *Subscription
 	doneSubscription chan struct{}
-	manager          *eventManager
-	fc               *firebirdsqlConn
 	noNotify         int32
 }
 
-func newSubscription(dsn *firebirdDsn, events []string, cb EventHandler, chEvent chan Event, chDoneEvent chan *Subscription) (*Subscription, error) {
-	fc, err := attachFirebirdsqlConn(dsn)
-	if err != nil {
-		return nil, err
+func newSubscription(fbEvent *FbEvent, events []string, cb EventHandler, chEvent chan Event) (*Subscription, error) {
+	if len(events) == 0 {
+		return nil, ErrEventNeed
 	}
 	newSubscription := &Subscription{
-		fc:               fc,
+		fbEvent:          fbEvent,
+		names:            make(map[string]bool, len(events)),
 		callback:         cb,
 		chEvent:          chEvent,
-		eventCounts:      make(chan Event),
+		signal:           make(chan struct{}, 1),
 		doneSubscription: make(chan struct{}),
-		chDoneEvent:      chDoneEvent,
-	}
-	manager, err := newSubscription.getEventManager()
-	if err != nil {
-		return nil, err
+		chDoneEvent:      fbEvent.chDoneSubscriber,
 	}
-	newSubscription.manager = manager
-
-	remoteEvent := newRemoteEvent()
-	if err := remoteEvent.queueEvents(events...); err != nil {
-		return nil, err
+	for _, event := range events {
+		if len(event) > maxEventNameLength {
+			return nil, ErrWrongLengthEvent
+		}
+		if !newSubscription.names[event] {
+			newSubscription.names[event] = true
+			newSubscription.events = append(newSubscription.events, event)
+		}
 	}
-
-	newSubscription.revent = remoteEvent
-
-	newSubscription.queueEvents(0)
-	chErrManager := manager.wait(remoteEvent, newSubscription.eventCounts)
-	go newSubscription.wait(chErrManager)
-
+	go newSubscription.wait()
 	return newSubscription, nil
 }
-func (s *Subscription) cancelEvents() error {
-	if atomic.LoadInt32(&s.closed) == 1 {
-		return nil
-	}
-	id := atomic.LoadInt32(&s.revent.id)
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if err := s.fc.wp.opCancelEvents(id); err != nil {
-		return err
-	}
-	_, _, _, err := s.fc.wp.opResponse()
-	if err != nil {
-		return err
-	}
-	s.revent.cancelEvents()
-	return nil
-}
 
-func (s *Subscription) queueEvents(eventID int32) error {
+// push queues e for delivery without blocking the shared connection.
+func (s *Subscription) push(e Event) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	id := eventID + 1
-	epbData := s.revent.buildEpb()
-
-	if err := s.fc.wp.opQueEvents(s.auxHandle, epbData, id); err != nil {
-		return err
-	}
-	rid, _, _, err := s.fc.wp.opResponse()
-	if err != nil {
-		return err
-	}
-
-	atomic.StoreInt32(&s.revent.id, id)
-	atomic.StoreInt32(&s.revent.rid, rid)
-	return nil
-}
-
-func (s *Subscription) getEventManager() (*eventManager, error) {
-	auxHandle, address, err := s.connAuxRequest()
-	if err != nil {
-		return nil, err
-	}
-	newManager, err := newEventManager(address, auxHandle)
-	if err != nil {
-		return nil, err
+	s.pending = append(s.pending, e)
+	s.mu.Unlock()
+	select {
+	case s.signal <- struct{}{}:
+	default:
 	}
-	s.auxHandle = auxHandle
-	return newManager, nil
 }
 
-func (s *Subscription) wait(chErrManager <-chan error) {
+func (s *Subscription) wait() {
 	for {
 		select {
-		case event := <-s.eventCounts:
-			s.doEventCounts(event)
-			s.queueEvents(event.ID)
+		case <-s.signal:
+			s.mu.Lock()
+			pending := s.pending
+			s.pending = nil
+			s.mu.Unlock()
+			for _, e := range pending {
+				if !s.doEventCounts(e) {
+					return
+				}
+			}
 		case <-s.doneSubscription:
 			return
-		case err := <-chErrManager:
-			s.closeWithError(err)
 		}
 	}
 }
 
-func (s *Subscription) doEventCounts(e Event) {
+func (s *Subscription) doEventCounts(e Event) bool {
 	if s.callback != nil {
 		go s.callback(e)
-		return
+		return true
+	}
+	select {
+	case s.chEvent <- e:
+		return true
+	case <-s.doneSubscription:
+		return false
 	}
-	s.chEvent <- e
 }
 
 func (s *Subscription) Unsubscribe() error {
 	if s.IsClose() {
 		return nil
 	}
-	if s.manager != nil {
-		if err := s.manager.close(); err != nil {
-			return err
-		}
-		s.manager = nil
-	}
-	if err := s.cancelEvents(); err != nil {
-		return err
-	}
-	return s.Close()
+	err := s.fbEvent.unsubscribe(s)
+	return errors.Join(err, s.Close())
 }
 
 func (s *Subscription) unsubscribeNoNotify() error {
@@ -157,13 +113,11 @@ func (s *Subscription) unsubscribeNoNotify() error {
 	return s.Unsubscribe()
 }
 
-func (s *Subscription) connAuxRequest() (int32, string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if err := s.fc.wp.opConnectRequest(); err != nil {
+func connAuxRequest(fc *firebirdsqlConn) (int32, string, error) {
+	if err := fc.wp.opConnectRequest(); err != nil {
 		return -1, "", err
 	}
-	auxHandle, _, buf, err := s.fc.wp.opResponse()
+	auxHandle, _, buf, err := fc.wp.opResponse()
 	if err != nil {
 		return -1, "", err
 	}
@@ -185,19 +139,18 @@ func (s *Subscription) connAuxRequest() (int32, string, error) {
 	}
 
 	var host string
-	if addr.IsUnspecified() {
-		// Server is bound to all interfaces (RemoteBindAddress empty), so it
-		// reports 0.0.0.0 / :: which the client cannot route to. Fall back to
-		// the host the primary connection used — it is reachable by definition.
+	if !addr.IsUnspecified() {
+		// Server bound to all interfaces (RemoteBindAddress empty) reports
+		// 0.0.0.0 / :: which the client cannot route to, so auxAddr falls
+		// back to the host of the primary connection.
 		// See: https://github.com/nakagami/firebirdsql/issues/156
-		host, _, err = net.SplitHostPort(s.fc.dsn.addr)
-		if err != nil {
-			return -1, "", err
-		}
-	} else {
 		host = addr.String()
 	}
-	return auxHandle, net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+	address, err := fc.dsn.auxAddr(host, int(port))
+	if err != nil {
+		return -1, "", err
+	}
+	return auxHandle, address, nil
 }
 
 func (s *Subscription) NotifyClose(receiver chan error) {
@@ -227,23 +180,26 @@ func (s *Subscription) closeWithError(err error) error {
 	return s.doClose(err)
 }
 
+// closeNoNotify closes s without telling its FbEvent, for a subscription
+// that was never handed out.
+func (s *Subscription) closeNoNotify(err error) error {
+	atomic.StoreInt32(&s.noNotify, 1)
+	return s.closeWithError(err)
+}
+
 func (s *Subscription) doClose(err error) (errResult error) {
 	atomic.StoreInt32(&s.closed, 1)
 	s.closer.Do(func() {
-
-		close(s.doneSubscription)
-
+		// receivers have the error by the time doneSubscription is closed
 		s.muClose.Lock()
-		defer s.muClose.Unlock()
 		if err != nil {
 			for _, c := range s.closes {
 				c <- err
 			}
 		}
+		s.muClose.Unlock()
 
-		s.mu.RLock()
-		errResult = s.fc.Close()
-		s.mu.RUnlock()
+		close(s.doneSubscription)
 
 		if atomic.LoadInt32(&s.noNotify) == 0 {
 			s.chDoneEvent <- s
@@ -0,0 +1,220 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TraceConfig describes a trace session configuration (fbtrace.conf syntax).
+// Build renders it for a given server version.
+type TraceConfig struct {
+	Databases []TraceDatabaseConfig
+	Services  *TraceServicesConfig
+}
+
+// TraceDatabaseConfig is a database section. Boolean items left false and
+// numeric items left zero are not written, so the server defaults apply.
+type TraceDatabaseConfig struct {
+	Filter   string // database name pattern, "" matches every database
+	Disabled bool
+
+	IncludeFilter   string // SQL statements to trace (SIMILAR TO pattern)
+	ExcludeFilter   string // SQL statements to skip
+	IncludeGdsCodes string // Firebird 3+
+	ExcludeGdsCodes string // Firebird 3+
+	ConnectionID    int
+	LogFilename     string
+	MaxLogSize      int // megabytes
+
+	LogConnections      bool
+	LogTransactions     bool
+	LogStatementPrepare bool
+	LogStatementFree    bool
+	LogStatementStart   bool
+	LogStatementFinish  bool
+	LogProcedureStart   bool
+	LogProcedureFinish  bool
+	LogFunctionStart    bool // Firebird 3+
+	LogFunctionFinish   bool // Firebird 3+
+	LogTriggerStart     bool
+	LogTriggerFinish    bool
+	LogContext          bool
+	LogErrors           bool
+	LogWarnings         bool
+	LogInitFini         bool
+	LogSweep            bool
+	LogBlrRequests      bool
+	LogDynRequests      bool
+	PrintPlan           bool
+	ExplainPlan         bool // Firebird 3+
+	PrintPerf           bool
+	PrintBlr            bool
+	PrintDyn            bool
+
+	TimeThreshold int // milliseconds
+	MaxSQLLength  int
+	MaxBlrLength  int
+	MaxDynLength  int
+	MaxArgLength  int
+	MaxArgCount   int
+}
+
+// TraceServicesConfig is the services section.
+type TraceServicesConfig struct {
+	Disabled        bool
+	IncludeFilter   string
+	ExcludeFilter   string
+	IncludeGdsCodes string // Firebird 3+
+	ExcludeGdsCodes string // Firebird 3+
+	LogServices     bool
+	LogServiceQuery bool
+	LogErrors       bool
+	LogWarnings     bool
+	LogInitFini     bool
+}
+
+type traceParams [][2]string
+
+func (ps *traceParams) putString(name, value string) {
+	if value != "" {
+		*ps = append(*ps, [2]string{name, value})
+	}
+}
+
+func (ps *traceParams) putBool(name string, value bool) {
+	if value {
+		*ps = append(*ps, [2]string{name, "true"})
+	}
+}
+
+func (ps *traceParams) putInt(name string, value int) {
+	if value > 0 {
+		*ps = append(*ps, [2]string{name, strconv.Itoa(value)})
+	}
+}
+
+func (d *TraceDatabaseConfig) params(major int) traceParams {
+	ps := traceParams{{"enabled", strconv.FormatBool(!d.Disabled)}}
+	ps.putString("include_filter", d.IncludeFilter)
+	ps.putString("exclude_filter", d.ExcludeFilter)
+	if major >= 3 {
+		ps.putString("include_gds_codes", d.IncludeGdsCodes)
+		ps.putString("exclude_gds_codes", d.ExcludeGdsCodes)
+	}
+	ps.putInt("connection_id", d.ConnectionID)
+	ps.putString("log_filename", d.LogFilename)
+	ps.putInt("max_log_size", d.MaxLogSize)
+	ps.putBool("log_connections", d.LogConnections)
+	ps.putBool("log_transactions", d.LogTransactions)
+	ps.putBool("log_statement_prepare", d.LogStatementPrepare)
+	ps.putBool("log_statement_free", d.LogStatementFree)
+	ps.putBool("log_statement_start", d.LogStatementStart)
+	ps.putBool("log_statement_finish", d.LogStatementFinish)
+	ps.putBool("log_procedure_start", d.LogProcedureStart)
+	ps.putBool("log_procedure_finish", d.LogProcedureFinish)
+	if major >= 3 {
+		ps.putBool("log_function_start", d.LogFunctionStart)
+		ps.putBool("log_function_finish", d.LogFunctionFinish)
+	}
+	ps.putBool("log_trigger_start", d.LogTriggerStart)
+	ps.putBool("log_trigger_finish", d.LogTriggerFinish)
+	ps.putBool("log_context", d.LogContext)
+	ps.putBool("log_errors", d.LogErrors)
+	ps.putBool("log_warnings", d.LogWarnings)
+	ps.putBool("log_initfini", d.LogInitFini)
+	ps.putBool("log_sweep", d.LogSweep)
+	ps.putBool("log_blr_requests", d.LogBlrRequests)
+	ps.putBool("log_dyn_requests", d.LogDynRequests)
+	ps.putBool("print_plan", d.PrintPlan)
+	if major >= 3 {
+		ps.putBool("explain_plan", d.ExplainPlan)
+	}
+	ps.putBool("print_perf", d.PrintPerf)
+	ps.putBool("print_blr", d.PrintBlr)
+	ps.putBool("print_dyn", d.PrintDyn)
+	ps.putInt("time_threshold", d.TimeThreshold)
+	ps.putInt("max_sql_length", d.MaxSQLLength)
+	ps.putInt("max_blr_length", d.MaxBlrLength)
+	ps.putInt("max_dyn_length", d.MaxDynLength)
+	ps.putInt("max_arg_length", d.MaxArgLength)
+	ps.putInt("max_arg_count", d.MaxArgCount)
+	return ps
+}
+
+func (s *TraceServicesConfig) params(major int) traceParams {
+	ps := traceParams{{"enabled", strconv.FormatBool(!s.Disabled)}}
+	ps.putString("include_filter", s.IncludeFilter)
+	ps.putString("exclude_filter", s.ExcludeFilter)
+	if major >= 3 {
+		ps.putString("include_gds_codes", s.IncludeGdsCodes)
+		ps.putString("exclude_gds_codes", s.ExcludeGdsCodes)
+	}
+	ps.putBool("log_services", s.LogServices)
+	ps.putBool("log_service_query", s.LogServiceQuery)
+	ps.putBool("log_errors", s.LogErrors)
+	ps.putBool("log_warnings", s.LogWarnings)
+	ps.putBool("log_initfini", s.LogInitFini)
+	return ps
+}
+
+// writeTraceSection renders a section in the Firebird 2.5 (<name value> ...
+// </name>) or Firebird 3+ (name = value { ... }) syntax.
+func writeTraceSection(sb *strings.Builder, major int, name, value string, ps traceParams) {
+	if major < 3 {
+		sb.WriteString("<" + name)
+		if value != "" {
+			sb.WriteString(" " + value)
+		}
+		sb.WriteString(">\n")
+		for _, p := range ps {
+			sb.WriteString("\t" + p[0] + " " + p[1] + "\n")
+		}
+		sb.WriteString("</" + name + ">\n")
+		return
+	}
+	sb.WriteString(name)
+	if value != "" {
+		sb.WriteString(" = " + value)
+	}
+	sb.WriteString("\n{\n")
+	for _, p := range ps {
+		sb.WriteString("\t" + p[0] + " = " + p[1] + "\n")
+	}
+	sb.WriteString("}\n")
+}
+
+// Build renders the configuration in the syntax expected by the server.
+func (c *TraceConfig) Build(version FirebirdVersion) string {
+	var sb strings.Builder
+	for i := range c.Databases {
+		d := &c.Databases[i]
+		writeTraceSection(&sb, version.Major, "database", d.Filter, d.params(version.Major))
+	}
+	if c.Services != nil {
+		writeTraceSection(&sb, version.Major, "services", "", c.Services.params(version.Major))
+	}
+	return sb.String()
+}
@@ -0,0 +1,326 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TraceEvent is an event read from a trace session. The concrete types are
+// *AttachEvent, *TransactionEvent, *StatementFinishEvent, *ProcedureEvent and
+// *RawTraceEvent for everything else.
+type TraceEvent interface {
+	EventHeader() *TraceEventHeader
+}
+
+// TraceEventHeader holds what every trace event has in common.
+type TraceEventHeader struct {
+	Time         time.Time // server wall clock time
+	ProcessID    int
+	ThreadID     string
+	Kind         string // e.g. "ATTACH_DATABASE" or "EXECUTE_STATEMENT_FINISH"
+	Failed       bool
+	Unauthorized bool
+	Attachment   *TraceAttachment
+	Transaction  *TraceTransaction
+	Lines        []string // event text, header line excluded
+}
+
+func (h *TraceEventHeader) EventHeader() *TraceEventHeader {
+	return h
+}
+
+// TraceAttachment identifies the attachment an event belongs to.
+type TraceAttachment struct {
+	Database      string
+	ID            int64
+	User          string
+	Role          string
+	Charset       string
+	Protocol      string // e.g. "TCPv4", empty for embedded attachments
+	RemoteAddress string
+	ProcessName   string
+	ProcessID     int
+}
+
+// TraceTransaction identifies the transaction an event belongs to.
+type TraceTransaction struct {
+	ID      int64
+	Options []string // e.g. ["CONCURRENCY", "WAIT", "READ_WRITE"]
+}
+
+// TracePerf holds the performance counters of an event.
+type TracePerf struct {
+	ElapsedMs int64
+	Reads     int64
+	Writes    int64
+	Fetches   int64
+	Marks     int64
+}
+
+// AttachEvent is ATTACH_DATABASE or DETACH_DATABASE.
+type AttachEvent struct {
+	TraceEventHeader
+}
+
+// TransactionEvent is START_TRANSACTION, COMMIT_TRANSACTION,
+// ROLLBACK_TRANSACTION and their retaining variants.
+type TransactionEvent struct {
+	TraceEventHeader
+	Perf TracePerf
+}
+
+// StatementFinishEvent is EXECUTE_STATEMENT_FINISH.
+type StatementFinishEvent struct {
+	TraceEventHeader
+	StatementID    int64
+	SQL            string
+	Plan           string
+	Params         []string // "type, value" as printed by the server
+	RecordsFetched int64
+	Perf           TracePerf
+}
+
+// ProcedureEvent is EXECUTE_PROCEDURE_START/FINISH and, on Firebird 3+,
+// EXECUTE_FUNCTION_START/FINISH.
+type ProcedureEvent struct {
+	TraceEventHeader
+	Name           string
+	Function       bool
+	Params         []string
+	RecordsFetched int64
+	Perf           TracePerf
+}
+
+// RawTraceEvent is an event the parser has no specific type for.
+type RawTraceEvent struct {
+	TraceEventHeader
+}
+
+var (
+	traceHeaderPattern      = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?)\s+\((\d+):([0-9A-Fa-fx]+)\)\s+(.+?)\s*$`)
+	traceAttachmentPattern  = regexp.MustCompile(`^(.+) \(ATT_(\d+), ([^,]*), ([^,]*), (.*)\)$`)
+	traceProcessPattern     = regexp.MustCompile(`^(.+):(\d+)$`)
+	traceTransactionPattern = regexp.MustCompile(`^\(TRA_(\d+)(?:, (.*))?\)$`)
+	tracePerfPattern        = regexp.MustCompile(`^(\d+) ms(.*)$`)
+	tracePerfItemPattern    = regexp.MustCompile(`(\d+) (read|write|fetch|mark)\(`)
+	traceFetchedPattern     = regexp.MustCompile(`^(\d+) records? fetched$`)
+	traceStatementPattern   = regexp.MustCompile(`^Statement (\d+):$`)
+	traceRoutinePattern     = regexp.MustCompile(`^(Procedure|Function) (.+):$`)
+	traceParamPattern       = regexp.MustCompile(`^param\d+ = (.*)$`)
+)
+
+// TraceParser splits trace output into events. Lines are fed one at a time;
+// an event is complete when the header of the next one arrives, or on Flush.
+type TraceParser struct {
+	header *TraceEventHeader
+}
+
+func NewTraceParser() *TraceParser {
+	return &TraceParser{}
+}
+
+// Feed adds a line of trace output and returns the event it completes, if any.
+func (p *TraceParser) Feed(line string) TraceEvent {
+	line = strings.TrimRight(line, "\r")
+	m := traceHeaderPattern.FindStringSubmatch(line)
+	if m == nil {
+		if p.header != nil {
+			p.header.Lines = append(p.header.Lines, line)
+		}
+		return nil
+	}
+
+	ev := p.Flush()
+	h := &TraceEventHeader{ThreadID: m[3]}
+	h.Time, _ = time.Parse("2006-01-02T15:04:05", m[1])
+	h.ProcessID, _ = strconv.Atoi(m[2])
+	kind := m[4]
+	for {
+		if rest, ok := strings.CutPrefix(kind, "FAILED "); ok {
+			h.Failed, kind = true, rest
+		} else if rest, ok := strings.CutPrefix(kind, "UNAUTHORIZED "); ok {
+			h.Unauthorized, kind = true, rest
+		} else {
+			break
+		}
+	}
+	h.Kind = kind
+	p.header = h
+	return ev
+}
+
+// Flush returns the pending event, if any.
+func (p *TraceParser) Flush() TraceEvent {
+	if p.header == nil {
+		return nil
+	}
+	h := p.header
+	p.header = nil
+	return parseTraceEvent(h)
+}
+
+func parseTracePerf(text string) (TracePerf, bool) {
+	m := tracePerfPattern.FindStringSubmatch(text)
+	if m == nil {
+		return TracePerf{}, false
+	}
+	perf := TracePerf{}
+	perf.ElapsedMs, _ = strconv.ParseInt(m[1], 10, 64)
+	for _, item := range tracePerfItemPattern.FindAllStringSubmatch(m[2], -1) {
+		n, _ := strconv.ParseInt(item[1], 10, 64)
+		switch item[2] {
+		case "read":
+			perf.Reads = n
+		case "write":
+			perf.Writes = n
+		case "fetch":
+			perf.Fetches = n
+		case "mark":
+			perf.Marks = n
+		}
+	}
+	return perf, true
+}
+
+func parseTraceEvent(h *TraceEventHeader) TraceEvent {
+	// Trailing blank lines separate events.
+	for len(h.Lines) > 0 && strings.TrimSpace(h.Lines[len(h.Lines)-1]) == "" {
+		h.Lines = h.Lines[:len(h.Lines)-1]
+	}
+
+	// The attachment, client process and transaction lines come first.
+	body := h.Lines
+	if len(body) > 0 {
+		if m := traceAttachmentPattern.FindStringSubmatch(strings.TrimSpace(body[0])); m != nil {
+			att := &TraceAttachment{Database: m[1], Charset: m[4]}
+			att.ID, _ = strconv.ParseInt(m[2], 10, 64)
+			att.User, att.Role, _ = strings.Cut(m[3], ":")
+			if protocol, addr, ok := strings.Cut(m[5], ":"); ok {
+				att.Protocol, att.RemoteAddress = protocol, addr
+			} else if m[5] != "<internal>" {
+				att.Protocol = m[5]
+			}
+			h.Attachment = att
+			body = body[1:]
+			if len(body) > 0 && !traceTransactionPattern.MatchString(strings.TrimSpace(body[0])) {
+				if m := traceProcessPattern.FindStringSubmatch(strings.TrimSpace(body[0])); m != nil {
+					att.ProcessName = m[1]
+					att.ProcessID, _ = strconv.Atoi(m[2])
+					body = body[1:]
+				}
+			}
+		}
+	}
+	if len(body) > 0 {
+		if m := traceTransactionPattern.FindStringSubmatch(strings.TrimSpace(body[0])); m != nil {
+			tra := &TraceTransaction{}
+			tra.ID, _ = strconv.ParseInt(m[1], 10, 64)
+			if m[2] != "" {
+				tra.Options = strings.Split(m[2], " | ")
+			}
+			h.Transaction = tra
+			body = body[1:]
+		}
+	}
+
+	switch {
+	case h.Kind == "ATTACH_DATABASE" || h.Kind == "DETACH_DATABASE":
+		return &AttachEvent{TraceEventHeader: *h}
+	case strings.HasSuffix(h.Kind, "_TRANSACTION") || strings.HasSuffix(h.Kind, "_RETAINING"):
+		ev := &TransactionEvent{TraceEventHeader: *h}
+		for _, line := range body {
+			if perf, ok := parseTracePerf(strings.TrimSpace(line)); ok {
+				ev.Perf = perf
+			}
+		}
+		return ev
+	case h.Kind == "EXECUTE_STATEMENT_FINISH":
+		return parseStatementFinish(h, body)
+	case strings.HasPrefix(h.Kind, "EXECUTE_PROCEDURE_") || strings.HasPrefix(h.Kind, "EXECUTE_FUNCTION_"):
+		ev := &ProcedureEvent{TraceEventHeader: *h}
+		for _, line := range body {
+			text := strings.TrimSpace(line)
+			if m := traceRoutinePattern.FindStringSubmatch(text); m != nil {
+				ev.Function = m[1] == "Function"
+				ev.Name = m[2]
+			} else if m := traceParamPattern.FindStringSubmatch(text); m != nil {
+				ev.Params = append(ev.Params, m[1])
+			} else if m := traceFetchedPattern.FindStringSubmatch(text); m != nil {
+				ev.RecordsFetched, _ = strconv.ParseInt(m[1], 10, 64)
+			} else if perf, ok := parseTracePerf(text); ok {
+				ev.Perf = perf
+			}
+		}
+		return ev
+	}
+	return &RawTraceEvent{TraceEventHeader: *h}
+}
+
+func parseStatementFinish(h *TraceEventHeader, body []string) *StatementFinishEvent {
+	ev := &StatementFinishEvent{TraceEventHeader: *h}
+	for i := 0; i < len(body); i++ {
+		text := strings.TrimSpace(body[i])
+		if m := traceStatementPattern.FindStringSubmatch(text); m != nil {
+			ev.StatementID, _ = strconv.ParseInt(m[1], 10, 64)
+			// A dashed line, the SQL text, then a line of carets.
+			if i+1 < len(body) && strings.HasPrefix(strings.TrimSpace(body[i+1]), "---") {
+				i++
+			}
+			end := i + 1
+			for end < len(body) && !strings.HasPrefix(strings.TrimSpace(body[end]), "^^^") {
+				end++
+			}
+			if end == len(body) {
+				// no caret line, the SQL text ends at the first blank line
+				for end = i + 1; end < len(body) && strings.TrimSpace(body[end]) != ""; end++ {
+				}
+			}
+			sql := body[i+1 : end]
+			i = end
+			ev.SQL = strings.TrimRight(strings.Join(sql, "\n"), "\n ")
+			continue
+		}
+		if strings.HasPrefix(text, "PLAN ") || text == "Select Expression" {
+			plan := []string{body[i]}
+			for i+1 < len(body) && strings.TrimSpace(body[i+1]) != "" {
+				plan = append(plan, body[i+1])
+				i++
+			}
+			ev.Plan = strings.Join(plan, "\n")
+			continue
+		}
+		if m := traceParamPattern.FindStringSubmatch(text); m != nil {
+			ev.Params = append(ev.Params, m[1])
+		} else if m := traceFetchedPattern.FindStringSubmatch(text); m != nil {
+			ev.RecordsFetched, _ = strconv.ParseInt(m[1], 10, 64)
+		} else if perf, ok := parseTracePerf(text); ok {
+			ev.Perf = perf
+		}
+	}
+	return ev
+}
@@ -1,9 +1,51 @@
 package firebirdsql
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+)
+
+var (
+	ErrTraceSessionNotFound   = errors.New("trace session not found")
+	ErrTraceSessionStopped    = errors.New("session already stopped")
+	ErrTraceSessionNotRunning = errors.New("session not running")
+	ErrTraceSessionNotPaused  = errors.New("session not paused")
+	ErrTraceSessionNoOutput   = errors.New("trace session output is only available to the connection that started it")
+)
+
+// TraceError is returned when the server does not confirm a trace action.
+type TraceError struct {
+	Action    string // "start", "stop", "pause" or "resume"
+	SessionID int32  // 0 for "start"
+	Message   string // server response
+	Err       error  // cause, e.g. ErrTraceSessionNotFound; nil if unknown
+}
+
+func (e *TraceError) Error() string {
+	return fmt.Sprintf("unable to %s trace session: %s", e.Action, e.Message)
+}
+
+func (e *TraceError) Unwrap() error {
+	return e.Err
+}
+
+// TraceSessionInfo describes a trace session as listed by the server.
+type TraceSessionInfo struct {
+	ID    int32
+	Name  string
+	User  string
+	Date  string // server local time, e.g. "2024-01-02 03:04:05"
+	Flags []string
+	State int // SessionRunning or SessionPaused
+}
+
+var (
+	traceStartedPattern   = regexp.MustCompile(`Trace session ID (\d+) started`)
+	traceListIDPattern    = regexp.MustCompile(`^Session ID:\s*(\d+)`)
+	traceListFieldPattern = regexp.MustCompile(`^\s+(\w+):\s*(.*?)\s*$`)
 )
 
 type TraceManager struct {
@@ -37,14 +79,33 @@ func (t *TraceManager) Start(config string) (*TraceSession, error) {
 }
 
 func (t *TraceManager) StartWithName(name string, config string) (*TraceSession, error) {
-	var (
-		conn *ServiceManager
-		id   int64
-		err  error
-	)
-	if conn, err = t.connBuilder(); err != nil {
+	conn, err := t.connBuilder()
+	if err != nil {
+		return nil, err
+	}
+	return t.start(conn, name, config)
+}
+
+// StartWithConfig starts a trace session with config rendered for the
+// version of the server.
+func (t *TraceManager) StartWithConfig(name string, config *TraceConfig) (*TraceSession, error) {
+	conn, err := t.connBuilder()
+	if err != nil {
 		return nil, err
 	}
+	version, err := conn.GetServerVersion()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return t.start(conn, name, config.Build(version))
+}
+
+func (t *TraceManager) start(conn *ServiceManager, name string, config string) (*TraceSession, error) {
+	var (
+		id  int64
+		err error
+	)
 
 	var res string
 	var spb = NewXPBWriterFromTag(isc_action_svc_trace_start)
@@ -61,11 +122,10 @@ func (t *TraceManager) StartWithName(name string, config string) (*TraceSession,
 	if res, _, err = conn.GetString(); err != nil {
 		return nil, err
 	}
-	re := regexp.MustCompile(`Trace session ID (\d+) started`)
-	match := re.FindStringSubmatch(res)
+	match := traceStartedPattern.FindStringSubmatch(res)
 	if len(match) == 0 {
 		_ = conn.Close()
-		return nil, fmt.Errorf("unable to start trace session: %s", res)
+		return nil, &TraceError{Action: "start", Message: res}
 	}
 	if id, err = strconv.ParseInt(match[1], 10, 32); err != nil {
 		return nil, err
@@ -87,7 +147,7 @@ func (t *TraceManager) List() (string, error) {
 		conn      *ServiceManager
 	)
 	if conn, err = t.connBuilder(); err != nil {
-		return "", nil
+		return "", err
 	}
 	defer func(conn *ServiceManager) {
 		_ = conn.Close()
@@ -99,7 +159,7 @@ func (t *TraceManager) List() (string, error) {
 
 	for {
 		if line, end, err = conn.GetString(); err != nil {
-			return "", nil
+			return "", err
 		}
 		if end {
 			return res, nil
@@ -108,12 +168,83 @@ func (t *TraceManager) List() (string, error) {
 	}
 }
 
+// ListSessions returns the trace sessions the user may see.
+func (t *TraceManager) ListSessions() ([]TraceSessionInfo, error) {
+	res, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+	return parseTraceSessions(res), nil
+}
+
+func parseTraceSessions(s string) []TraceSessionInfo {
+	var sessions []TraceSessionInfo
+	var cur *TraceSessionInfo
+	for _, line := range strings.Split(s, "\n") {
+		if m := traceListIDPattern.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.ParseInt(m[1], 10, 32)
+			sessions = append(sessions, TraceSessionInfo{ID: int32(id), State: SessionRunning})
+			cur = &sessions[len(sessions)-1]
+			continue
+		}
+		m := traceListFieldPattern.FindStringSubmatch(line)
+		if m == nil || cur == nil {
+			continue
+		}
+		switch strings.ToLower(m[1]) {
+		case "name":
+			cur.Name = m[2]
+		case "user":
+			cur.User = m[2]
+		case "date":
+			cur.Date = m[2]
+		case "flags":
+			cur.Flags = strings.Split(m[2], ", ")
+			for _, flag := range cur.Flags {
+				if flag == "suspend" {
+					cur.State = SessionPaused
+				}
+			}
+		}
+	}
+	return sessions
+}
+
+// Attach returns a session to control the existing trace session id, e.g.
+// one started before the process restarted. The output of the session is
+// only delivered to the connection that started it, so the Wait methods of
+// the returned session fail with ErrTraceSessionNoOutput.
+func (t *TraceManager) Attach(id int32) (*TraceSession, error) {
+	sessions, err := t.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range sessions {
+		if info.ID == id {
+			return &TraceSession{
+				connBuilder: t.connBuilder,
+				id:          id,
+				state:       info.State,
+			}, nil
+		}
+	}
+	return nil, ErrTraceSessionNotFound
+}
+
+// ID returns the id of the session on the server.
+func (ts *TraceSession) ID() int32 {
+	return ts.id
+}
+
 func (ts *TraceSession) Close() (err error) {
 	if ts.state != SessionStopped {
 		if err = ts.Stop(); err != nil {
 			return err
 		}
 	}
+	if ts.conn == nil {
+		return nil
+	}
 	if err = ts.conn.Close(); err != nil {
 		return err
 	}
@@ -123,72 +254,40 @@ func (ts *TraceSession) Close() (err error) {
 
 func (ts *TraceSession) Stop() (err error) {
 	if ts.state == SessionStopped {
-		return fmt.Errorf("session already stopped")
-	}
-	var auxConn *ServiceManager
-	if auxConn, err = ts.connBuilder(); err != nil {
-		return
+		return ErrTraceSessionStopped
 	}
-	defer func(auxConn *ServiceManager) {
-		_ = auxConn.Close()
-	}(auxConn)
-
-	var res string
-	spb := NewXPBWriterFromTag(isc_action_svc_trace_stop)
-	spb.PutInt32(isc_spb_trc_id, ts.id)
-
-	if err = auxConn.ServiceStart(spb.Bytes()); err != nil {
-		return err
-	}
-	if res, _, err = auxConn.GetString(); err != nil {
+	if err = ts.control(isc_action_svc_trace_stop, "stop", "stopped"); err != nil {
 		return err
 	}
-	re := regexp.MustCompile(`Trace session ID (\d+) stopped`)
-	match := re.FindStringSubmatch(res)
-	if len(match) == 0 {
-		return fmt.Errorf("unable to stop trace session: %s", res)
-	}
 	ts.state = SessionStopped
 	return nil
 }
 
 func (ts *TraceSession) Pause() (err error) {
 	if ts.state != SessionRunning {
-		return fmt.Errorf("session not running")
-	}
-
-	var auxConn *ServiceManager
-	if auxConn, err = ts.connBuilder(); err != nil {
-		return
+		return ErrTraceSessionNotRunning
 	}
-	defer func(auxConn *ServiceManager) {
-		_ = auxConn.Close()
-	}(auxConn)
-
-	var res string
-	spb := NewXPBWriterFromTag(isc_action_svc_trace_suspend)
-	spb.PutInt32(isc_spb_trc_id, ts.id)
-
-	if err = auxConn.ServiceStart(spb.Bytes()); err != nil {
-		return err
-	}
-	if res, _, err = auxConn.GetString(); err != nil {
+	if err = ts.control(isc_action_svc_trace_suspend, "pause", "paused"); err != nil {
 		return err
 	}
-	re := regexp.MustCompile(`Trace session ID (\d+) paused`)
-	match := re.FindStringSubmatch(res)
-	if len(match) == 0 {
-		return fmt.Errorf("unable to pause trace session: %s", res)
-	}
 	ts.state = SessionPaused
 	return nil
 }
 
 func (ts *TraceSession) Resume() (err error) {
 	if ts.state != SessionPaused {
-		return fmt.Errorf("session not paused")
+		return ErrTraceSessionNotPaused
+	}
+	if err = ts.control(isc_action_svc_trace_resume, "resume", "resumed"); err != nil {
+		return err
 	}
+	ts.state = SessionRunning
+	return nil
+}
 
+// control runs a trace action on an auxiliary connection and checks that
+// the server answers "Trace session ID <id> <done>".
+func (ts *TraceSession) control(action byte, name string, done string) (err error) {
 	var auxConn *ServiceManager
 	if auxConn, err = ts.connBuilder(); err != nil {
 		return
@@ -198,7 +297,7 @@ func (ts *TraceSession) Resume() (err error) {
 	}(auxConn)
 
 	var res string
-	spb := NewXPBWriterFromTag(isc_action_svc_trace_resume)
+	spb := NewXPBWriterFromTag(action)
 	spb.PutInt32(isc_spb_trc_id, ts.id)
 
 	if err = auxConn.ServiceStart(spb.Bytes()); err != nil {
@@ -207,19 +306,50 @@ func (ts *TraceSession) Resume() (err error) {
 	if res, _, err = auxConn.GetString(); err != nil {
 		return err
 	}
-	re := regexp.MustCompile(`Trace session ID (\d+) resumed`)
-	match := re.FindStringSubmatch(res)
-	if len(match) == 0 {
-		return fmt.Errorf("unable to resume trace session: %s", res)
+	return traceControlResult(ts.id, name, done, res)
+}
+
+// traceControlResult checks the answer of the server to a trace action.
+func traceControlResult(id int32, name string, done string, res string) error {
+	res = strings.TrimSpace(res)
+	switch res {
+	case fmt.Sprintf("Trace session ID %d %s", id, done):
+		return nil
+	case fmt.Sprintf("Trace session ID %d not found", id):
+		return &TraceError{Action: name, SessionID: id, Message: res, Err: ErrTraceSessionNotFound}
 	}
-	ts.state = SessionRunning
-	return nil
+	return &TraceError{Action: name, SessionID: id, Message: res}
 }
 
 func (ts *TraceSession) Wait() (err error) {
+	if ts.conn == nil {
+		return ErrTraceSessionNoOutput
+	}
 	return ts.conn.Wait()
 }
 
 func (ts *TraceSession) WaitStrings(result chan string) (err error) {
+	if ts.conn == nil {
+		return ErrTraceSessionNoOutput
+	}
 	return ts.conn.WaitStrings(result)
 }
+
+// WaitEvents parses the session output into events and sends them to
+// events until the session is stopped.
+func (ts *TraceSession) WaitEvents(events chan TraceEvent) error {
+	if ts.conn == nil {
+		return ErrTraceSessionNoOutput
+	}
+	parser := NewTraceParser()
+	err := ts.conn.waitLines(func(line string) error {
+		if ev := parser.Feed(line); ev != nil {
+			events <- ev
+		}
+		return nil
+	})
+	if ev := parser.Flush(); ev != nil {
+		events <- ev
+	}
+	return err
+}
@@ -0,0 +1,219 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceConfigBuild(t *testing.T) {
+	config := &TraceConfig{
+		Databases: []TraceDatabaseConfig{
+			{Disabled: true},
+			{
+				Filter:             `%[\\/]employee.fdb`,
+				IncludeGdsCodes:    "deadlock",
+				LogStatementFinish: true,
+				LogFunctionFinish:  true,
+				PrintPlan:          true,
+				TimeThreshold:      100,
+				MaxSQLLength:       4096,
+			},
+		},
+		Services: &TraceServicesConfig{LogServices: true},
+	}
+
+	assert.Equal(t, `database
+{
+	enabled = false
+}
+database = %[\\/]employee.fdb
+{
+	enabled = true
+	include_gds_codes = deadlock
+	log_statement_finish = true
+	log_function_finish = true
+	print_plan = true
+	time_threshold = 100
+	max_sql_length = 4096
+}
+services
+{
+	enabled = true
+	log_services = true
+}
+`, config.Build(FirebirdVersion{Major: 3}))
+
+	assert.Equal(t, `<database>
+	enabled false
+</database>
+<database %[\\/]employee.fdb>
+	enabled true
+	log_statement_finish true
+	print_plan true
+	time_threshold 100
+	max_sql_length 4096
+</database>
+<services>
+	enabled true
+	log_services true
+</services>
+`, config.Build(FirebirdVersion{Major: 2, Minor: 5}))
+}
+
+func TestTraceParser(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "trace", "fb30.log"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	parser := NewTraceParser()
+	var events []TraceEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if ev := parser.Feed(scanner.Text()); ev != nil {
+			events = append(events, ev)
+		}
+	}
+	if ev := parser.Flush(); ev != nil {
+		events = append(events, ev)
+	}
+	require.Len(t, events, 8)
+
+	attach, ok := events[0].(*AttachEvent)
+	require.True(t, ok)
+	assert.Equal(t, "ATTACH_DATABASE", attach.Kind)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC), attach.Time)
+	assert.Equal(t, 1234, attach.ProcessID)
+	assert.Equal(t, &TraceAttachment{
+		Database:      "/db/employee.fdb",
+		ID:            12,
+		User:          "SYSDBA",
+		Role:          "NONE",
+		Charset:       "UTF8",
+		Protocol:      "TCPv4",
+		RemoteAddress: "127.0.0.1/51234",
+		ProcessName:   "/usr/bin/isql",
+		ProcessID:     4321,
+	}, attach.Attachment)
+	assert.Nil(t, attach.Transaction)
+
+	start, ok := events[1].(*TransactionEvent)
+	require.True(t, ok)
+	assert.Equal(t, &TraceTransaction{ID: 45, Options: []string{"CONCURRENCY", "WAIT", "READ_WRITE"}}, start.Transaction)
+
+	stmt, ok := events[2].(*StatementFinishEvent)
+	require.True(t, ok)
+	assert.False(t, stmt.Failed)
+	assert.Equal(t, int64(67), stmt.StatementID)
+	assert.Equal(t, "select currency\nfrom country\nwhere country = ?", stmt.SQL)
+	assert.Equal(t, "PLAN (COUNTRY INDEX (RDB$PRIMARY1))", stmt.Plan)
+	assert.Equal(t, []string{`varchar(15), "USA"`}, stmt.Params)
+	assert.Equal(t, int64(1), stmt.RecordsFetched)
+	assert.Equal(t, TracePerf{ElapsedMs: 2, Reads: 2, Fetches: 3, Marks: 1}, stmt.Perf)
+
+	proc, ok := events[3].(*ProcedureEvent)
+	require.True(t, ok)
+	assert.Equal(t, "ADD_EMP_PROJ", proc.Name)
+	assert.False(t, proc.Function)
+	assert.Equal(t, []string{`smallint, "141"`, `char(5), "DGPII"`}, proc.Params)
+	assert.Equal(t, TracePerf{ElapsedMs: 1, Fetches: 4, Marks: 2}, proc.Perf)
+
+	failed, ok := events[4].(*StatementFinishEvent)
+	require.True(t, ok)
+	assert.True(t, failed.Failed)
+	assert.Equal(t, "EXECUTE_STATEMENT_FINISH", failed.Kind)
+	assert.Equal(t, "select * from no_such_table", failed.SQL)
+
+	commit, ok := events[5].(*TransactionEvent)
+	require.True(t, ok)
+	assert.Equal(t, "COMMIT_TRANSACTION", commit.Kind)
+	assert.Equal(t, TracePerf{ElapsedMs: 3, Writes: 1, Fetches: 5, Marks: 2}, commit.Perf)
+
+	raw, ok := events[6].(*RawTraceEvent)
+	require.True(t, ok)
+	assert.Equal(t, "TRACE_INIT", raw.EventHeader().Kind)
+
+	detach, ok := events[7].(*AttachEvent)
+	require.True(t, ok)
+	assert.Equal(t, "DETACH_DATABASE", detach.Kind)
+}
+
+func TestParseTraceSessions(t *testing.T) {
+	list := "Session ID: 3\n" +
+		"  name:  slow queries\n" +
+		"  user:  SYSDBA\n" +
+		"  date:  2024-01-02 03:04:05\n" +
+		"  flags: active, trace\n" +
+		"\n" +
+		"Session ID: 7\n" +
+		"  user:  ALICE\n" +
+		"  date:  2024-01-02 04:05:06\n" +
+		"  flags: suspend, audit\n"
+
+	sessions := parseTraceSessions(list)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, TraceSessionInfo{
+		ID:    3,
+		Name:  "slow queries",
+		User:  "SYSDBA",
+		Date:  "2024-01-02 03:04:05",
+		Flags: []string{"active", "trace"},
+		State: SessionRunning,
+	}, sessions[0])
+	assert.Equal(t, int32(7), sessions[1].ID)
+	assert.Equal(t, "", sessions[1].Name)
+	assert.Equal(t, SessionPaused, sessions[1].State)
+
+	assert.Empty(t, parseTraceSessions(""))
+}
+
+func TestTraceErrors(t *testing.T) {
+	var err error = &TraceError{Action: "stop", SessionID: 9, Message: "No permissions to change the trace session ID 9"}
+	assert.Equal(t, "unable to stop trace session: No permissions to change the trace session ID 9", err.Error())
+	assert.False(t, errors.Is(err, ErrTraceSessionNotFound))
+
+	assert.NoError(t, traceControlResult(9, "stop", "stopped", "Trace session ID 9 stopped\n"))
+	err = traceControlResult(9, "stop", "stopped", "Trace session ID 9 stopped by someone else")
+	assert.False(t, errors.Is(err, ErrTraceSessionNotFound))
+	err = traceControlResult(9, "pause", "paused", "Trace session ID 9 not found\n")
+	assert.True(t, errors.Is(err, ErrTraceSessionNotFound))
+	assert.Equal(t, "unable to pause trace session: Trace session ID 9 not found", err.Error())
+	var traceErr *TraceError
+	require.True(t, errors.As(err, &traceErr))
+	assert.Equal(t, int32(9), traceErr.SessionID)
+
+	ts := &TraceSession{id: 9, state: SessionStopped}
+	assert.ErrorIs(t, ts.Stop(), ErrTraceSessionStopped)
+	assert.ErrorIs(t, ts.Pause(), ErrTraceSessionNotRunning)
+	assert.ErrorIs(t, ts.Resume(), ErrTraceSessionNotPaused)
+	assert.ErrorIs(t, ts.Wait(), ErrTraceSessionNoOutput)
+}
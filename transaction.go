@@ -27,6 +27,7 @@ type firebirdsqlTx struct {
 	fc             *firebirdsqlConn
 	isolationLevel int
 	isAutocommit   bool
+	implicit       bool // the connection's own transaction, not one started by BeginTx
 	transHandle    int32
 	needBegin      bool
 }
@@ -97,6 +98,9 @@ func (tx *firebirdsqlTx) begin() (err error) {
 	if err != nil {
 		return err
 	}
+	if tx.implicit && tx.fc.autocommitMode == autocommitServer {
+		tpb = append(tpb, byte(isc_tpb_autocommit))
+	}
 	err = tx.fc.wp.opTransaction(tpb)
 	if err != nil {
 		return
@@ -113,10 +117,36 @@ func (tx *firebirdsqlTx) commitRetainging() (err error) {
 		return
 	}
 	_, _, _, err = tx.fc.wp.opResponse()
-	tx.isAutocommit = tx.fc.isAutocommit
 	return
 }
 
+func (tx *firebirdsqlTx) rollbackRetaining() (err error) {
+	err = tx.fc.wp.opRollbackRetaining(tx.transHandle)
+	if err != nil {
+		return
+	}
+	_, _, _, err = tx.fc.wp.opResponse()
+	return
+}
+
+// CommitRetaining commits the work done so far but keeps the transaction
+// context, so cursors opened in the transaction stay open.
+func (tx *firebirdsqlTx) CommitRetaining() error {
+	if tx.needBegin {
+		return nil
+	}
+	return tx.commitRetainging()
+}
+
+// RollbackRetaining undoes the work done so far but keeps the transaction
+// context, so cursors opened in the transaction stay open.
+func (tx *firebirdsqlTx) RollbackRetaining() error {
+	if tx.needBegin {
+		return nil
+	}
+	return tx.rollbackRetaining()
+}
+
 func (tx *firebirdsqlTx) Commit() (err error) {
 	err = tx.fc.wp.opCommit(tx.transHandle)
 	if err != nil {
@@ -124,6 +154,7 @@ func (tx *firebirdsqlTx) Commit() (err error) {
 	}
 	_, _, _, err = tx.fc.wp.opResponse()
 	tx.isAutocommit = tx.fc.isAutocommit
+	tx.implicit = true
 	tx.needBegin = true
 	return
 }
@@ -135,15 +166,17 @@ func (tx *firebirdsqlTx) Rollback() (err error) {
 	}
 	_, _, _, err = tx.fc.wp.opResponse()
 	tx.isAutocommit = tx.fc.isAutocommit
+	tx.implicit = true
 	tx.needBegin = true
 	return
 }
 
-func newFirebirdsqlTx(fc *firebirdsqlConn, isolationLevel int, isAutocommit bool, withBegin bool) (tx *firebirdsqlTx, err error) {
+func newFirebirdsqlTx(fc *firebirdsqlConn, isolationLevel int, implicit bool, withBegin bool) (tx *firebirdsqlTx, err error) {
 	tx = new(firebirdsqlTx)
 	tx.fc = fc
 	tx.isolationLevel = isolationLevel
-	tx.isAutocommit = isAutocommit
+	tx.implicit = implicit
+	tx.isAutocommit = implicit && fc.isAutocommit
 	tx.needBegin = false
 
 	if withBegin {
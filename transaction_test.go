@@ -25,6 +25,7 @@ package firebirdsql
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"testing"
 	"time"
@@ -550,3 +551,102 @@ func TestIssue136(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAutocommitMode(t *testing.T) {
+	for s, want := range map[string]int{
+		"":       autocommitClient,
+		"true":   autocommitClient,
+		"false":  autocommitNone,
+		"0":      autocommitNone,
+		"server": autocommitServer,
+		"SERVER": autocommitServer,
+	} {
+		if got := parseAutocommitMode(s); got != want {
+			t.Errorf("parseAutocommitMode(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestAutocommitDisabled(t *testing.T) {
+	var n int
+	test_dsn := GetTestDSN("test_autocommit_disabled_")
+	conn, err := sql.Open("firebirdsql_createdb", test_dsn)
+	if err != nil {
+		t.Fatalf("Error sql.Open(): %v", err)
+	}
+	conn.Exec("CREATE TABLE test_autocommit (i integer)")
+	conn.Close()
+
+	conn, err = sql.Open("firebirdsql", test_dsn+"?autocommit=false")
+	if err != nil {
+		t.Fatalf("Error sql.Open(): %v", err)
+	}
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Error Conn(): %v", err)
+	}
+	if _, err = c.ExecContext(ctx, "INSERT INTO test_autocommit (i) values (1)"); err != nil {
+		t.Fatalf("Error Insert: %v", err)
+	}
+	if err = RollbackRetaining(c); err != nil {
+		t.Fatalf("Error RollbackRetaining: %v", err)
+	}
+	if _, err = c.ExecContext(ctx, "INSERT INTO test_autocommit (i) values (2)"); err != nil {
+		t.Fatalf("Error Insert: %v", err)
+	}
+	if err = CommitRetaining(c); err != nil {
+		t.Fatalf("Error CommitRetaining: %v", err)
+	}
+	if _, err = c.ExecContext(ctx, "INSERT INTO test_autocommit (i) values (3)"); err != nil {
+		t.Fatalf("Error Insert: %v", err)
+	}
+	c.Close()
+	conn.Close()
+
+	conn, err = sql.Open("firebirdsql", test_dsn)
+	if err != nil {
+		t.Fatalf("Error sql.Open(): %v", err)
+	}
+	defer conn.Close()
+	err = conn.QueryRow("SELECT Count(*) FROM test_autocommit").Scan(&n)
+	if err != nil {
+		t.Fatalf("Error SELECT: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Incorrect count: %v", n)
+	}
+}
+
+func TestAutocommitServer(t *testing.T) {
+	var n int
+	test_dsn := GetTestDSN("test_autocommit_server_")
+	conn, err := sql.Open("firebirdsql_createdb", test_dsn)
+	if err != nil {
+		t.Fatalf("Error sql.Open(): %v", err)
+	}
+	conn.Exec("CREATE TABLE test_autocommit (i integer)")
+	conn.Close()
+
+	conn, err = sql.Open("firebirdsql", test_dsn+"?autocommit=server")
+	if err != nil {
+		t.Fatalf("Error sql.Open(): %v", err)
+	}
+	if _, err = conn.Exec("INSERT INTO test_autocommit (i) values (1)"); err != nil {
+		t.Fatalf("Error Insert: %v", err)
+	}
+	conn.Close()
+
+	conn, err = sql.Open("firebirdsql", test_dsn)
+	if err != nil {
+		t.Fatalf("Error sql.Open(): %v", err)
+	}
+	defer conn.Close()
+	err = conn.QueryRow("SELECT Count(*) FROM test_autocommit").Scan(&n)
+	if err != nil {
+		t.Fatalf("Error SELECT: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Incorrect count: %v", n)
+	}
+}
@@ -32,6 +32,11 @@ type User struct {
 	UserId     int32
 	GroupId    int32
 	Admin      *bool
+
+	// Used by SQLUserManager only (Firebird 3.0+)
+	Plugin *string
+	Active *bool
+	Tags   map[string]string
 }
 
 type UserManager struct {
@@ -123,6 +128,35 @@ func WithoutAdmin() UserOption {
 	}
 }
 
+func WithPlugin(plugin string) UserOption {
+	return func(opts *User) {
+		opts.Plugin = &plugin
+	}
+}
+
+func WithActive() UserOption {
+	return func(opts *User) {
+		res := true
+		opts.Active = &res
+	}
+}
+
+func WithInactive() UserOption {
+	return func(opts *User) {
+		res := false
+		opts.Active = &res
+	}
+}
+
+func WithTag(key string, value string) UserOption {
+	return func(opts *User) {
+		if opts.Tags == nil {
+			opts.Tags = make(map[string]string)
+		}
+		opts.Tags[key] = value
+	}
+}
+
 func NewUser(opts ...UserOption) User {
 	res := User{
 		UserId:  -1,
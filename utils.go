@@ -27,7 +27,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"math/big"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -265,6 +267,54 @@ func _timestampToBlr(t time.Time, protocolVersion int32, sessionTimezone string)
 	return _timestampToBlrNoTZ(t)
 }
 
+// QuoteString returns s as an SQL string literal.
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+var regularNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_$]*$`)
+
+var reservedWords = make(map[string]bool)
+
+func init() {
+	for _, word := range strings.Fields(`
+		ADD ADMIN ALL ALTER AND ANY AS AT AVG BEGIN BETWEEN BIGINT BIT_LENGTH BLOB
+		BOOLEAN BOTH BY CASE CAST CHAR CHAR_LENGTH CHARACTER CHARACTER_LENGTH CHECK
+		CLOSE COLLATE COLUMN COMMIT CONNECT CONSTRAINT CORR COUNT CREATE CROSS
+		CURRENT CURRENT_CONNECTION CURRENT_DATE CURRENT_ROLE CURRENT_TIME
+		CURRENT_TIMESTAMP CURRENT_TRANSACTION CURRENT_USER CURSOR DATE DAY DEC
+		DECFLOAT DECIMAL DECLARE DEFAULT DELETE DELETING DETERMINISTIC DISCONNECT
+		DISTINCT DOUBLE DROP ELSE END ESCAPE EXECUTE EXISTS EXTERNAL EXTRACT FALSE
+		FETCH FILTER FLOAT FOR FOREIGN FROM FULL FUNCTION GDSCODE GLOBAL GRANT
+		GROUP HAVING HOUR IN INDEX INNER INSENSITIVE INSERT INSERTING INT INT128
+		INTEGER INTO IS JOIN LEADING LEFT LIKE LOCAL LOCALTIME LOCALTIMESTAMP LONG
+		LOWER MAX MERGE MIN MINUTE MONTH NATIONAL NATURAL NCHAR NO NOT NULL NUMERIC
+		OCTET_LENGTH OF OFFSET ON ONLY OPEN OR ORDER OUTER OVER PARAMETER PLAN
+		POSITION POST_EVENT PRECISION PRIMARY PROCEDURE PUBLICATION RDB$DB_KEY
+		RDB$RECORD_VERSION REAL RECORD_VERSION RECREATE RECURSIVE REFERENCES
+		RELEASE RESETTING RETURN RETURNING_VALUES RETURNS REVOKE RIGHT ROLLBACK ROW
+		ROW_COUNT ROWS SAVEPOINT SCROLL SECOND SELECT SENSITIVE SET SIMILAR
+		SMALLINT SOME SQLCODE SQLSTATE START SUM TABLE THEN TIME TIMESTAMP TO
+		TRAILING TRIGGER TRIM TRUE UNION UNIQUE UNKNOWN UPDATE UPDATING UPPER USER
+		USING VALUE VALUES VARBINARY VARCHAR VARIABLE VARYING VIEW WHEN WHERE WHILE
+		WINDOW WITH WITHOUT YEAR`) {
+		reservedWords[word] = true
+	}
+}
+
+// QuoteIdentifier returns name as it is written in dialect 3 DDL: as is if
+// it is an upper-case regular identifier, otherwise double quoted.
+func QuoteIdentifier(name string) string {
+	if regularNamePattern.MatchString(name) && !reservedWords[name] {
+		return name
+	}
+	return delimitIdentifier(name)
+}
+
+func delimitIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
 func convertToBool(s string, defaultValue bool) bool {
 	v, err := strconv.ParseBool(s)
 	if err != nil {
@@ -273,6 +323,13 @@ func convertToBool(s string, defaultValue bool) bool {
 	return v
 }
 
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func fitsUint32(val int64) bool {
 	return val >= 0 && val <= 0xffffffff
 }
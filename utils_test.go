@@ -24,7 +24,13 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestDSNParse(t *testing.T) {
@@ -95,3 +101,205 @@ func TestDSNParse(t *testing.T) {
 	}
 
 }
+
+func TestAuxAddr(t *testing.T) {
+	var testAddrs = []struct {
+		dsn  string
+		host string
+		port int
+		addr string
+	}{
+		{"user:password@db.example.com/dbname", "10.0.0.5", 3051, "10.0.0.5:3051"},
+		{"user:password@db.example.com/dbname", "", 3051, "db.example.com:3051"},
+		{"user:password@[::1]:13050/dbname", "", 3051, "[::1]:3051"},
+		{"user:password@db.example.com/dbname?aux_host=gw.example.com", "10.0.0.5", 3051, "gw.example.com:3051"},
+		{"user:password@db.example.com/dbname?aux_port=13051", "", 3051, "db.example.com:13051"},
+	}
+	for _, d := range testAddrs {
+		dsn, err := parseDSN(d.dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr, err := dsn.auxAddr(d.host, d.port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != d.addr {
+			t.Errorf("auxAddr fail:%s(%s != %s)", d.dsn, addr, d.addr)
+		}
+	}
+
+	cfg, err := ParseConfig("user:password@db.example.com/dbname?aux_host=gw.example.com&aux_port=13051")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AuxHost = "localhost"
+	cfg.AuxPorts = map[int]int{3051: 23051}
+	dsn := cfg.firebirdDsn()
+	if addr, _ := dsn.auxAddr("10.0.0.5", 3051); addr != "localhost:23051" {
+		t.Errorf("auxAddr fail:%s", addr)
+	}
+	if addr, _ := dsn.auxAddr("10.0.0.5", 3052); addr != "localhost:13051" {
+		t.Errorf("auxAddr fail:%s", addr)
+	}
+	cfg.AuxPortFunc = func(port int) int { return port + 1 }
+	dsn = cfg.firebirdDsn()
+	if addr, _ := dsn.auxAddr("10.0.0.5", 3051); addr != "localhost:3052" {
+		t.Errorf("auxAddr fail:%s", addr)
+	}
+
+	dsn, _ = parseDSN("user:password@localhost/dbname?aux_port=port")
+	if _, err = dsn.auxAddr("", 3051); err == nil {
+		t.Fatalf("Error Not occured")
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	cfg, err := ParseConfig("user:password@db.example.com/dbname?dial_timeout=5s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dialed string
+	var deadline time.Duration
+	cfg.Dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = network + " " + addr
+		if d, ok := ctx.Deadline(); ok {
+			deadline = time.Until(d)
+		}
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	}
+	conn, err := cfg.firebirdDsn().dialContext(context.Background(), "gw.example.com:13051")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if dialed != "tcp gw.example.com:13051" {
+		t.Errorf("dialContext fail:%s", dialed)
+	}
+	if deadline <= 0 || deadline > 5*time.Second {
+		t.Errorf("dialContext fail:deadline %v", deadline)
+	}
+
+	cfg.DialTimeout = time.Second
+	cfg.firebirdDsn().dialContext(context.Background(), "gw.example.com:13051")
+	if deadline > time.Second {
+		t.Errorf("dialContext fail:deadline %v", deadline)
+	}
+
+	dsn, _ := parseDSN("user:password@localhost/dbname?dial_timeout=5")
+	if _, err = dsn.dialContext(context.Background(), "localhost:3050"); err == nil {
+		t.Fatalf("Error Not occured")
+	}
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	type ctxKey struct{}
+	cfg, err := ParseConfig("user:password@db.example.com/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	cfg.CredentialsProvider = func(ctx context.Context) (string, string, error) {
+		calls = append(calls, ctx.Value(ctxKey{}).(string))
+		if len(calls) == 3 {
+			return "", "", errors.New("vault sealed")
+		}
+		return "rotated", "secret" + strconv.Itoa(len(calls)), nil
+	}
+	errNoServer := errors.New("no server")
+	cfg.Dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errNoServer
+	}
+
+	// resolved for each new connection
+	connector := NewConnector(cfg)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "connect")
+	for i := 0; i < 2; i++ {
+		if _, err = connector.Connect(ctx); !errors.Is(err, errNoServer) {
+			t.Fatalf("Connect fail:%v", err)
+		}
+	}
+	if _, err = connector.Connect(ctx); err == nil || errors.Is(err, errNoServer) {
+		t.Fatalf("Connect fail:%v", err)
+	}
+	if len(calls) != 3 || calls[0] != "connect" {
+		t.Errorf("CredentialsProvider fail:%v", calls)
+	}
+
+	dsn := cfg.firebirdDsn()
+	calls = nil
+	resolved, err := dsn.resolveCredentials(context.WithValue(context.Background(), ctxKey{}, "resolve"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.user != "rotated" || resolved.passwd != "secret1" || resolved.credentials != nil {
+		t.Errorf("resolveCredentials fail:%s,%s", resolved.user, resolved.passwd)
+	}
+	if dsn.user != "user" || dsn.passwd != "password" {
+		t.Errorf("resolveCredentials changed dsn:%s,%s", dsn.user, dsn.passwd)
+	}
+}
+
+func TestCredentialsProviderDPB(t *testing.T) {
+	cfg, err := ParseConfig("user:password@db.example.com/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.CredentialsProvider = func(ctx context.Context) (string, string, error) {
+		return "rotated", "secret", nil
+	}
+	chAttach := make(chan []byte, 1)
+	cfg.Dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			buf := make([]byte, 65536)
+			if _, err := server.Read(buf); err != nil { // op_connect
+				return
+			}
+			// op_accept of a server without authentication plugins
+			server.Write(bytes.Join([][]byte{
+				bint32_to_bytes(op_accept), bint32_to_bytes(PROTOCOL_VERSION13), bint32_to_bytes(1), bint32_to_bytes(ptype_batch_send),
+			}, nil))
+			n, _ := server.Read(buf) // op_attach
+			chAttach <- buf[:n]
+		}()
+		return client, nil
+	}
+	if _, err = NewConnector(cfg).Connect(context.Background()); err == nil {
+		t.Fatalf("Error Not occured")
+	}
+	attach := <-chAttach
+	if bytes_to_bint32(attach[:4]) != op_attach {
+		t.Fatalf("op_attach fail:%v", attach)
+	}
+	if !bytes.Contains(attach, []byte("\x1c\x07ROTATED")) {
+		t.Errorf("DPB user fail:%q", attach)
+	}
+	if !bytes.Contains(attach, []byte("\x1d\x06secret")) {
+		t.Errorf("DPB password fail:%q", attach)
+	}
+	if bytes.Contains(attach, []byte("USER")) {
+		t.Errorf("DPB has the static user:%q", attach)
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	var testNames = []struct {
+		name   string
+		quoted string
+	}{
+		{"ORDERS", "ORDERS"},
+		{"Order", `"Order"`},
+		{"ORDER", `"ORDER"`},
+		{`A "B"`, `"A ""B"""`},
+		{"RDB$RELATIONS", "RDB$RELATIONS"},
+	}
+	for _, d := range testNames {
+		if s := QuoteIdentifier(d.name); s != d.quoted {
+			t.Errorf("QuoteIdentifier fail:%s(%s != %s)", d.name, s, d.quoted)
+		}
+	}
+}
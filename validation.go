@@ -0,0 +1,247 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidateOptions controls online validation. Patterns use the SIMILAR TO
+// syntax and are matched against table and index names.
+type ValidateOptions struct {
+	IncludeTables  string
+	ExcludeTables  string
+	IncludeIndices string
+	ExcludeIndices string
+	LockTimeout    int32 // seconds to wait for a table lock, 0 uses the server default
+}
+
+type ValidateOption func(*ValidateOptions)
+
+func GetDefaultValidateOptions() ValidateOptions {
+	return ValidateOptions{}
+}
+
+func WithIncludeTables(pattern string) ValidateOption {
+	return func(opts *ValidateOptions) {
+		opts.IncludeTables = pattern
+	}
+}
+
+func WithExcludeTables(pattern string) ValidateOption {
+	return func(opts *ValidateOptions) {
+		opts.ExcludeTables = pattern
+	}
+}
+
+func WithIncludeIndices(pattern string) ValidateOption {
+	return func(opts *ValidateOptions) {
+		opts.IncludeIndices = pattern
+	}
+}
+
+func WithExcludeIndices(pattern string) ValidateOption {
+	return func(opts *ValidateOptions) {
+		opts.ExcludeIndices = pattern
+	}
+}
+
+func WithLockTimeout(seconds int32) ValidateOption {
+	return func(opts *ValidateOptions) {
+		opts.LockTimeout = seconds
+	}
+}
+
+func NewValidateOptions(opts ...ValidateOption) ValidateOptions {
+	res := GetDefaultValidateOptions()
+	for _, opt := range opts {
+		opt(&res)
+	}
+	return res
+}
+
+// ValidationReport is the parsed output of an online validation.
+type ValidationReport struct {
+	Tables   []TableValidation
+	Errors   []string // messages not attributed to a table
+	Warnings []string
+	Output   []string // lines as sent by the server
+}
+
+// TableValidation holds the result for a table and its indices.
+type TableValidation struct {
+	ID         int
+	Name       string
+	OK         bool
+	ErrorCount int // as reported by the server
+	Errors     []string
+	Warnings   []string
+	Indices    []IndexValidation
+}
+
+// IndexValidation holds the messages reported while checking an index.
+type IndexValidation struct {
+	ID       int
+	Name     string
+	Errors   []string
+	Warnings []string
+}
+
+// HasErrors reports whether any error was found.
+func (r *ValidationReport) HasErrors() bool {
+	if len(r.Errors) > 0 {
+		return true
+	}
+	for _, t := range r.Tables {
+		if t.ErrorCount > 0 || len(t.Errors) > 0 {
+			return true
+		}
+		for _, idx := range t.Indices {
+			if len(idx.Errors) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	validationTimePattern     = regexp.MustCompile(`^\d{1,2}:\d{2}:\d{2}\.\d+\s+`)
+	validationRelationPattern = regexp.MustCompile(`^Relation (\d+) \((.*?)\)(?:\s*(.*))?$`)
+	validationIndexPattern    = regexp.MustCompile(`^Index (\d+) \((.*)\)$`)
+	validationErrorsPattern   = regexp.MustCompile(`^:\s*(\d+) ERRORS? found`)
+)
+
+type validationParser struct {
+	report *ValidationReport
+	table  *TableValidation
+	index  *IndexValidation
+}
+
+func appendValidationMessage(errors, warnings *[]string, text string, isWarning bool) {
+	if isWarning {
+		*warnings = append(*warnings, text)
+	} else {
+		*errors = append(*errors, text)
+	}
+}
+
+func (p *validationParser) parse(line string) {
+	p.report.Output = append(p.report.Output, line)
+	text := strings.TrimSpace(validationTimePattern.ReplaceAllString(line, ""))
+
+	if m := validationRelationPattern.FindStringSubmatch(text); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		if p.table == nil || p.table.ID != id {
+			p.report.Tables = append(p.report.Tables, TableValidation{ID: id, Name: m[2]})
+			p.table = &p.report.Tables[len(p.report.Tables)-1]
+		}
+		p.index = nil
+		switch rest := m[3]; {
+		case rest == "":
+		case rest == "is ok":
+			p.table.OK = true
+		case validationErrorsPattern.MatchString(rest):
+			p.table.ErrorCount, _ = strconv.Atoi(validationErrorsPattern.FindStringSubmatch(rest)[1])
+		default:
+			// e.g. ": timeout 10 sec, failed to acquire lock"
+			p.table.Errors = append(p.table.Errors, strings.TrimSpace(strings.TrimPrefix(rest, ":")))
+		}
+		return
+	}
+	if m := validationIndexPattern.FindStringSubmatch(text); m != nil && p.table != nil {
+		id, _ := strconv.Atoi(m[1])
+		p.table.Indices = append(p.table.Indices, IndexValidation{ID: id, Name: m[2]})
+		p.index = &p.table.Indices[len(p.table.Indices)-1]
+		return
+	}
+
+	var isWarning bool
+	switch {
+	case strings.HasPrefix(text, "Warning:"):
+		isWarning = true
+		text = strings.TrimSpace(strings.TrimPrefix(text, "Warning:"))
+	case strings.HasPrefix(text, "Error:"):
+		text = strings.TrimSpace(strings.TrimPrefix(text, "Error:"))
+	default:
+		// progress and start / finish lines
+		return
+	}
+	switch {
+	case p.index != nil:
+		appendValidationMessage(&p.index.Errors, &p.index.Warnings, text, isWarning)
+	case p.table != nil:
+		appendValidationMessage(&p.table.Errors, &p.table.Warnings, text, isWarning)
+	default:
+		appendValidationMessage(&p.report.Errors, &p.report.Warnings, text, isWarning)
+	}
+}
+
+// ParseValidationReport parses the output of an online validation.
+func ParseValidationReport(lines []string) *ValidationReport {
+	p := &validationParser{report: &ValidationReport{}}
+	for _, line := range lines {
+		p.parse(line)
+	}
+	return p.report
+}
+
+func validateSPB(database string, options ValidateOptions) []byte {
+	spb := NewXPBWriterFromTag(isc_action_svc_validate)
+	spb.PutString(isc_spb_dbname, database)
+	if options.IncludeTables != "" {
+		spb.PutString(isc_spb_val_tab_incl, options.IncludeTables)
+	}
+	if options.ExcludeTables != "" {
+		spb.PutString(isc_spb_val_tab_excl, options.ExcludeTables)
+	}
+	if options.IncludeIndices != "" {
+		spb.PutString(isc_spb_val_idx_incl, options.IncludeIndices)
+	}
+	if options.ExcludeIndices != "" {
+		spb.PutString(isc_spb_val_idx_excl, options.ExcludeIndices)
+	}
+	if options.LockTimeout != 0 {
+		spb.PutInt32(isc_spb_val_lock_timeout, options.LockTimeout)
+	}
+	return spb.Bytes()
+}
+
+// ValidateOnline validates database while it stays online (Firebird 3+).
+// Tables are locked one at a time; a table that can't be locked within the
+// lock timeout is reported with an error and skipped.
+func (mm *MaintenanceManager) ValidateOnline(ctx context.Context, database string, options ValidateOptions) (*ValidationReport, error) {
+	p := &validationParser{report: &ValidationReport{}}
+	err := serviceRunContext(ctx, mm.connBuilder, validateSPB(database, options), func(line string) error {
+		p.parse(line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.report, nil
+}
@@ -0,0 +1,119 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOptions(t *testing.T) {
+	opts := NewValidateOptions()
+	assert.Equal(t, ValidateOptions{}, opts)
+	opts = NewValidateOptions(WithIncludeTables("T%"), WithExcludeTables("TMP%"), WithIncludeIndices("IDX%"), WithExcludeIndices("RDB$%"), WithLockTimeout(5))
+	assert.Equal(t, ValidateOptions{IncludeTables: "T%", ExcludeTables: "TMP%", IncludeIndices: "IDX%", ExcludeIndices: "RDB$%", LockTimeout: 5}, opts)
+
+	spb := NewXPBReader(validateSPB("employee", opts))
+	_, action := spb.Next()
+	assert.Equal(t, byte(isc_action_svc_validate), action)
+	_, tag := spb.Next()
+	assert.Equal(t, byte(isc_spb_dbname), tag)
+	assert.Equal(t, "employee", spb.GetString())
+	for _, want := range []struct {
+		tag   byte
+		value string
+	}{{isc_spb_val_tab_incl, "T%"}, {isc_spb_val_tab_excl, "TMP%"}, {isc_spb_val_idx_incl, "IDX%"}, {isc_spb_val_idx_excl, "RDB$%"}} {
+		_, tag = spb.Next()
+		assert.Equal(t, want.tag, tag)
+		assert.Equal(t, want.value, spb.GetString())
+	}
+	_, tag = spb.Next()
+	assert.Equal(t, byte(isc_spb_val_lock_timeout), tag)
+	assert.Equal(t, int32(5), spb.GetInt32())
+	assert.True(t, spb.End())
+}
+
+func TestParseValidationReport(t *testing.T) {
+	report := ParseValidationReport([]string{
+		"10:20:30.01 Validation started",
+		"10:20:30.01 Relation 128 (COUNTRY)",
+		"10:20:30.01   process pointer page    0 of    1",
+		"10:20:30.01 Index 1 (RDB$PRIMARY1)",
+		"10:20:30.01 Relation 128 (COUNTRY) is ok",
+		"10:20:30.02 Relation 129 (JOB)",
+		"10:20:30.02   process pointer page    0 of    1",
+		"10:20:30.02 Warning: Page 213 is an orphan",
+		"10:20:30.02 Index 1 (RDB$PRIMARY2)",
+		"10:20:30.02 Error: Index 1 is corrupt (missing entries for record 5)",
+		"10:20:30.02 Relation 129 (JOB) : 1 ERRORS found",
+		"10:20:30.03 Relation 130 (SALES)",
+		"10:20:30.03 Relation 130 (SALES) : timeout 1 sec, failed to acquire lock",
+		"10:20:30.03 Validation finished",
+	})
+
+	require.Len(t, report.Tables, 3)
+	assert.Equal(t, TableValidation{ID: 128, Name: "COUNTRY", OK: true, Indices: []IndexValidation{{ID: 1, Name: "RDB$PRIMARY1"}}}, report.Tables[0])
+
+	job := report.Tables[1]
+	assert.False(t, job.OK)
+	assert.Equal(t, 1, job.ErrorCount)
+	assert.Equal(t, []string{"Page 213 is an orphan"}, job.Warnings)
+	require.Len(t, job.Indices, 1)
+	assert.Equal(t, []string{"Index 1 is corrupt (missing entries for record 5)"}, job.Indices[0].Errors)
+
+	assert.Equal(t, []string{"timeout 1 sec, failed to acquire lock"}, report.Tables[2].Errors)
+	assert.True(t, report.HasErrors())
+	assert.Len(t, report.Output, 14)
+
+	assert.False(t, ParseValidationReport([]string{"Validation started", "Validation finished"}).HasErrors())
+}
+
+func TestMaintenanceManager_ValidateOnline(t *testing.T) {
+	if get_firebird_major_version(t) < 3 {
+		t.Skip("online validation requires Firebird 3.0+")
+	}
+
+	dbPath := GetTestDatabase("test_validate_online_")
+	conn, err := sql.Open("firebirdsql_createdb", GetTestDSNFromDatabase(dbPath))
+	require.NoError(t, err)
+	_, err = conn.Exec("CREATE TABLE test_validate (id INTEGER NOT NULL PRIMARY KEY)")
+	require.NoError(t, err)
+	_, err = conn.Exec("INSERT INTO test_validate VALUES (1)")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	m, err := NewMaintenanceManager("localhost:3050", GetTestUser(), GetTestPassword(), GetDefaultServiceManagerOptions())
+	require.NoError(t, err)
+
+	report, err := m.ValidateOnline(context.Background(), dbPath, NewValidateOptions(WithIncludeTables("TEST_VALIDATE"), WithLockTimeout(1)))
+	require.NoError(t, err)
+	assert.False(t, report.HasErrors())
+	require.Len(t, report.Tables, 1)
+	assert.Equal(t, "TEST_VALIDATE", report.Tables[0].Name)
+	assert.True(t, report.Tables[0].OK)
+}
@@ -0,0 +1,81 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Wire encryption policies of the wire_crypt parameter, sent to the server
+// as CNCT_client_crypt.
+const (
+	wireCryptDisabled = 0
+	wireCryptEnabled  = 1
+	wireCryptRequired = 2
+)
+
+// ErrWireCryptRequired is returned when wire_crypt=required and the
+// connection could not be encrypted.
+var ErrWireCryptRequired = errors.New("wire encryption is required but no cipher was negotiated")
+
+// parseWireCrypt parses the wire_crypt parameter: required, enabled or
+// disabled, or a boolean for enabled or disabled.
+func parseWireCrypt(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "required":
+		return wireCryptRequired, nil
+	case "enabled":
+		return wireCryptEnabled, nil
+	case "disabled":
+		return wireCryptDisabled, nil
+	}
+	enabled, err := strconv.ParseBool(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wire_crypt %q", s)
+	}
+	if enabled {
+		return wireCryptEnabled, nil
+	}
+	return wireCryptDisabled, nil
+}
+
+// wireCryptPlugins parses the wire_crypt_plugins parameter, the ciphers to
+// use in order of preference.
+func wireCryptPlugins(s string) ([]string, error) {
+	var plugins []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+		case "ChaCha64", "ChaCha", "Arc4":
+			plugins = append(plugins, name)
+		default:
+			return nil, fmt.Errorf("invalid wire_crypt_plugins %q", s)
+		}
+	}
+	return plugins, nil
+}
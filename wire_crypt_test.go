@@ -0,0 +1,165 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2013-2024 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestParseWireCrypt(t *testing.T) {
+	var testPolicies = []struct {
+		s      string
+		policy int
+	}{
+		{"true", wireCryptEnabled},
+		{"false", wireCryptDisabled},
+		{"Required", wireCryptRequired},
+		{"enabled", wireCryptEnabled},
+		{"DISABLED", wireCryptDisabled},
+	}
+	for _, d := range testPolicies {
+		policy, err := parseWireCrypt(d.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if policy != d.policy {
+			t.Errorf("parseWireCrypt fail:%s(%d != %d)", d.s, policy, d.policy)
+		}
+	}
+	if _, err := parseWireCrypt("requird"); err == nil {
+		t.Fatalf("Error Not occured")
+	}
+
+	plugins, err := wireCryptPlugins("Arc4, ChaCha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(plugins, []string{"Arc4", "ChaCha"}) {
+		t.Errorf("wireCryptPlugins fail:%v", plugins)
+	}
+	if _, err = wireCryptPlugins("ChaCha,Blowfish"); err == nil {
+		t.Fatalf("Error Not occured")
+	}
+}
+
+func TestGuessWireCrypt(t *testing.T) {
+	chacha64Nonce := append([]byte("ChaCha64\x00"), bytes.Repeat([]byte{1}, 8)...)
+	chachaNonce := append([]byte("ChaCha\x00"), bytes.Repeat([]byte{2}, 12)...)
+	plugins := []byte("ChaCha64 ChaCha Arc4")
+	buf := bytes.Join([][]byte{
+		{1, byte(len(plugins))}, plugins,
+		{3, byte(len(chacha64Nonce))}, chacha64Nonce,
+		{3, byte(len(chachaNonce))}, chachaNonce,
+	}, nil)
+
+	var testGuesses = []struct {
+		preferred string
+		plugin    string
+		nonce     []byte
+	}{
+		{"ChaCha64,ChaCha,Arc4", "ChaCha64", chacha64Nonce[9:]},
+		{"ChaCha,ChaCha64", "ChaCha", chachaNonce[7:]},
+		{"Arc4,ChaCha64", "Arc4", nil},
+		{"", "", nil},
+	}
+	p := &wireProtocol{}
+	for _, d := range testGuesses {
+		p.wireCryptPlugins, _ = wireCryptPlugins(d.preferred)
+		plugin, nonce := p._guess_wire_crypt(buf)
+		if plugin != d.plugin || !bytes.Equal(nonce, d.nonce) {
+			t.Errorf("_guess_wire_crypt fail:%s(%s != %s)", d.preferred, plugin, d.plugin)
+		}
+	}
+
+	// ChaCha64 offered without its nonce
+	buf = bytes.Join([][]byte{{1, byte(len(plugins))}, plugins, {3, byte(len(chachaNonce))}, chachaNonce}, nil)
+	p.wireCryptPlugins, _ = wireCryptPlugins("ChaCha64,ChaCha")
+	if plugin, _ := p._guess_wire_crypt(buf); plugin != "ChaCha" {
+		t.Errorf("_guess_wire_crypt fail:%s", plugin)
+	}
+}
+
+func TestWireCryptRequired(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	wp, _ := newWireProtocolConn(client, "localhost:3050", "", "")
+	wp.wireCrypt = wireCryptRequired
+	go func() {
+		defer server.Close()
+		// op_accept of a server without authentication plugins
+		server.Write(bytes.Join([][]byte{
+			bint32_to_bytes(op_accept), bint32_to_bytes(PROTOCOL_VERSION13), bint32_to_bytes(1), bint32_to_bytes(ptype_lazy_send),
+		}, nil))
+	}()
+	if err := wp._parse_connect_response("sysdba", "masterkey", nil); !errors.Is(err, ErrWireCryptRequired) {
+		t.Fatalf("_parse_connect_response fail:%v", err)
+	}
+}
+
+func TestConnectionInfo(t *testing.T) {
+	test_dsn := GetTestDSN("test_connection_info_")
+	conn, err := sql.Open("firebirdsql_createdb", test_dsn+"?wire_crypt=required&wire_crypt_plugins=ChaCha,Arc4")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer conn.Close()
+	c, err := conn.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	info, err := ConnectionInfo(c)
+	if err != nil {
+		t.Fatalf("Error ConnectionInfo: %v", err)
+	}
+	if info.WireCrypt != "ChaCha" && info.WireCrypt != "Arc4" {
+		t.Errorf("Error WireCrypt: %s", info.WireCrypt)
+	}
+	if info.ProtocolVersion < PROTOCOL_VERSION13 || info.AuthPlugin == "" || info.WireCompress {
+		t.Errorf("Error ConnectionInfo: %+v", info)
+	}
+
+	c2, err := sql.Open("firebirdsql", test_dsn+"?wire_crypt=disabled&wire_compress=true")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c2.Close()
+	c, err = c2.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	if info, err = ConnectionInfo(c); err != nil {
+		t.Fatalf("Error ConnectionInfo: %v", err)
+	}
+	if info.WireCrypt != "" || !info.WireCompress {
+		t.Errorf("Error ConnectionInfo: %+v", info)
+	}
+}
@@ -29,22 +29,18 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/big"
 	"net"
 	"os"
-	"reflect"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kardianos/osext"
-	"gitlab.com/nyarla/go-crypt"
 	// "unsafe"
 )
 
 const (
-	PLUGIN_LIST       = "Srp256,Srp,Legacy_Auth"
 	BUFFER_LEN        = 1024
 	MAX_CHAR_LENGTH   = 32767
 	BLOB_SEGMENT_SIZE = 32000
@@ -99,10 +95,18 @@ type wireProtocol struct {
 	lazyResponseCount  int
 
 	pluginName string
+	pluginList string
+	authPlugin AuthPlugin // plugin of the data sent with op_connect
 	user       string
 	password   string
 	authData   []byte
 
+	wireCrypt        int      // wireCryptDisabled, wireCryptEnabled or wireCryptRequired
+	wireCryptPlugins []string // ciphers in order of preference
+
+	// answers op_crypt_key_callback, nil answers with no data
+	cryptKeyCallback func(serverData []byte) ([]byte, error)
+
 	charset        string
 	charsetByteLen int
 
@@ -111,15 +115,21 @@ type wireProtocol struct {
 }
 
 func newWireProtocol(addr string, timezone string, charset string) (*wireProtocol, error) {
-	p := new(wireProtocol)
-	p.buf = make([]byte, 0, BUFFER_LEN)
-
-	p.addr = addr
-	conn, err := net.Dial("tcp", p.addr)
+	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
+	return newWireProtocolConn(conn, addr, timezone, charset)
+}
 
+// newWireProtocolConn is like newWireProtocol, over a connection to addr
+// the caller has dialed.
+func newWireProtocolConn(conn net.Conn, addr string, timezone string, charset string) (*wireProtocol, error) {
+	p := new(wireProtocol)
+	p.buf = make([]byte, 0, BUFFER_LEN)
+	p.addr = addr
+
+	var err error
 	p.conn, err = newWireChannel(conn)
 	p.timezone = timezone
 	p.charset = charset
@@ -159,22 +169,7 @@ func (p *wireProtocol) appendBytes(bs []byte) {
 	p.buf = append(p.buf, bs...)
 }
 
-func getSrpClientPublicBytes(clientPublic *big.Int) (bs []byte) {
-	b := []byte(hex.EncodeToString(bigIntToBytes(clientPublic)))
-	if len(b) > 254 {
-		bs = bytes.Join([][]byte{
-			[]byte{CNCT_specific_data, byte(255), 0}, b[:254],
-			[]byte{CNCT_specific_data, byte(len(b)-254) + 1, 1}, b[254:],
-		}, nil)
-	} else {
-		bs = bytes.Join([][]byte{
-			[]byte{CNCT_specific_data, byte(len(b)) + 1, 0}, b,
-		}, nil)
-	}
-	return bs
-}
-
-func (p *wireProtocol) uid(user string, password string, authPluginName string, wireCrypt bool, clientPublic *big.Int) []byte {
+func (p *wireProtocol) uid(user string, specificData []byte) []byte {
 	sysUser := os.Getenv("USER")
 	if sysUser == "" {
 		sysUser = os.Getenv("USERNAME")
@@ -183,33 +178,16 @@ func (p *wireProtocol) uid(user string, password string, authPluginName string,
 
 	sysUserBytes := []byte(sysUser)
 	hostnameBytes := []byte(hostname)
-	pluginListNameBytes := []byte(PLUGIN_LIST)
-	pluginNameBytes := []byte(authPluginName)
-	userBytes := []byte(strings.ToUpper(user))
-	var wireCryptByte byte
-	if wireCrypt {
-		wireCryptByte = 1
-	} else {
-		wireCryptByte = 0
-	}
-
-	var specific_data []byte
-	if authPluginName == "Srp" || authPluginName == "Srp256" {
-		specific_data = getSrpClientPublicBytes(clientPublic)
-	} else if authPluginName == "Legacy_Auth" {
-		b := []byte(crypt.Crypt(password, "9z")[2:])
-		specific_data = bytes.Join([][]byte{
-			[]byte{CNCT_specific_data, byte(len(b)) + 1, 0}, b,
-		}, nil)
-	} else {
-		panic(fmt.Sprintf("Unknown plugin name:%s", authPluginName))
-	}
+	pluginListNameBytes := []byte(p.pluginList)
+	pluginNameBytes := []byte(p.authPlugin.Name())
+	userBytes := []byte(normalizeUser(user))
+	wireCryptByte := byte(p.wireCrypt)
 
 	return bytes.Join([][]byte{
 		[]byte{CNCT_login, byte(len(userBytes))}, userBytes,
 		[]byte{CNCT_plugin_name, byte(len(pluginNameBytes))}, pluginNameBytes,
 		[]byte{CNCT_plugin_list, byte(len(pluginListNameBytes))}, pluginListNameBytes,
-		specific_data,
+		specificData,
 		[]byte{CNCT_client_crypt, 4, wireCryptByte, 0, 0, 0},
 		[]byte{CNCT_user, byte(len(sysUserBytes))}, sysUserBytes,
 		[]byte{CNCT_host, byte(len(hostnameBytes))}, hostnameBytes,
@@ -454,11 +432,14 @@ func (p *wireProtocol) _guess_wire_crypt(buf []byte) (string, []byte) {
 	plugin_nonce := make([][]byte, 0, 2)
 
 	i := 0
-	for i = 0; i < len(buf); {
+	for i = 0; i+1 < len(buf); {
 		t := buf[i]
 		i++
 		ln := int(buf[i])
 		i++
+		if i+ln > len(buf) {
+			break
+		}
 		v := buf[i : i+ln]
 		i += ln
 		if t == 1 {
@@ -467,25 +448,31 @@ func (p *wireProtocol) _guess_wire_crypt(buf []byte) (string, []byte) {
 			plugin_nonce = append(plugin_nonce, v)
 		}
 	}
-	if slices.Contains(available_plugins, "ChaCha64") {
-		for _, nonce := range plugin_nonce {
-			if reflect.DeepEqual(nonce[:9], []byte{'C', 'h', 'a', 'C', 'h', 'a', '6', '4', 0}) {
-				return "ChaCha64", nonce[9:]
-			}
+	for _, plugin := range p.wireCryptPlugins {
+		if !slices.Contains(available_plugins, plugin) {
+			continue
 		}
-	} else if slices.Contains(available_plugins, "ChaCha") {
-		for _, nonce := range plugin_nonce {
-			if reflect.DeepEqual(nonce[:7], []byte{'C', 'h', 'a', 'C', 'h', 'a', 0}) {
-				return "ChaCha", nonce[7 : 7+12]
+		switch plugin {
+		case "ChaCha64":
+			for _, nonce := range plugin_nonce {
+				if bytes.HasPrefix(nonce, []byte{'C', 'h', 'a', 'C', 'h', 'a', '6', '4', 0}) {
+					return "ChaCha64", nonce[9:]
+				}
 			}
+		case "ChaCha":
+			for _, nonce := range plugin_nonce {
+				if bytes.HasPrefix(nonce, []byte{'C', 'h', 'a', 'C', 'h', 'a', 0}) && len(nonce) >= 7+12 {
+					return "ChaCha", nonce[7 : 7+12]
+				}
+			}
+		case "Arc4":
+			return "Arc4", nil
 		}
-	} else if slices.Contains(available_plugins, "Arc4") {
-		return "Arc4", nil
 	}
 	return "", nil
 }
 
-func (p *wireProtocol) _parse_connect_response(user string, password string, options map[string]string, clientPublic *big.Int, clientSecret *big.Int) (err error) {
+func (p *wireProtocol) _parse_connect_response(user string, password string, options map[string]string) (err error) {
 	p.debugPrint("_parse_connect_response")
 
 	b, err := p.recvPackets(4)
@@ -538,60 +525,51 @@ func (p *wireProtocol) _parse_connect_response(user string, password string, opt
 		ln = int(bytes_to_bint32(b))
 		_, _ = p.recvPacketsAlignment(ln) // keys
 
+		var plugin AuthPlugin
 		var authData []byte
 		var sessionKey []byte
 		if isAuthenticated == 0 {
-			if p.pluginName == "Srp" || p.pluginName == "Srp256" {
-
-				// TODO: normalize user
-
-				if len(data) == 0 {
-					p.opContAuth(bigIntToBytes(clientPublic), p.pluginName, PLUGIN_LIST, "")
-					b, _ := p.recvPackets(4)
-					op := bytes_to_bint32(b)
-					if op == op_response {
-						_, _, _, err = p._parse_op_response() // error occurred
-						return
-					}
+			plugin = p.authPlugin
+			if plugin.Name() != p.pluginName {
+				if !slices.Contains(strings.Split(p.pluginList, ","), p.pluginName) {
+					err = errors.New("Your user name and password are not defined. Ask your database administrator to set up a Firebird login.\n")
+					return
+				}
+				if plugin, err = newAuthPlugin(p.pluginName, user, password); err != nil {
+					return
+				}
+			}
 
-					if op != op_cont_auth {
-						err = errors.New("Your user name and password are not defined. Ask your database administrator to set up a Firebird login.\n")
-						return
+			if len(data) == 0 {
+				// the server switched plugins, so it has no data of this one yet
+				if authData, _, err = plugin.Continue(nil); err != nil {
+					return
+				}
+				p.opContAuth(authData, p.pluginName, p.pluginList, "")
+				b, _ := p.recvPackets(4)
+				op := bytes_to_bint32(b)
+				if op == op_response {
+					_, _, _, err = p._parse_op_response() // error occurred, or authenticated
+					if err == nil && p.wireCrypt == wireCryptRequired {
+						err = ErrWireCryptRequired
 					}
+					return
+				}
 
-					b, _ = p.recvPackets(4)
-					ln = int(bytes_to_bint32(b))
-					data, _ = p.recvPacketsAlignment(ln)
-
-					b, _ = p.recvPackets(4)
-					ln = int(bytes_to_bint32(b))
-					_, _ = p.recvPacketsAlignment(ln) // pluginName
-
-					b, _ = p.recvPackets(4)
-					ln = int(bytes_to_bint32(b))
-					_, _ = p.recvPacketsAlignment(ln) // pluginList
+				if op != op_cont_auth {
+					err = errors.New("Your user name and password are not defined. Ask your database administrator to set up a Firebird login.\n")
+					return
+				}
 
-					b, _ = p.recvPackets(4)
-					ln = int(bytes_to_bint32(b))
-					_, _ = p.recvPacketsAlignment(ln) // keys
+				if data, err = p.recvContAuth(); err != nil {
+					return
 				}
 				if len(data) == 0 {
 					err = errors.New("Your user name and password are not defined. Ask your database administrator to set up a Firebird login.\n")
 					return
 				}
-
-				ln = int(bytes_to_int16(data[:2]))
-				serverSalt := data[2 : ln+2]
-				serverPublic := bigIntFromHexString(bytes_to_str(data[4+ln:]))
-				authData, sessionKey = getClientProof(strings.ToUpper(user), password, serverSalt, clientPublic, serverPublic, clientSecret, p.pluginName)
-				if DEBUG_SRP {
-					fmt.Printf("pluginName=%s\nserverSalt=%s\nserverPublic(bin)=%s\nserverPublic=%s\nauthData=%v,sessionKey=%v\n",
-						p.pluginName, serverSalt, data[4+ln:], serverPublic, authData, sessionKey)
-				}
-			} else if p.pluginName == "Legacy_Auth" {
-				authData = []byte(crypt.Crypt(password, "9z")[2:])
-			} else {
-				err = errors.New("Your user name and password are not defined. Ask your database administrator to set up a Firebird login.\n")
+			}
+			if authData, sessionKey, err = plugin.Continue(data); err != nil {
 				return
 			}
 		}
@@ -600,17 +578,41 @@ func (p *wireProtocol) _parse_connect_response(user string, password string, opt
 		var nonce []byte
 
 		if opcode == op_cond_accept {
-			p.opContAuth(authData, options["auth_plugin_name"], PLUGIN_LIST, "")
+			// a plugin may take several rounds: answer each op_cont_auth
+			// until the server settles with op_response
 			var buf []byte
-			_, _, buf, err = p.opResponse()
-			if err != nil {
-				return
+			for {
+				p.opContAuth(authData, p.pluginName, p.pluginList, "")
+				b, _ := p.recvPackets(4)
+				for bytes_to_bint32(b) == op_dummy {
+					b, _ = p.recvPackets(4)
+				}
+				op := bytes_to_bint32(b)
+				if op == op_response {
+					if _, _, buf, err = p._parse_op_response(); err != nil {
+						return
+					}
+					break
+				}
+				if op != op_cont_auth || plugin == nil {
+					err = errors.New("Your user name and password are not defined. Ask your database administrator to set up a Firebird login.\n")
+					return
+				}
+				if data, err = p.recvContAuth(); err != nil {
+					return
+				}
+				var key []byte
+				if authData, key, err = plugin.Continue(data); err != nil {
+					return
+				}
+				if key != nil {
+					sessionKey = key
+				}
 			}
 			enc_plugin, nonce = p._guess_wire_crypt(buf)
 		}
 
-		wire_crypt, _ := strconv.ParseBool(options["wire_crypt"])
-		if enc_plugin != "" && wire_crypt && sessionKey != nil {
+		if enc_plugin != "" && p.wireCrypt != wireCryptDisabled && sessionKey != nil {
 			// Send op_crypt
 			p.opCrypt(enc_plugin)
 			p.conn.setCryptKey(enc_plugin, sessionKey, nonce)
@@ -618,6 +620,9 @@ func (p *wireProtocol) _parse_connect_response(user string, password string, opt
 			if err != nil {
 				return
 			}
+		} else if p.wireCrypt == wireCryptRequired {
+			err = ErrWireCryptRequired
+			return
 		} else {
 			p.authData = authData // use later opAttach and opCreate
 		}
@@ -627,11 +632,43 @@ func (p *wireProtocol) _parse_connect_response(user string, password string, opt
 			err = errors.New("_parse_connect_response() protocol error")
 			return
 		}
+		if p.wireCrypt == wireCryptRequired {
+			err = ErrWireCryptRequired
+			return
+		}
+		// the server took the data sent with op_connect, or checks the
+		// password of the DPB if it predates authentication plugins
+		if p.protocolVersion < PROTOCOL_VERSION13 {
+			p.pluginName = "Legacy_Auth"
+		} else {
+			p.pluginName = p.authPlugin.Name()
+		}
 	}
 
 	return
 }
 
+// recvContAuth reads the rest of an op_cont_auth packet and returns the
+// plugin data.
+func (p *wireProtocol) recvContAuth() ([]byte, error) {
+	var data []byte
+	// data, plugin name, plugin list and keys
+	for i := 0; i < 4; i++ {
+		b, err := p.recvPackets(4)
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.recvPacketsAlignment(int(bytes_to_bint32(b)))
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			data = v
+		}
+	}
+	return data, nil
+}
+
 func (p *wireProtocol) _parse_select_items(buf []byte, xsqlda []xSQLVAR) (int, error) {
 	var err error
 	var ln int
@@ -859,10 +896,24 @@ func (p *wireProtocol) getBlobSegments(blobId []byte, transHandle int32) ([]byte
 	return blob, err
 }
 
-func (p *wireProtocol) opConnect(dbName string, user string, password string, options map[string]string, clientPublic *big.Int) error {
+func (p *wireProtocol) opConnect(dbName string, user string, password string, options map[string]string) error {
 	p.debugPrint("opConnect")
-	wire_crypt := true
-	wire_crypt, _ = strconv.ParseBool(options["wire_crypt"]) // errors default to false
+	var err error
+	if p.wireCrypt, err = parseWireCrypt(options["wire_crypt"]); err != nil {
+		return err
+	}
+	if p.wireCryptPlugins, err = wireCryptPlugins(options["wire_crypt_plugins"]); err != nil {
+		return err
+	}
+	if p.authPlugin, err = newAuthPlugin(options["auth_plugin_name"], user, password); err != nil {
+		return err
+	}
+	p.pluginList = authPluginList(options)
+	initialData, err := p.authPlugin.InitialData()
+	if err != nil {
+		return err
+	}
+
 	wire_compress := false
 	wire_compress, _ = strconv.ParseBool(options["wire_compress"]) // errors default to false
 
@@ -898,17 +949,17 @@ func (p *wireProtocol) opConnect(dbName string, user string, password string, op
 	p.packInt(1) // Arch type(GENERIC)
 	p.packString(dbName)
 	p.packInt(int32(len(protocols)))
-	p.packBytes(p.uid(strings.ToUpper(user), password, options["auth_plugin_name"], wire_crypt, clientPublic))
+	p.packBytes(p.uid(user, specificData(initialData)))
 	buf, _ := hex.DecodeString(strings.Join(protocols, ""))
 	p.appendBytes(buf)
-	_, err := p.sendPackets()
+	_, err = p.sendPackets()
 	return err
 }
 
 // appendAuthAndTimezone appends auth data and session timezone to a DPB byte slice.
 func (p *wireProtocol) appendAuthAndTimezone(dpb []byte) []byte {
 	if p.authData != nil {
-		specificAuthData := []byte(hex.EncodeToString(p.authData))
+		specificAuthData := p.authData
 		dpb = bytes.Join([][]byte{
 			dpb,
 			{isc_dpb_specific_auth_data, byte(len(specificAuthData))}, specificAuthData}, nil)
@@ -922,28 +973,9 @@ func (p *wireProtocol) appendAuthAndTimezone(dpb []byte) []byte {
 	return dpb
 }
 
-func (p *wireProtocol) opCreate(dbName string, user string, password string, role string) error {
+func (p *wireProtocol) opCreate(dbName string, user string, password string, role string, opts CreateOptions) error {
 	p.debugPrint("opCreate")
-	var page_size int32
-	page_size = 4096
-
-	encode := []byte(p.charset)
-	userBytes := []byte(strings.ToUpper(user))
-	passwordBytes := []byte(password)
-	roleBytes := []byte(role)
-	dpb := bytes.Join([][]byte{
-		[]byte{isc_dpb_version1},
-		[]byte{isc_dpb_set_db_charset, byte(len(encode))}, encode,
-		[]byte{isc_dpb_lc_ctype, byte(len(encode))}, encode,
-		[]byte{isc_dpb_user_name, byte(len(userBytes))}, userBytes,
-		[]byte{isc_dpb_password, byte(len(passwordBytes))}, passwordBytes,
-		[]byte{isc_dpb_sql_role_name, byte(len(roleBytes))}, roleBytes,
-		[]byte{isc_dpb_sql_dialect, 4}, int32_to_bytes(3),
-		[]byte{isc_dpb_force_write, 4}, bint32_to_bytes(1),
-		[]byte{isc_dpb_overwrite, 4}, bint32_to_bytes(1),
-		[]byte{isc_dpb_page_size, 4}, int32_to_bytes(page_size),
-		[]byte{isc_dpb_utf8_filename, 1, 1},
-	}, nil)
+	dpb := opts.createDpb(p.charset, user, password, role)
 
 	dpb = p.appendAuthAndTimezone(dpb)
 
@@ -958,7 +990,7 @@ func (p *wireProtocol) opCreate(dbName string, user string, password string, rol
 func (p *wireProtocol) opAttach(dbName string, user string, password string, role string) error {
 	p.debugPrint("opAttach")
 	encode := []byte(p.charset)
-	userBytes := []byte(strings.ToUpper(user))
+	userBytes := []byte(dpbUser(user))
 	passwordBytes := []byte(password)
 	roleBytes := []byte(role)
 
@@ -999,7 +1031,7 @@ func (p *wireProtocol) opAttach(dbName string, user string, password string, rol
 func (p *wireProtocol) opContAuth(authData []byte, authPluginName string, authPluginList string, keys string) error {
 	p.debugPrint("opContAuth")
 	p.packInt(op_cont_auth)
-	p.packString(hex.EncodeToString(authData))
+	p.packBytes(authData)
 	p.packString(authPluginName)
 	p.packString(authPluginList)
 	p.packString(keys)
@@ -1015,15 +1047,43 @@ func (p *wireProtocol) opCrypt(plugin string) error {
 	return err
 }
 
-func (p *wireProtocol) opCryptCallback() error {
+func (p *wireProtocol) opCryptCallback(data []byte) error {
 	p.debugPrint("opCryptCallback")
 	p.packInt(op_crypt_key_callback)
-	p.packInt(0)
+	p.packBytes(data)
 	p.packInt(int32(BUFFER_LEN))
 	_, err := p.sendPackets()
 	return err
 }
 
+// answerCryptCallback reads the rest of an op_crypt_key_callback, the data
+// of the crypt plugin of the database, and sends the reply of
+// cryptKeyCallback.
+func (p *wireProtocol) answerCryptCallback() error {
+	b, err := p.recvPackets(4)
+	if err != nil {
+		return err
+	}
+	data, err := p.recvPacketsAlignment(int(bytes_to_bint32(b)))
+	if err != nil {
+		return err
+	}
+	if p.protocolVersion >= PROTOCOL_VERSION14 || p.protocolVersion == 0 {
+		if _, err = p.recvPackets(4); err != nil { // size of the reply buffer
+			return err
+		}
+	}
+	p.debugPrint("op_crypt_key_callback:%v", data)
+
+	var reply []byte
+	if p.cryptKeyCallback != nil {
+		if reply, err = p.cryptKeyCallback(data); err != nil {
+			return err
+		}
+	}
+	return p.opCryptCallback(reply)
+}
+
 func (p *wireProtocol) opDropDatabase() error {
 	p.debugPrint("opDropDatabase")
 	p.packInt(op_drop_database)
@@ -1438,15 +1498,12 @@ func (p *wireProtocol) opResponse() (int32, []byte, []byte, error) {
 		b, _ = p.recvPackets(4)
 	}
 	for bytes_to_bint32(b) == op_crypt_key_callback {
-
-		err = p.opCryptCallback()
-		if err != nil {
+		if err = p.answerCryptCallback(); err != nil {
+			return 0, nil, nil, err
+		}
+		if b, err = p.recvPackets(4); err != nil {
 			return 0, nil, nil, err
 		}
-
-		b, _ = p.recvPackets(12)
-		b, _ = p.recvPackets(4)
-
 	}
 	for bytes_to_bint32(b) == op_response && p.lazyResponseCount > 0 {
 		p.lazyResponseCount--
@@ -1720,7 +1777,7 @@ func (p *wireProtocol) opServiceAttach() error {
 		{isc_spb_utf8_filename, 1, 1},
 	}, nil)
 	if p.authData != nil {
-		specificAuthData := []byte(hex.EncodeToString(p.authData))
+		specificAuthData := p.authData
 		spb = bytes.Join([][]byte{
 			spb,
 			{isc_dpb_specific_auth_data, byte(len(specificAuthData))}, specificAuthData}, nil)